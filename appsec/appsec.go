@@ -28,6 +28,14 @@ import (
 // AppSec is disabled or the given context is incorrect.
 // Note that passing the raw bytes of the HTTP request body is not expected and would
 // result in inaccurate attack detection.
+// JSON, URL-encoded form, multipart form and XML request bodies smaller than
+// DD_APPSEC_HTTP_BODY_MAX_SIZE bytes (64KiB by default) are already parsed and
+// monitored by the HTTP integrations before executing the request handler, so
+// this function is only required by other content types or larger bodies.
+// Larger bodies are not inspected by the HTTP integrations, except for the
+// complete fields found in their first DD_APPSEC_HTTP_BODY_MAX_SIZE bytes
+// for URL-encoded and multipart forms, and the request span is then tagged
+// with _dd.appsec.request.body_truncated.
 func MonitorParsedHTTPBody(ctx context.Context, body interface{}) {
 	if appsec.Enabled() {
 		httpsec.MonitorParsedBody(ctx, body)
@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package gqlgen
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
+)
+
+// Test that the resolver arguments are monitored by using custom rules
+func TestAppSec(t *testing.T) {
+	t.Setenv("DD_APPSEC_RULES", "../../../internal/appsec/testdata/blocking.json")
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	interceptor := NewTracer().(graphql.FieldInterceptor)
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		// Simulate the execution of the field resolver by the generated code
+		ctx := graphql.WithFieldContext(r.Context(), &graphql.FieldContext{
+			Object: "Query",
+			Field: graphql.CollectedField{
+				Field: &ast.Field{Name: "user"},
+			},
+			Args:       map[string]interface{}{"id": r.URL.Query().Get("id")},
+			IsResolver: true,
+		})
+		res, err := interceptor.InterceptField(ctx, func(context.Context) (interface{}, error) {
+			return "Hello World!\n", nil
+		})
+		if err != nil {
+			require.ErrorIs(t, err, graphqlsec.ErrBlocked)
+			return
+		}
+		w.Write([]byte(res.(string)))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{name: "no-block", id: "legit-resolver", status: http.StatusOK},
+		{name: "block", id: "blocked-resolver", status: http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			res, err := http.Get(srv.URL + "/graphql?id=" + tc.id)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, res.StatusCode)
+
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			if tc.status == http.StatusForbidden {
+				require.Contains(t, spans[0].Tag("_dd.appsec.json"), "blk-001-007")
+				require.Equal(t, true, spans[0].Tag("appsec.blocked"))
+			} else {
+				require.NotContains(t, spans[0].Tags(), "_dd.appsec.json")
+			}
+		})
+	}
+}
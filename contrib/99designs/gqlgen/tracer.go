@@ -53,6 +53,8 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
 
@@ -157,8 +159,22 @@ func (t *gqlTracer) InterceptResponse(ctx context.Context, next graphql.Response
 	return next(ctx)
 }
 
+// InterceptField monitors the arguments of the field resolvers when AppSec is
+// enabled, and interrupts them when the request must be blocked.
+func (t *gqlTracer) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	if appsec.Enabled() {
+		if fc := graphql.GetFieldContext(ctx); fc != nil && len(fc.Args) > 0 {
+			if err := graphqlsec.MonitorResolver(ctx, fc.Object, fc.Field.Name, fc.Args); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return next(ctx)
+}
+
 // Ensure all of these interfaces are implemented.
 var _ interface {
 	graphql.HandlerExtension
 	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
 } = &gqlTracer{}
@@ -0,0 +1,172 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sharedsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sqlsec"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the SQL injection exploits are blocked before being executed by using custom rules
+func TestAppSec(t *testing.T) {
+	t.Setenv("DD_APPSEC_RULES", "../../../internal/appsec/testdata/blocking.json")
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	d := &internal.MockDriver{}
+	Register("test", d)
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var queryErr error
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.QueryContext(r.Context(), "SELECT * FROM users WHERE name = '"+r.URL.Query().Get("name")+"'")
+		if queryErr = err; err != nil {
+			return
+		}
+		rows.Close()
+		w.Write([]byte("Hello World!\n"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name        string
+		param       string
+		shouldBlock bool
+	}{
+		{
+			name:  "no-block",
+			param: "john",
+		},
+		{
+			name:        "block",
+			param:       "' OR 1=1 --",
+			shouldBlock: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+			d.Executed = nil
+
+			res, err := srv.Client().Get(srv.URL + "/?name=" + url.QueryEscape(tc.param))
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var querySpan mocktracer.Span
+			for _, s := range mt.FinishedSpans() {
+				if s.OperationName() == "test.query" {
+					querySpan = s
+				}
+			}
+			require.NotNil(t, querySpan)
+			if !tc.shouldBlock {
+				require.Equal(t, http.StatusOK, res.StatusCode)
+				require.NoError(t, queryErr)
+				require.Len(t, d.Executed, 1)
+				return
+			}
+			require.Equal(t, http.StatusForbidden, res.StatusCode)
+			var exploitErr *sharedsec.ExploitPreventionError
+			require.True(t, errors.As(queryErr, &exploitErr))
+			require.Empty(t, d.Executed)
+			require.Equal(t, queryErr, querySpan.Tag(ext.Error))
+		})
+	}
+}
+
+// prepareOnlyDriver is a driver whose connections don't implement the
+// ExecerContext and QueryerContext interfaces, so that database/sql prepares
+// the statement of every query.
+type prepareOnlyDriver struct {
+	internal.MockDriver
+}
+
+func (d *prepareOnlyDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.MockDriver.Open(name)
+	return struct{ driver.Conn }{c}, err
+}
+
+// Test that the queries are protected once when database/sql falls back to
+// prepared statements
+func TestAppSecPrepared(t *testing.T) {
+	t.Setenv("DD_APPSEC_RULES", "../../../internal/appsec/testdata/blocking.json")
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	var protected int32
+	defer dyngo.Register(sqlsec.OnSQLOperationStart(func(*sqlsec.SQLOperation, sqlsec.SQLOperationArgs) {
+		atomic.AddInt32(&protected, 1)
+	}))()
+
+	d := &prepareOnlyDriver{}
+	Register("test-prepare", d)
+	defer unregister("test-prepare")
+	db, err := Open("test-prepare", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var queryErr error
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.QueryContext(r.Context(), "SELECT * FROM users WHERE name = '"+r.URL.Query().Get("name")+"'")
+		if queryErr = err; err == nil {
+			rows.Close()
+		}
+	})
+	mux.HandleFunc("/exec", func(w http.ResponseWriter, r *http.Request) {
+		_, queryErr = db.ExecContext(r.Context(), "DELETE FROM users WHERE name = '"+r.URL.Query().Get("name")+"'")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, name := range []string{"query", "exec"} {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&protected, 0)
+			d.Prepared = nil
+			res, err := srv.Client().Get(srv.URL + "/" + name + "?name=john")
+			require.NoError(t, err)
+			res.Body.Close()
+			require.NoError(t, queryErr)
+			require.Len(t, d.Prepared, 1)
+			require.Equal(t, int32(1), atomic.LoadInt32(&protected))
+
+			res, err = srv.Client().Get(srv.URL + "/" + name + "?name=" + url.QueryEscape("' OR 1=1 --"))
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, http.StatusForbidden, res.StatusCode)
+			var exploitErr *sharedsec.ExploitPreventionError
+			require.True(t, errors.As(queryErr, &exploitErr))
+			require.Len(t, d.Prepared, 1)
+		})
+	}
+}
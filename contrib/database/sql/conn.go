@@ -15,6 +15,8 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sqlsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
 
@@ -87,6 +89,10 @@ func (tc *TracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx dr
 // execution of the statement.
 func (tc *TracedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
 	start := time.Now()
+	if err := tc.protect(ctx, query); err != nil {
+		tc.tryTrace(ctx, queryTypePrepare, query, start, err)
+		return nil, err
+	}
 	mode := tc.cfg.dbmPropagationMode
 	if mode == tracer.DBMPropagationModeFull {
 		// no context other than service in prepared statements
@@ -114,6 +120,10 @@ func (tc *TracedConn) PrepareContext(ctx context.Context, query string) (stmt dr
 func (tc *TracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (r driver.Result, err error) {
 	start := time.Now()
 	if execContext, ok := tc.Conn.(driver.ExecerContext); ok {
+		if err := tc.protect(ctx, query); err != nil {
+			tc.tryTrace(ctx, queryTypeExec, query, start, err)
+			return nil, err
+		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		r, err := execContext.ExecContext(ctx, cquery, args)
 		tc.tryTrace(ctx, queryTypeExec, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
@@ -129,6 +139,10 @@ func (tc *TracedConn) ExecContext(ctx context.Context, query string, args []driv
 			return nil, ctx.Err()
 		default:
 		}
+		if err := tc.protect(ctx, query); err != nil {
+			tc.tryTrace(ctx, queryTypeExec, query, start, err)
+			return nil, err
+		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		r, err = execer.Exec(cquery, dargs)
 		tc.tryTrace(ctx, queryTypeExec, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
@@ -152,6 +166,10 @@ func (tc *TracedConn) Ping(ctx context.Context) (err error) {
 func (tc *TracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
 	start := time.Now()
 	if queryerContext, ok := tc.Conn.(driver.QueryerContext); ok {
+		if err := tc.protect(ctx, query); err != nil {
+			tc.tryTrace(ctx, queryTypeQuery, query, start, err)
+			return nil, err
+		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		rows, err := queryerContext.QueryContext(ctx, cquery, args)
 		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
@@ -167,6 +185,10 @@ func (tc *TracedConn) QueryContext(ctx context.Context, query string, args []dri
 			return nil, ctx.Err()
 		default:
 		}
+		if err := tc.protect(ctx, query); err != nil {
+			tc.tryTrace(ctx, queryTypeQuery, query, start, err)
+			return nil, err
+		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		rows, err = queryer.Query(cquery, dargs)
 		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
@@ -203,6 +225,19 @@ type traceParams struct {
 	meta       map[string]string
 }
 
+// protect runs the AppSec exploit prevention on the given query when AppSec is enabled. An error is returned when the
+// query must be blocked.
+func (tp *traceParams) protect(ctx context.Context, query string) error {
+	if !appsec.Enabled() {
+		return nil
+	}
+	system, ok := tp.meta[ext.DBSystem]
+	if !ok {
+		system = ext.DBSystemOtherSQL
+	}
+	return sqlsec.ProtectSQLOperation(ctx, query, system)
+}
+
 type contextKey int
 
 const spanTagsKey contextKey = 0 // map[string]string
@@ -6,6 +6,9 @@
 package gin
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -26,7 +29,91 @@ func useAppSec(c *gin.Context, span tracer.Span) {
 	}
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Request = r
+		// Write the response through the AppSec response writer so that it
+		// can be monitored and blocked once the handlers return.
+		writer := c.Writer
+		c.Writer = &responseWriter{ResponseWriter: w, gin: writer, size: noWritten, status: http.StatusOK}
+		defer func() { c.Writer = writer }()
 		c.Next()
 	})
 	httpsec.WrapHandler(h, span, params).ServeHTTP(c.Writer, c.Request)
 }
+
+const noWritten = -1
+
+// responseWriter is the gin.ResponseWriter of the handlers when AppSec is
+// enabled. It writes the response to the AppSec response writer given by
+// httpsec.WrapHandler, and tracks the status code and size of the response
+// the same way gin does.
+type responseWriter struct {
+	http.ResponseWriter
+	// gin is the gin response writer the AppSec response writer eventually
+	// writes the response to.
+	gin    gin.ResponseWriter
+	size   int
+	status int
+}
+
+var _ gin.ResponseWriter = (*responseWriter)(nil)
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = w.ResponseWriter.Write(data)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) WriteString(s string) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.size < 0 {
+		w.size = 0
+	}
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w *responseWriter) CloseNotify() <-chan bool {
+	return w.gin.CloseNotify()
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) Pusher() http.Pusher {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p
+	}
+	return nil
+}
@@ -381,6 +381,13 @@ func TestBlocking(t *testing.T) {
 	r.Any("/", func(c *gin.Context) {
 		c.String(200, "Hello World!\n")
 	})
+	r.Any("/response", func(c *gin.Context) {
+		c.Header("X-Test-Response", c.Query("value"))
+		c.String(200, "Hello World!\n")
+	})
+	r.Any("/response-body", func(c *gin.Context) {
+		c.JSON(200, c.Query("value"))
+	})
 	srv := httptest.NewServer(r)
 	defer srv.Close()
 
@@ -428,4 +435,43 @@ func TestBlocking(t *testing.T) {
 
 		}
 	})
+	t.Run("block-response", func(t *testing.T) {
+		for _, tc := range []struct {
+			name        string
+			endpoint    string
+			value       string
+			want        string
+			shouldBlock bool
+		}{
+			{name: "header/block", endpoint: "/response", value: "blocked-response", shouldBlock: true},
+			{name: "header/no-block", endpoint: "/response", value: "legit-response", want: "Hello World!\n"},
+			{name: "body/block", endpoint: "/response-body", value: "blocked-response", shouldBlock: true},
+			{name: "body/no-block", endpoint: "/response-body", value: "legit-response", want: `"legit-response"`},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				mt := mocktracer.Start()
+				defer mt.Stop()
+
+				res, err := srv.Client().Get(srv.URL + tc.endpoint + "?value=" + tc.value)
+				require.NoError(t, err)
+				b, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				spans := mt.FinishedSpans()
+				require.Len(t, spans, 1)
+
+				if tc.shouldBlock {
+					// Check that the response was replaced by the blocking response
+					require.Equal(t, http.StatusForbidden, res.StatusCode)
+					require.NotContains(t, string(b), tc.value)
+					require.Empty(t, res.Header.Get("X-Test-Response"))
+					require.Equal(t, true, spans[0].Tag("appsec.blocked"))
+				} else {
+					require.Equal(t, http.StatusOK, res.StatusCode)
+					require.Equal(t, tc.want, string(b))
+					require.NotContains(t, spans[0].Tags(), "appsec.blocked")
+				}
+				require.Equal(t, fmt.Sprintf("%d", res.StatusCode), spans[0].Tag("http.status_code"))
+			})
+		}
+	})
 }
@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package zap provides a log/span correlation core for the go.uber.org/zap package (https://github.com/uber-go/zap).
+package zap
+
+import (
+	"context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/logcorrelation"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const componentName = "go.uber.org/zap"
+
+func init() {
+	telemetry.LoadIntegration(componentName)
+}
+
+// contextKey is the key of the fields returned by Context.
+const contextKey = "dd.context"
+
+// Context returns a field carrying ctx. When logged through a core returned by WrapCore,
+// it is replaced by the correlation fields of the span found in ctx. Other cores ignore it.
+//
+//	logger.Info("message", zaptrace.Context(ctx))
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: contextKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// WrapCore returns a core which adds the dd.service, dd.env, dd.version, dd.trace_id and
+// dd.span_id fields of the span found in the context given using Context, either with
+// the entry or when creating a child logger, before passing entries to c.
+func WrapCore(c zapcore.Core, opts ...Option) zapcore.Core {
+	cfg := new(config)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return &core{Core: c, cfg: cfg}
+}
+
+type core struct {
+	zapcore.Core
+	cfg *config
+	// span is the span given using With, if any.
+	span ddtrace.Span
+}
+
+// With implements zapcore.Core.
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	span, fields := correlate(fields)
+	if span == nil {
+		span = c.span
+	}
+	return &core{Core: c.Core.With(fields), cfg: c.cfg, span: span}
+}
+
+// Check implements zapcore.Core.
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core.
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	span, fields := correlate(fields)
+	if span == nil {
+		span = c.span
+	}
+	if span != nil && c.cfg.spanErrors && ent.Level >= zapcore.ErrorLevel {
+		var err error
+		for _, f := range fields {
+			if f.Type == zapcore.ErrorType {
+				err, _ = f.Interface.(error)
+				break
+			}
+		}
+		logcorrelation.MarkError(span, ent.Message, err)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// correlate replaces the context fields found in fields by the correlation fields of the
+// span they carry, and returns that span.
+func correlate(fields []zapcore.Field) (ddtrace.Span, []zapcore.Field) {
+	var (
+		span ddtrace.Span
+		out  []zapcore.Field
+	)
+	for i, f := range fields {
+		if f.Type != zapcore.SkipType || f.Key != contextKey {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)+4), fields[:i]...)
+		}
+		ctx, _ := f.Interface.(context.Context)
+		s, ok := tracer.SpanFromContext(ctx)
+		if !ok {
+			continue
+		}
+		span = s
+		logcorrelation.FromSpan(s).Each(func(key, value string) {
+			out = append(out, zap.String(key, value))
+		})
+	}
+	if out == nil {
+		return nil, fields
+	}
+	return span, out
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package zap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCore(t *testing.T) {
+	t.Setenv("DD_ENV", "test-env")
+	t.Setenv("DD_VERSION", "1.2.3")
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(WrapCore(obs))
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+	defer span.Finish()
+	want := map[string]interface{}{
+		"dd.env":      "test-env",
+		"dd.version":  "1.2.3",
+		"dd.trace_id": fmt.Sprint(span.Context().TraceID()),
+		"dd.span_id":  fmt.Sprint(span.Context().SpanID()),
+	}
+
+	logger.Info("hello", zap.String("key", "value"), Context(ctx))
+	logger.With(Context(ctx)).Info("child")
+	logger.Debug("disabled", Context(ctx))
+	logger.Info("no context")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+	m := entries[0].ContextMap()
+	assert.Equal(t, "value", m["key"])
+	for k, v := range want {
+		assert.Equal(t, v, m[k], k)
+	}
+	assert.NotContains(t, m, contextKey)
+	m = entries[1].ContextMap()
+	for k, v := range want {
+		assert.Equal(t, v, m[k], k)
+	}
+	assert.NotContains(t, entries[2].ContextMap(), "dd.trace_id")
+}
+
+func TestCoreSpanErrors(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts   []Option
+		fields []zap.Field
+		want   interface{}
+	}{
+		"disabled":    {want: nil},
+		"message":     {opts: []Option{WithSpanErrors(true)}, want: true},
+		"error-field": {opts: []Option{WithSpanErrors(true)}, fields: []zap.Field{zap.Error(errors.New("oops"))}, want: errors.New("oops")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt.Reset()
+			obs, _ := observer.New(zapcore.InfoLevel)
+			logger := zap.New(WrapCore(obs, tt.opts...))
+			span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+			logger.Warn("warning", Context(ctx))
+			logger.With(Context(ctx)).Error("failure", tt.fields...)
+			span.Finish()
+
+			s := mt.FinishedSpans()[0]
+			assert.Equal(t, tt.want, s.Tag(ext.Error))
+			if tt.want == true {
+				assert.Equal(t, "failure", s.Tag(ext.ErrorMsg))
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package fiber
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sharedsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// nextWithAppSec passes the execution down the line while monitoring the
+// request and its response with httpsec.WrapHandler(). Fiber is not based on
+// net/http so that the request is converted into an *http.Request, and the
+// response is handed over to httpsec.WrapHandler() once the handlers
+// returned so that it can be monitored and replaced by a blocking response.
+// The error returned by the handlers is returned as is, to be handled by the
+// fiber error handler, along with whether the request was blocked, in which
+// case the blocking response must not be replaced by the error handler.
+func nextWithAppSec(c *fiber.Ctx, span tracer.Span) (blocked bool, err error) {
+	var r http.Request
+	if err := fasthttpadaptor.ConvertRequest(c.Context(), &r, true); err != nil {
+		log.Debug("contrib/gofiber/fiber.v2: appsec: could not convert the request: %v", err)
+		return false, c.Next()
+	}
+	rw := newResponseWriter(c)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// pass the appsec operation through the request UserContext
+		c.SetUserContext(r.Context())
+		err = c.Next()
+		// Hand the fiber response over to the response writer of httpsec.WrapHandler()
+		res := c.Response()
+		res.Header.VisitAll(func(k, v []byte) {
+			if key := string(k); key != fiber.HeaderContentLength {
+				w.Header().Add(key, string(v))
+			}
+		})
+		rw.handedOver, rw.status = true, res.StatusCode()
+		w.WriteHeader(rw.status)
+		if !res.IsBodyStream() {
+			rw.body = append([]byte(nil), res.Body()...)
+			w.Write(rw.body)
+		}
+	})
+	httpsec.WrapHandler(handler, span, nil).ServeHTTP(rw, r.WithContext(c.UserContext()))
+	return isBlockingError(err) || rw.replaced, err
+}
+
+// isBlockingError returns true when err is returned by the handlers because
+// AppSec blocked the request, in which case the blocking response is written
+// by httpsec.WrapHandler() in place of the fiber error handler.
+func isBlockingError(err error) bool {
+	var (
+		userErr    *sharedsec.UserMonitoringError
+		exploitErr *sharedsec.ExploitPreventionError
+	)
+	return errors.As(err, &userErr) || errors.As(err, &exploitErr)
+}
+
+// responseWriter is an http.ResponseWriter writing into the fiber response.
+// The fiber response headers are replaced by the response writer ones when
+// the response is written, and its body is replaced by the first write.
+type responseWriter struct {
+	c           *fiber.Ctx
+	header      http.Header
+	wroteHeader bool
+	wroteBody   bool
+
+	// handedOver is true once the response of the handlers, made of status
+	// and body, was handed over to httpsec.WrapHandler()
+	handedOver bool
+	status     int
+	body       []byte
+	// replaced is true when httpsec.WrapHandler() wrote another response
+	// than the one of the handlers, i.e. a blocking response
+	replaced bool
+}
+
+func newResponseWriter(c *fiber.Ctx) *responseWriter {
+	return &responseWriter{c: c, header: make(http.Header)}
+}
+
+// Header returns the response headers to write.
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader replaces the fiber response status code and headers.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if w.handedOver && status != w.status {
+		w.replaced = true
+	}
+	w.wroteHeader = true
+	h := &w.c.Response().Header
+	var keys []string
+	h.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	for _, k := range keys {
+		// The content length is managed by fasthttp according to the body
+		if k != fiber.HeaderContentLength {
+			h.Del(k)
+		}
+	}
+	for k, v := range w.header {
+		for _, v := range v {
+			h.Add(k, v)
+		}
+	}
+	w.c.Status(status)
+}
+
+// Write replaces the fiber response body on the first call, and appends to it
+// afterwards.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.handedOver && !bytes.Equal(b, w.body) {
+		w.replaced = true
+	}
+	res := w.c.Response()
+	if !w.wroteBody {
+		w.wroteBody = true
+		res.ResetBody()
+	}
+	res.AppendBody(b)
+	return len(b), nil
+}
+
+// Status returns the status code of the fiber response.
+func (w *responseWriter) Status() int {
+	return w.c.Response().StatusCode()
+}
@@ -0,0 +1,248 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package fiber
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pappsec "gopkg.in/DataDog/dd-trace-go.v1/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppSec(t *testing.T) {
+	appsec.Start()
+	defer appsec.Stop()
+
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+
+	router := fiber.New()
+	router.Use(Middleware())
+	router.Post("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello World!\n")
+	})
+
+	// Test a security scanner attack via the request headers
+	t.Run("headers", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("User-Agent", "Arachni/v1")
+		res, err := router.Test(req)
+		require.NoError(t, err)
+		// Check that the handler was properly called
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "Hello World!\n", string(b))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 1)
+		event := finished[0].Tag("_dd.appsec.json").(string)
+		require.True(t, strings.Contains(event, "server.request.headers.no_cookies"))
+	})
+
+	// Test a PHP injection attack via the request body (according to appsec rule id crs-933-130)
+	t.Run("body", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"key":"$globals"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := router.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 1)
+		event := finished[0].Tag("_dd.appsec.json").(string)
+		require.True(t, strings.Contains(event, "crs-933-130"))
+		require.True(t, strings.Contains(event, "server.request.body"))
+	})
+
+	// Test that the error returned by the handler is handled once by the fiber error handler
+	t.Run("error-handler", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		var handled []error
+		router := fiber.New(fiber.Config{
+			ErrorHandler: func(c *fiber.Ctx, err error) error {
+				handled = append(handled, err)
+				return fiber.DefaultErrorHandler(c, err)
+			},
+		})
+		// the error is returned to the previous middleware
+		var returned error
+		router.Use(func(c *fiber.Ctx) error {
+			returned = c.Next()
+			return returned
+		})
+		router.Use(Middleware())
+		wantErr := fiber.NewError(http.StatusTeapot, "what status code will I yield")
+		router.Post("/error", func(c *fiber.Ctx) error {
+			return wantErr
+		})
+
+		req := httptest.NewRequest("POST", "/error", nil)
+		req.Header.Set("User-Agent", "Arachni/v1")
+		res, err := router.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusTeapot, res.StatusCode)
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "what status code will I yield", string(b))
+		require.Equal(t, []error{wantErr}, handled)
+		require.Equal(t, wantErr, returned)
+
+		finished := mt.FinishedSpans()
+		require.Len(t, finished, 1)
+		require.Equal(t, wantErr, finished[0].Tag(ext.Error))
+		require.Contains(t, finished[0].Tags(), "_dd.appsec.json")
+	})
+}
+
+// Test that IP, user and response blocking work by using custom rules/rules data
+func TestBlocking(t *testing.T) {
+	t.Setenv("DD_APPSEC_RULES", "../../../internal/appsec/testdata/blocking.json")
+
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	router := fiber.New()
+	router.Use(Middleware())
+	router.All("/ip", func(c *fiber.Ctx) error {
+		return c.SendString("Hello World!\n")
+	})
+	router.All("/user", func(c *fiber.Ctx) error {
+		userID := c.Get("user-id")
+		if err := pappsec.SetUser(c.UserContext(), userID); err != nil {
+			return err
+		}
+		return c.SendString("Hello, " + userID)
+	})
+	router.All("/response", func(c *fiber.Ctx) error {
+		c.Set("x-test-response", c.Get("test-response-header"))
+		return c.SendString("Hello World!\n")
+	})
+	router.All("/response-error", func(c *fiber.Ctx) error {
+		c.Set("x-test-response", c.Get("test-response-header"))
+		return fiber.NewError(http.StatusTeapot, "what status code will I yield")
+	})
+
+	for _, tc := range []struct {
+		name        string
+		endpoint    string
+		headers     map[string]string
+		shouldBlock bool
+	}{
+		{
+			name:        "ip/block",
+			endpoint:    "/ip",
+			headers:     map[string]string{"x-forwarded-for": "1.2.3.4"},
+			shouldBlock: true,
+		},
+		{
+			name:     "ip/no-block",
+			endpoint: "/ip",
+			headers:  map[string]string{"x-forwarded-for": "1.2.3.5"},
+		},
+		{
+			name:        "user/block",
+			endpoint:    "/user",
+			headers:     map[string]string{"user-id": "blocked-user-1"},
+			shouldBlock: true,
+		},
+		{
+			name:     "user/no-block",
+			endpoint: "/user",
+			headers:  map[string]string{"user-id": "legit-user-1"},
+		},
+		{
+			name:        "response/block",
+			endpoint:    "/response",
+			headers:     map[string]string{"test-response-header": "blocked-response"},
+			shouldBlock: true,
+		},
+		{
+			name:     "response/no-block",
+			endpoint: "/response",
+			headers:  map[string]string{"test-response-header": "legit-response"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			req := httptest.NewRequest("POST", tc.endpoint, nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			res, err := router.Test(req)
+			require.NoError(t, err)
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+
+			if tc.shouldBlock {
+				require.Equal(t, http.StatusForbidden, res.StatusCode)
+				require.Empty(t, res.Header.Get("x-test-response"))
+				require.Equal(t, spans[0].Tag("appsec.blocked"), true)
+			} else {
+				require.Equal(t, http.StatusOK, res.StatusCode)
+				require.NotContains(t, spans[0].Tags(), "appsec.blocked")
+			}
+			require.Equal(t, spans[0].Tag("http.status_code"), fmt.Sprintf("%d", res.StatusCode))
+		})
+	}
+	// The error returned by the handler must not replace the blocking response
+	t.Run("response-error", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			header string
+			status int
+		}{
+			{name: "block", header: "blocked-response", status: http.StatusForbidden},
+			{name: "no-block", header: "legit-response", status: http.StatusTeapot},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				mt := mocktracer.Start()
+				defer mt.Stop()
+
+				req := httptest.NewRequest("POST", "/response-error", nil)
+				req.Header.Set("test-response-header", tc.header)
+				res, err := router.Test(req)
+				require.NoError(t, err)
+				require.Equal(t, tc.status, res.StatusCode)
+				b, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				if tc.status == http.StatusForbidden {
+					require.NotContains(t, string(b), "what status code will I yield")
+					require.Empty(t, res.Header.Get("x-test-response"))
+				} else {
+					require.Equal(t, "what status code will I yield", string(b))
+				}
+				spans := mt.FinishedSpans()
+				require.Len(t, spans, 1)
+				require.Error(t, spans[0].Tag(ext.Error).(error))
+			})
+		}
+	})
+}
@@ -17,6 +17,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
@@ -65,7 +66,15 @@ func Middleware(opts ...Option) func(c *fiber.Ctx) error {
 		c.SetUserContext(ctx)
 
 		// pass the execution down the line
-		err := c.Next()
+		var (
+			blocked bool
+			err     error
+		)
+		if appsec.Enabled() {
+			blocked, err = nextWithAppSec(c, span)
+		} else {
+			err = c.Next()
+		}
 
 		span.SetTag(ext.ResourceName, cfg.resourceNamer(c))
 
@@ -84,6 +93,10 @@ func Middleware(opts ...Option) func(c *fiber.Ctx) error {
 			// mark 5xx server error
 			span.SetTag(ext.Error, fmt.Errorf("%d: %s", status, http.StatusText(status)))
 		}
+		if blocked {
+			// The blocking response replaces the one of the fiber error handler
+			return nil
+		}
 		return err
 	}
 }
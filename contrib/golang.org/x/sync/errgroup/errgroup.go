@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package errgroup provides a traced version of the golang.org/x/sync/errgroup package (https://pkg.go.dev/golang.org/x/sync/errgroup),
+// which starts a span for each goroutine of a group.
+package errgroup
+
+import (
+	"context"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const componentName = "golang.org/x/sync/errgroup"
+
+func init() {
+	telemetry.LoadIntegration(componentName)
+}
+
+// A Group is a collection of goroutines working on subtasks that are part of the same
+// overall task, each running within its own span. See errgroup.Group.
+//
+// A zero Group is valid, has no limit on the number of active goroutines, and does
+// not cancel on error. The spans of its goroutines are named after the default
+// operation name, and are the roots of their traces.
+type Group struct {
+	once  sync.Once
+	group *errgroup.Group
+	ctx   context.Context // ctx is the context given to WithContext, if any
+	name  string          // name is the operation name of the spans
+	opts  []tracer.GoOption
+}
+
+// WithContext returns a new Group and an associated Context derived from ctx, like
+// errgroup.WithContext. The spans of the goroutines of the group are children of the
+// span found in ctx, if any.
+func WithContext(ctx context.Context, opts ...Option) (*Group, context.Context) {
+	group, ctx := errgroup.WithContext(ctx)
+	g := &Group{group: group, ctx: ctx}
+	g.configure(opts...)
+	return g, ctx
+}
+
+// configure sets the operation name and the options of the spans of g.
+func (g *Group) configure(opts ...Option) {
+	cfg := new(config)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	g.name = cfg.spanName
+	if cfg.linked {
+		g.opts = append(g.opts, tracer.WithLinkedSpan())
+	}
+	if len(cfg.spanOpts) > 0 {
+		g.opts = append(g.opts, tracer.WithGoSpanOptions(cfg.spanOpts...))
+	}
+}
+
+// init initializes the zero Group.
+func (g *Group) init() *errgroup.Group {
+	g.once.Do(func() {
+		if g.group == nil {
+			g.group = new(errgroup.Group)
+			g.configure()
+		}
+	})
+	return g.group
+}
+
+// wrap returns f called within the span of a goroutine of g.
+func (g *Group) wrap(f func() error) func() error {
+	return tracer.Wrap(g.ctx, g.name, func(context.Context) error {
+		return f()
+	}, g.opts...)
+}
+
+// Go calls the given function in a new goroutine, within a span, like
+// errgroup.Group.Go. A panic of f is recorded as the error of the span before
+// re-panicking.
+func (g *Group) Go(f func() error) {
+	g.init().Go(g.wrap(f))
+}
+
+// TryGo calls the given function in a new goroutine, within a span, only if the
+// number of active goroutines in the group is below the configured limit, like
+// errgroup.Group.TryGo.
+func (g *Group) TryGo(f func() error) bool {
+	return g.init().TryGo(g.wrap(f))
+}
+
+// Wait blocks until all function calls from the Go method have returned, then
+// returns the first non-nil error (if any) from them.
+func (g *Group) Wait() error {
+	return g.init().Wait()
+}
+
+// SetLimit limits the number of active goroutines in this group to at most n.
+// A negative value indicates no limit.
+func (g *Group) SetLimit(n int) {
+	g.init().SetLimit(n)
+}
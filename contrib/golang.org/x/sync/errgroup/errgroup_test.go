@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package errgroup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	parent, ctx := tracer.StartSpanFromContext(context.Background(), "parent")
+	g, gctx := WithContext(ctx, WithSpanName("work"), WithSpanOptions(tracer.ResourceName("job")))
+	g.SetLimit(1)
+	g.Go(func() error {
+		return nil
+	})
+	g.Go(func() error {
+		return errors.New("oops")
+	})
+	assert.EqualError(t, g.Wait(), "oops")
+	assert.Error(t, gctx.Err())
+	assert.True(t, g.TryGo(func() error { return nil }))
+	// the first error is kept
+	assert.EqualError(t, g.Wait(), "oops")
+	parent.Finish()
+
+	mocktracer.AssertTrace(t, mt, mocktracer.SpanTree{
+		Name: "parent",
+		Children: []mocktracer.SpanTree{
+			{Name: "work", Tags: map[string]interface{}{ext.Component: componentName, ext.ResourceName: "job"}},
+			{Name: "work", Tags: map[string]interface{}{ext.Error: errors.New("oops")}},
+			{Name: "work"},
+		},
+	})
+}
+
+func TestGroupZero(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var g Group
+	g.Go(func() error { return nil })
+	g.Go(func() error { return errors.New("oops") })
+	assert.EqualError(t, g.Wait(), "oops")
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 2)
+	for _, s := range spans {
+		assert.Equal(t, defaultSpanName, s.OperationName())
+		assert.Equal(t, componentName, s.Tag(ext.Component))
+		assert.Zero(t, s.ParentID())
+	}
+}
+
+func TestGroupLinked(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	parent, ctx := tracer.StartSpanFromContext(context.Background(), "parent")
+	parent.Finish()
+	g, _ := WithContext(ctx, WithSpanName("job"), WithLinkedSpans())
+	g.Go(func() error { return nil })
+	require.NoError(t, g.Wait())
+
+	traces := mocktracer.Traces(mt)
+	require.Len(t, traces, 2)
+	job := traces[1].Span
+	assert.Equal(t, "job", job.OperationName())
+	assert.Zero(t, job.ParentID())
+	assert.NotEqual(t, parent.Context().TraceID(), job.TraceID())
+	assert.NotEmpty(t, job.Tag("_dd.span_links"))
+}
@@ -0,0 +1,35 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package errgroup_test
+
+import (
+	"context"
+	"log"
+
+	errgrouptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/golang.org/x/sync/errgroup"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func Example() {
+	tracer.Start()
+	defer tracer.Stop()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "fetch.all")
+	defer span.Finish()
+
+	g, _ := errgrouptrace.WithContext(ctx, errgrouptrace.WithSpanName("fetch"))
+	for _, url := range []string{"http://example.com/a", "http://example.com/b"} {
+		url := url
+		g.Go(func() error {
+			// runs within a "fetch" span, a child of "fetch.all"
+			log.Printf("fetching %s", url)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package errgroup
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// defaultSpanName is the default operation name of the spans of the goroutines.
+const defaultSpanName = "errgroup.go"
+
+type config struct {
+	spanName string
+	linked   bool
+	spanOpts []ddtrace.StartSpanOption
+}
+
+// Option represents an option that can be passed to WithContext.
+type Option func(*config)
+
+func defaults(cfg *config) {
+	cfg.spanName = defaultSpanName
+	cfg.spanOpts = []ddtrace.StartSpanOption{tracer.Tag(ext.Component, componentName)}
+}
+
+// WithSpanName sets the operation name of the spans of the goroutines. It defaults
+// to "errgroup.go".
+func WithSpanName(name string) Option {
+	return func(cfg *config) {
+		cfg.spanName = name
+	}
+}
+
+// WithLinkedSpans makes the span of each goroutine the root of a new trace, linked to
+// the span found in the context of the group instead of being its child. The links
+// are experimental, see tracer.WithLinkedSpan.
+func WithLinkedSpans() Option {
+	return func(cfg *config) {
+		cfg.linked = true
+	}
+}
+
+// WithSpanOptions appends the given options to the ones used to start the span
+// of each goroutine.
+func WithSpanOptions(opts ...ddtrace.StartSpanOption) Option {
+	return func(cfg *config) {
+		cfg.spanOpts = append(cfg.spanOpts, opts...)
+	}
+}
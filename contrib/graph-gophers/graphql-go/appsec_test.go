@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package graphql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/stretchr/testify/require"
+
+	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+)
+
+type appsecResolver struct{}
+
+func (*appsecResolver) User(args struct{ ID string }) string { return args.ID }
+
+// Test that the resolver arguments are monitored by using custom rules
+func TestAppSec(t *testing.T) {
+	t.Setenv("DD_APPSEC_RULES", "../../../internal/appsec/testdata/blocking.json")
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	s := `
+		schema {
+			query: Query
+		}
+		type Query {
+			user(id: String!): String!
+		}
+	`
+	schema := graphql.MustParseSchema(s, new(appsecResolver), graphql.Tracer(NewTracer()))
+	mux := httptrace.NewServeMux()
+	mux.Handle("/graphql", &relay.Handler{Schema: schema})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{name: "no-block", id: "legit-resolver", status: http.StatusOK},
+		{name: "block", id: "blocked-resolver", status: http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			res, err := http.Post(srv.URL+"/graphql", "application/json", strings.NewReader(`{
+				"query": "query TestQuery($id: String!) { user(id: $id) }",
+				"variables": {"id": "`+tc.id+`"}
+			}`))
+			require.NoError(t, err)
+			require.Equal(t, tc.status, res.StatusCode)
+
+			var root mocktracer.Span
+			for _, span := range mt.FinishedSpans() {
+				if span.ParentID() == 0 {
+					root = span
+				}
+			}
+			require.NotNil(t, root)
+			if tc.status == http.StatusForbidden {
+				require.Contains(t, root.Tag("_dd.appsec.json"), "blk-001-007")
+				require.Equal(t, true, root.Tag("appsec.blocked"))
+			} else {
+				require.NotContains(t, root.Tags(), "_dd.appsec.json")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package logcorrelation provides the fields shared by the log correlation integrations.
+package logcorrelation
+
+import (
+	"encoding/binary"
+	"os"
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+)
+
+// Keys of the correlation fields added to log entries.
+const (
+	KeyService = "dd.service"
+	KeyEnv     = "dd.env"
+	KeyVersion = "dd.version"
+	KeyTraceID = "dd.trace_id"
+	KeySpanID  = "dd.span_id"
+)
+
+// Fields holds the values which correlate a log entry with a span. Empty values
+// should not be added to the log entry.
+type Fields struct {
+	Service string
+	Env     string
+	Version string
+	TraceID string
+	SpanID  string
+}
+
+// FromSpan returns the correlation fields of the given span. The trace ID is the
+// 128-bit hexadecimal ID when DD_TRACE_128_BIT_TRACEID_LOGGING_ENABLED is true and
+// the trace has one, and the decimal lower 64 bits otherwise.
+func FromSpan(s ddtrace.Span) Fields {
+	f := Fields{
+		Service: globalconfig.ServiceName(),
+		Env:     globalconfig.Env(),
+		Version: globalconfig.Version(),
+		SpanID:  strconv.FormatUint(s.Context().SpanID(), 10),
+	}
+	if f.Env == "" {
+		f.Env = os.Getenv("DD_ENV")
+	}
+	if f.Version == "" {
+		f.Version = os.Getenv("DD_VERSION")
+	}
+	f.TraceID = strconv.FormatUint(s.Context().TraceID(), 10)
+	if w3c, ok := s.Context().(ddtrace.SpanContextW3C); ok && internal.BoolEnv("DD_TRACE_128_BIT_TRACEID_LOGGING_ENABLED", false) {
+		id := w3c.TraceID128Bytes()
+		if binary.BigEndian.Uint64(id[:8]) != 0 {
+			f.TraceID = w3c.TraceID128()
+		}
+	}
+	return f
+}
+
+// Each calls fn with the key and value of each of the non-empty fields.
+func (f Fields) Each(fn func(key, value string)) {
+	for _, kv := range [...][2]string{
+		{KeyService, f.Service},
+		{KeyEnv, f.Env},
+		{KeyVersion, f.Version},
+		{KeyTraceID, f.TraceID},
+		{KeySpanID, f.SpanID},
+	} {
+		if kv[1] != "" {
+			fn(kv[0], kv[1])
+		}
+	}
+}
+
+// MarkError marks s as errored because of an error-level log entry with the given
+// message. err is the error attached to the entry, if any.
+func MarkError(s ddtrace.Span, msg string, err error) {
+	if err != nil {
+		s.SetTag(ext.Error, err)
+		return
+	}
+	s.SetTag(ext.Error, true)
+	s.SetTag(ext.ErrorMsg, msg)
+}
@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package logcorrelation
+
+import (
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromSpan(t *testing.T) {
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", "true")
+	tracer.Start(tracer.WithService("svc"), tracer.WithEnv("env"), tracer.WithServiceVersion("1.0"), tracer.WithLogStartup(false))
+	defer tracer.Stop()
+	span := tracer.StartSpan("test", tracer.WithSpanID(1234))
+	defer span.Finish()
+
+	f := FromSpan(span)
+	assert.Equal(t, Fields{Service: "svc", Env: "env", Version: "1.0", TraceID: "1234", SpanID: "1234"}, f)
+
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_LOGGING_ENABLED", "true")
+	f = FromSpan(span)
+	assert.Len(t, f.TraceID, 32)
+	assert.Equal(t, span.Context().(interface{ TraceID128() string }).TraceID128(), f.TraceID)
+
+	var keys []string
+	Fields{Service: "svc", TraceID: "1", SpanID: "1"}.Each(func(k, _ string) { keys = append(keys, k) })
+	assert.Equal(t, []string{KeyService, KeyTraceID, KeySpanID}, keys)
+}
@@ -0,0 +1,215 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package snapshottest provides snapshot (golden file) testing of the traces produced by integrations.
+//
+// The finished traces of a mock tracer are serialized to JSON, with span IDs, trace IDs and timestamps
+// normalized, and compared to a golden file stored in the testdata/snapshots directory of the package
+// under test. A missing golden file fails the test, unless the tests are run with the -update flag, which
+// rewrites the golden files. They must be recorded against the real services of the integration tests:
+//
+//	INTEGRATION=1 go test ./contrib/database/sql -update
+package snapshottest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var update = flag.Bool("update", false, "rewrite the trace snapshots (golden files)")
+
+// Dir is the directory, relative to the package under test, where snapshots are stored.
+const Dir = "testdata/snapshots"
+
+// Span is the serialized form of a span in a snapshot.
+type Span struct {
+	Name     string                 `json:"name"`
+	Service  interface{}            `json:"service,omitempty"`
+	Resource interface{}            `json:"resource,omitempty"`
+	Type     interface{}            `json:"type,omitempty"`
+	TraceID  int                    `json:"trace_id"`
+	SpanID   int                    `json:"span_id"`
+	ParentID int                    `json:"parent_id"`
+	Tags     map[string]interface{} `json:"tags,omitempty"`
+	Children []*Span                `json:"children,omitempty"`
+}
+
+// Option can be passed to Assert to customize snapshots.
+type Option func(*config)
+
+type config struct {
+	name       string
+	ignoreTags map[string]bool
+}
+
+// WithName sets the name of the snapshot. It defaults to the name of the test.
+func WithName(name string) Option {
+	return func(cfg *config) {
+		cfg.name = name
+	}
+}
+
+// IgnoreTags excludes the given tags from the snapshot, typically because their
+// values change from one run to another.
+func IgnoreTags(keys ...string) Option {
+	return func(cfg *config) {
+		for _, k := range keys {
+			cfg.ignoreTags[k] = true
+		}
+	}
+}
+
+// Assert compares the traces finished by mt to the snapshot named after the test.
+// The test fails if the snapshot doesn't exist, unless the -update flag is set, in
+// which case the snapshot is written instead.
+func Assert(t testing.TB, mt mocktracer.Tracer, opts ...Option) {
+	t.Helper()
+	cfg := config{name: t.Name(), ignoreTags: make(map[string]bool)}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	got, err := Marshal(mocktracer.Traces(mt), cfg.ignoreTags)
+	if err != nil {
+		t.Fatalf("snapshottest: %v", err)
+	}
+	path := filepath.Join(Dir, fileName(cfg.name))
+	want, err := os.ReadFile(path)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("snapshottest: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("snapshottest: %v", err)
+		}
+		t.Logf("snapshottest: wrote %s", path)
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshottest: missing snapshot %s (run with -update to create it)", path)
+		return
+	}
+	if err != nil {
+		t.Fatalf("snapshottest: %v", err)
+		return
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(want)),
+		B:        difflib.SplitLines(string(got)),
+		FromFile: path,
+		ToFile:   "actual",
+		Context:  3,
+	})
+	t.Errorf("snapshottest: traces don't match the snapshot (run with -update to rewrite it):\n%s", diff)
+}
+
+// Marshal serializes the given traces to indented JSON. Span and trace IDs are replaced by
+// their order of appearance, timestamps are left out and tags found in ignoreTags are omitted.
+func Marshal(traces []*mocktracer.Trace, ignoreTags map[string]bool) ([]byte, error) {
+	n := normalizer{
+		spanIDs:  make(map[uint64]int),
+		traceIDs: make(map[uint64]int),
+		ignore:   ignoreTags,
+	}
+	spans := make([]*Span, len(traces))
+	for i, tr := range traces {
+		spans[i] = n.span(tr)
+	}
+	b, err := json.MarshalIndent(spans, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// normalizer converts traces to snapshot spans, normalizing IDs.
+type normalizer struct {
+	spanIDs  map[uint64]int
+	traceIDs map[uint64]int
+	ignore   map[string]bool
+}
+
+func (n *normalizer) span(tr *mocktracer.Trace) *Span {
+	s := tr.Span
+	tags := s.Tags()
+	out := &Span{
+		Name:     s.OperationName(),
+		Service:  tags[ext.ServiceName],
+		Resource: tags[ext.ResourceName],
+		Type:     tags[ext.SpanType],
+		TraceID:  n.id(n.traceIDs, s.TraceID()),
+		SpanID:   n.id(n.spanIDs, s.SpanID()),
+		ParentID: n.id(n.spanIDs, s.ParentID()),
+	}
+	for k, v := range tags {
+		if n.ignore[k] {
+			continue
+		}
+		switch k {
+		case ext.ServiceName, ext.ResourceName, ext.SpanType:
+			continue
+		}
+		if out.Tags == nil {
+			out.Tags = make(map[string]interface{}, len(tags))
+		}
+		out.Tags[k] = n.value(v)
+	}
+	for _, c := range tr.Children {
+		out.Children = append(out.Children, n.span(c))
+	}
+	return out
+}
+
+// id returns the normalized form of id, which is its order of appearance. Zero
+// is kept as is, as it means that there is no ID.
+func (n *normalizer) id(ids map[uint64]int, id uint64) int {
+	if id == 0 {
+		return 0
+	}
+	if v, ok := ids[id]; ok {
+		return v
+	}
+	ids[id] = len(ids) + 1
+	return ids[id]
+}
+
+// value returns the JSON-friendly form of a tag value.
+func (n *normalizer) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case string, bool, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		return v
+	case uint64:
+		if id, ok := n.spanIDs[v]; ok {
+			return fmt.Sprintf("<span %d>", id)
+		}
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// fileName returns the name of the snapshot file for the given snapshot name.
+func fileName(name string) string {
+	r := strings.NewReplacer("/", "__", " ", "_", ":", "_", "\\", "_")
+	return r.Replace(name) + ".json"
+}
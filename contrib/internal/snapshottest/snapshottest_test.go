@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package snapshottest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// genSpans generates a trace made of a root span with two children.
+func genSpans(resource string) {
+	root := tracer.StartSpan("http.request", tracer.ServiceName("web"), tracer.ResourceName(resource), tracer.SpanType(ext.SpanTypeWeb))
+	child := tracer.StartSpan("db.query", tracer.ChildOf(root.Context()), tracer.Tag("db.rows", 3))
+	child.Finish(tracer.WithError(errors.New("oops")))
+	tracer.StartSpan("cache.get", tracer.ChildOf(root.Context()), tracer.Tag("random", "changes every time")).Finish()
+	root.Finish()
+}
+
+func TestAssert(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	genSpans("GET /")
+	Assert(t, mt, IgnoreTags("random"))
+}
+
+// recorder records the errors reported by Assert.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+}
+
+func TestAssertMismatch(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	genSpans("GET /changed")
+
+	r := &recorder{TB: t}
+	Assert(r, mt, WithName("TestAssert"), IgnoreTags("random"))
+	require.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], `-    "resource": "GET /",`)
+	assert.Contains(t, r.errors[0], `+    "resource": "GET /changed",`)
+}
+
+func TestAssertCreate(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	genSpans("GET /")
+
+	defer func(old bool) { *update = old }(*update)
+	*update = true
+	name := "TestAssertCreate-tmp"
+	path := filepath.Join(Dir, fileName(name))
+	defer os.Remove(path)
+	Assert(t, mt, WithName(name), IgnoreTags("random"))
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	want, err := os.ReadFile(filepath.Join(Dir, fileName("TestAssert")))
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestAssertMissing(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	genSpans("GET /")
+
+	name := "TestAssertMissing-tmp"
+	path := filepath.Join(Dir, fileName(name))
+	r := &recorder{TB: t}
+	Assert(r, mt, WithName(name))
+	assert.True(t, r.fatal)
+	require.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], "missing snapshot")
+	_, err := os.Stat(path)
+	assert.True(t, errors.Is(err, os.ErrNotExist), "the snapshot must not be written")
+}
+
+func TestMarshalIDs(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	a := tracer.StartSpan("a")
+	tracer.StartSpan("b", tracer.ChildOf(a.Context())).Finish()
+	a.Finish()
+	tracer.StartSpan("c").Finish()
+
+	b, err := Marshal(mocktracer.Traces(mt), nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+	{"name": "a", "resource": "a", "trace_id": 1, "span_id": 1, "parent_id": 0, "children": [
+		{"name": "b", "resource": "b", "trace_id": 1, "span_id": 2, "parent_id": 1}
+	]},
+	{"name": "c", "resource": "c", "trace_id": 2, "span_id": 3, "parent_id": 0}
+]`, string(b))
+}
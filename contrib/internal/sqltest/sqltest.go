@@ -12,6 +12,7 @@ import (
 	"log"
 	"testing"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/snapshottest"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -53,6 +54,8 @@ func Prepare(tableName string) func() {
 }
 
 // RunAll applies a sequence of unit tests to check the correct tracing of sql features.
+// Besides the expected tags, the traces produced by each test are compared to the snapshots
+// stored in the testdata/snapshots directory of the calling package (see package snapshottest).
 func RunAll(t *testing.T, cfg *Config) {
 	cfg.mockTracer = mocktracer.Start()
 	defer cfg.mockTracer.Stop()
@@ -85,6 +88,7 @@ func testConnect(cfg *Config) func(*testing.T) {
 		for k, v := range cfg.ExpectTags {
 			assert.Equal(v, span.Tag(k), "Value mismatch on tag %s", k)
 		}
+		snapshottest.Assert(t, cfg.mockTracer)
 	}
 }
 
@@ -105,6 +109,7 @@ func testPing(cfg *Config) func(*testing.T) {
 		for k, v := range cfg.ExpectTags {
 			assert.Equal(v, span.Tag(k), "Value mismatch on tag %s", k)
 		}
+		snapshottest.Assert(t, cfg.mockTracer)
 	}
 }
 
@@ -148,6 +153,7 @@ func testQuery(cfg *Config) func(*testing.T) {
 		for k, v := range cfg.ExpectTags {
 			assert.Equal(v, querySpan.Tag(k), "Value mismatch on tag %s", k)
 		}
+		snapshottest.Assert(t, cfg.mockTracer)
 	}
 }
 
@@ -191,6 +197,7 @@ func testStatement(cfg *Config) func(*testing.T) {
 		for k, v := range cfg.ExpectTags {
 			assert.Equal(v, span.Tag(k), "Value mismatch on tag %s", k)
 		}
+		snapshottest.Assert(t, cfg.mockTracer)
 	}
 }
 
@@ -226,6 +233,7 @@ func testBeginRollback(cfg *Config) func(*testing.T) {
 		for k, v := range cfg.ExpectTags {
 			assert.Equal(v, span.Tag(k), "Value mismatch on tag %s", k)
 		}
+		snapshottest.Assert(t, cfg.mockTracer)
 	}
 }
 
@@ -291,6 +299,7 @@ func testExec(cfg *Config) func(*testing.T) {
 		for k, v := range cfg.ExpectTags {
 			assert.Equal(v, span.Tag(k), "Value mismatch on tag %s", k)
 		}
+		snapshottest.Assert(t, cfg.mockTracer)
 	}
 }
 
@@ -55,15 +55,21 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// get the resource associated to this request
 	route := req.URL.Path
 	_, ps, _ := r.Router.Lookup(req.Method, route)
+	var params map[string]string
+	if len(ps) > 0 {
+		params = make(map[string]string, len(ps))
+	}
 	for _, param := range ps {
 		route = strings.Replace(route, param.Value, ":"+param.Key, 1)
+		params[param.Key] = param.Value
 	}
 	resource := req.Method + " " + route
 
 	httptrace.TraceAndServe(r.Router, w, req, &httptrace.ServeConfig{
-		Service:  r.config.serviceName,
-		Resource: resource,
-		SpanOpts: r.config.spanOpts,
-		Route:    route,
+		Service:     r.config.serviceName,
+		Resource:    resource,
+		SpanOpts:    r.config.spanOpts,
+		Route:       route,
+		RouteParams: params,
 	})
 }
@@ -6,6 +6,9 @@
 package echo
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -22,8 +25,14 @@ func withAppSec(next echo.HandlerFunc, span tracer.Span) echo.HandlerFunc {
 			params[n] = c.Param(n)
 		}
 		var err error
+		resp := c.Response()
+		writer, committed := resp.Writer, resp.Committed
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			c.SetRequest(r)
+			// Write the echo response through the AppSec response writer so that it can be monitored and blocked
+			// once the handler returns.
+			resp.Writer = w
+			defer func() { resp.Writer = writer }()
 			err = next(c)
 			// If the error is a user monitoring one, it means appsec actions will take care of writing the response
 			// and handling the error. Don't call the echo error handler in this case
@@ -31,27 +40,69 @@ func withAppSec(next echo.HandlerFunc, span tracer.Span) echo.HandlerFunc {
 				c.Error(err)
 			}
 		})
-		// Wrap the echo response to allow monitoring of the response status code in httpsec.WrapHandler()
-		httpsec.WrapHandler(handler, span, params).ServeHTTP(&statusResponseWriter{Response: c.Response()}, c.Request())
+		// The AppSec response writer writes the response, or the blocking response replacing it, to the underlying
+		// response writer: track its status code to report it in the echo response, unless the response was already
+		// committed by a previous middleware.
+		sw := &statusResponseWriter{ResponseWriter: writer, response: resp}
+		httpsec.WrapHandler(handler, span, params).ServeHTTP(sw, c.Request())
+		if sw.status != 0 && !committed {
+			resp.Status = sw.status
+			resp.Committed = true
+		}
 		// If an error occurred, wrap it under an echo.HTTPError. We need to do this so that APM doesn't override
 		// the response code tag with 500 in case it doesn't recognize the error type.
 		if _, ok := err.(*echo.HTTPError); !ok && err != nil {
 			// We call the echo error handlers in our wrapper when an error occurs, so we know that the response
 			// status won't change anymore at this point in the execution
-			err = echo.NewHTTPError(c.Response().Status, err.Error())
+			err = echo.NewHTTPError(resp.Status, err.Error())
 		}
 		return err
 	}
 
 }
 
-// statusResponseWriter wraps an echo response to allow tracking/retrieving its status code through a Status() method
-// without having to rely on the echo error handlers
+// statusResponseWriter wraps the underlying response writer of an echo response to track the status code written to
+// it, and to allow retrieving the status code of the echo response through a Status() method without having to rely
+// on the echo error handlers
 type statusResponseWriter struct {
-	*echo.Response
+	http.ResponseWriter
+	response *echo.Response
+	status   int
+}
+
+// WriteHeader records the written status code
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 status code when no status code was written yet
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying response writer when it is an http.Flusher
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection of the underlying response writer when it is an http.Hijacker
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not implement http.Hijacker")
+	}
+	return h.Hijack()
 }
 
 // Status returns the status code of the response
 func (w *statusResponseWriter) Status() int {
-	return w.Response.Status
+	return w.response.Status
 }
@@ -564,6 +564,10 @@ func TestBlocking(t *testing.T) {
 		}
 		return c.String(http.StatusOK, "Hello, "+userID)
 	})
+	e.Any("/response", func(c echo.Context) error {
+		c.Response().Header().Set("X-Test-Response", c.Request().Header.Get("x-test-value"))
+		return c.String(http.StatusOK, "Hello World!\n")
+	})
 	srv := httptest.NewServer(e)
 	defer srv.Close()
 
@@ -595,6 +599,17 @@ func TestBlocking(t *testing.T) {
 			endpoint: "/user",
 			headers:  map[string]string{"user-id": "legit-user-1"},
 		},
+		{
+			name:        "response/block",
+			endpoint:    "/response",
+			headers:     map[string]string{"x-test-value": "blocked-response"},
+			shouldBlock: true,
+		},
+		{
+			name:     "response/no-block",
+			endpoint: "/response",
+			headers:  map[string]string{"x-test-value": "legit-response"},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			mt := mocktracer.Start()
@@ -610,11 +625,16 @@ func TestBlocking(t *testing.T) {
 			spans := mt.FinishedSpans()
 			require.Len(t, spans, 1)
 
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
 			if tc.shouldBlock {
 				require.Equal(t, http.StatusForbidden, res.StatusCode)
+				require.NotContains(t, string(b), "Hello")
+				require.Empty(t, res.Header.Get("X-Test-Response"))
 				require.Equal(t, spans[0].Tag("appsec.blocked"), true)
 			} else {
 				require.Equal(t, http.StatusOK, res.StatusCode)
+				require.Contains(t, string(b), "Hello")
 				require.NotContains(t, spans[0].Tags(), "appsec.blocked")
 			}
 			require.Equal(t, spans[0].Tag("http.status_code"), fmt.Sprintf("%d", res.StatusCode))
@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build go1.21
+
+package slog
+
+type config struct {
+	spanErrors bool
+}
+
+// Option represents an option that can be passed to NewHandler.
+type Option func(*config)
+
+func defaults(_ *config) {}
+
+// WithSpanErrors sets whether the span found in the context of records logged at
+// the error level or above is marked as errored. The first error attribute of the
+// record, if any, is used as the error of the span. It is disabled by default.
+func WithSpanErrors(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.spanErrors = enabled
+	}
+}
@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build go1.21
+
+// Package slog provides a log/span correlation handler for the log/slog package (https://pkg.go.dev/log/slog).
+package slog
+
+import (
+	"context"
+	"log/slog"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/logcorrelation"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+)
+
+const componentName = "log/slog"
+
+func init() {
+	telemetry.LoadIntegration(componentName)
+}
+
+// NewHandler returns a handler which adds the dd.service, dd.env, dd.version, dd.trace_id
+// and dd.span_id attributes of the span found in the context of each record before
+// passing it to h. The context has to be given to the logger using one of its
+// *Context methods, such as (*slog.Logger).InfoContext.
+func NewHandler(h slog.Handler, opts ...Option) slog.Handler {
+	cfg := new(config)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return &handler{Handler: h, cfg: cfg}
+}
+
+type handler struct {
+	slog.Handler
+	cfg *config
+}
+
+// Handle implements slog.Handler.
+func (h *handler) Handle(ctx context.Context, rec slog.Record) error {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		return h.Handler.Handle(ctx, rec)
+	}
+	if h.cfg.spanErrors && rec.Level >= slog.LevelError {
+		var err error
+		rec.Attrs(func(a slog.Attr) bool {
+			err, _ = a.Value.Any().(error)
+			return err == nil
+		})
+		logcorrelation.MarkError(span, rec.Message, err)
+	}
+	// the record may be shared with other handlers
+	rec = rec.Clone()
+	logcorrelation.FromSpan(span).Each(func(key, value string) {
+		rec.AddAttrs(slog.String(key, value))
+	})
+	return h.Handler.Handle(ctx, rec)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{Handler: h.Handler.WithAttrs(attrs), cfg: h.cfg}
+}
+
+// WithGroup implements slog.Handler.
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), cfg: h.cfg}
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build go1.21
+
+package slog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &m))
+	b.Reset()
+	return m
+}
+
+func TestHandler(t *testing.T) {
+	t.Setenv("DD_ENV", "test-env")
+	t.Setenv("DD_VERSION", "1.2.3")
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+	defer span.Finish()
+
+	logger.InfoContext(ctx, "hello", "key", "value")
+	m := decode(t, &buf)
+	assert.Equal(t, "hello", m["msg"])
+	assert.Equal(t, "value", m["key"])
+	assert.Equal(t, "test-env", m["dd.env"])
+	assert.Equal(t, "1.2.3", m["dd.version"])
+	assert.Equal(t, fmt.Sprint(span.Context().TraceID()), m["dd.trace_id"])
+	assert.Equal(t, fmt.Sprint(span.Context().SpanID()), m["dd.span_id"])
+
+	logger.With("k", "v").InfoContext(ctx, "with attrs")
+	m = decode(t, &buf)
+	assert.Equal(t, "v", m["k"])
+	assert.Equal(t, fmt.Sprint(span.Context().SpanID()), m["dd.span_id"])
+
+	logger.Info("no context")
+	m = decode(t, &buf)
+	assert.NotContains(t, m, "dd.trace_id")
+}
+
+func TestHandler128BitTraceID(t *testing.T) {
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", "true")
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_LOGGING_ENABLED", "true")
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+	defer span.Finish()
+
+	logger.InfoContext(ctx, "hello")
+	m := decode(t, &buf)
+	id := span.Context().(interface{ TraceID128() string }).TraceID128()
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, m["dd.trace_id"])
+}
+
+func TestHandlerSpanErrors(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var buf bytes.Buffer
+	for name, tt := range map[string]struct {
+		opts []Option
+		args []any
+		want interface{}
+	}{
+		"disabled":   {want: nil},
+		"message":    {opts: []Option{WithSpanErrors(true)}, want: true},
+		"error-attr": {opts: []Option{WithSpanErrors(true)}, args: []any{"err", errors.New("oops")}, want: errors.New("oops")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt.Reset()
+			logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil), tt.opts...))
+			span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+			logger.WarnContext(ctx, "warning")
+			logger.ErrorContext(ctx, "failure", tt.args...)
+			span.Finish()
+
+			s := mt.FinishedSpans()[0]
+			assert.Equal(t, tt.want, s.Tag(ext.Error))
+			if tt.want == true {
+				assert.Equal(t, "failure", s.Tag(ext.ErrorMsg))
+			}
+		})
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 )
 
 type roundTripper struct {
@@ -69,6 +71,12 @@ func (rt *roundTripper) RoundTrip(req *http.Request) (res *http.Response, err er
 		// this should never happen
 		fmt.Fprintf(os.Stderr, "contrib/net/http.Roundtrip: failed to inject http headers: %v\n", err)
 	}
+	if appsec.Enabled() {
+		// Run the exploit prevention on the outbound request URL, which is blocked in case of SSRF exploit
+		if err = httpsec.ProtectRoundTrip(ctx, req.URL.String()); err != nil {
+			return nil, err
+		}
+	}
 	res, err = rt.base.RoundTrip(r2)
 	if err != nil {
 		span.SetTag("http.errors", err.Error())
@@ -0,0 +1,24 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package zerolog
+
+type config struct {
+	spanErrors bool
+}
+
+// Option represents an option that can be passed to NewHook.
+type Option func(*config)
+
+func defaults(_ *config) {}
+
+// WithSpanErrors sets whether the span found in the context of events logged at
+// the error level or above is marked as errored, using the message of the event
+// as the error message. It is disabled by default.
+func WithSpanErrors(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.spanErrors = enabled
+	}
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package zerolog provides a log/span correlation hook for the rs/zerolog package (https://github.com/rs/zerolog).
+package zerolog
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/logcorrelation"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+const componentName = "rs/zerolog"
+
+func init() {
+	telemetry.LoadIntegration(componentName)
+}
+
+// NewHook returns a hook which adds the dd.service, dd.env, dd.version, dd.trace_id and
+// dd.span_id fields of the span found in the context of each event. The context has
+// to be given to the event using (*zerolog.Event).Ctx or to the logger using
+// (zerolog.Context).Ctx.
+func NewHook(opts ...Option) zerolog.Hook {
+	cfg := new(config)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return &hook{cfg: cfg}
+}
+
+type hook struct {
+	cfg *config
+}
+
+// Run implements zerolog.Hook.
+func (h *hook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	span, ok := tracer.SpanFromContext(e.GetCtx())
+	if !ok {
+		return
+	}
+	if h.cfg.spanErrors && level >= zerolog.ErrorLevel && level != zerolog.NoLevel && level != zerolog.Disabled {
+		logcorrelation.MarkError(span, msg, nil)
+	}
+	logcorrelation.FromSpan(span).Each(func(key, value string) {
+		e.Str(key, value)
+	})
+}
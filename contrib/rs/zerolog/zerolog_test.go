@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &m))
+	b.Reset()
+	return m
+}
+
+func TestHook(t *testing.T) {
+	t.Setenv("DD_ENV", "test-env")
+	t.Setenv("DD_VERSION", "1.2.3")
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Hook(NewHook())
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+	defer span.Finish()
+	want := map[string]interface{}{
+		"dd.env":      "test-env",
+		"dd.version":  "1.2.3",
+		"dd.trace_id": fmt.Sprint(span.Context().TraceID()),
+		"dd.span_id":  fmt.Sprint(span.Context().SpanID()),
+	}
+
+	logger.Info().Ctx(ctx).Str("key", "value").Msg("hello")
+	m := decode(t, &buf)
+	assert.Equal(t, "value", m["key"])
+	for k, v := range want {
+		assert.Equal(t, v, m[k], k)
+	}
+
+	child := logger.With().Ctx(ctx).Logger()
+	child.Info().Msg("child")
+	m = decode(t, &buf)
+	for k, v := range want {
+		assert.Equal(t, v, m[k], k)
+	}
+
+	logger.Info().Msg("no context")
+	assert.NotContains(t, decode(t, &buf), "dd.trace_id")
+}
+
+func TestHookSpanErrors(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts []Option
+		want interface{}
+	}{
+		"disabled": {want: nil},
+		"enabled":  {opts: []Option{WithSpanErrors(true)}, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt.Reset()
+			var buf bytes.Buffer
+			logger := zerolog.New(&buf).Hook(NewHook(tt.opts...))
+			span, ctx := tracer.StartSpanFromContext(context.Background(), "test")
+			logger.Warn().Ctx(ctx).Msg("warning")
+			logger.Error().Ctx(ctx).Msg("failure")
+			span.Finish()
+
+			s := mt.FinishedSpans()[0]
+			assert.Equal(t, tt.want, s.Tag(ext.Error))
+			if tt.want == true {
+				assert.Equal(t, "failure", s.Tag(ext.ErrorMsg))
+			}
+		})
+	}
+}
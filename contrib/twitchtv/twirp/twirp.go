@@ -16,6 +16,8 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 
@@ -144,6 +146,10 @@ func WrapServer(h http.Handler, opts ...Option) http.Handler {
 		defer span.Finish()
 
 		r = r.WithContext(ctx)
+		if appsec.Enabled() {
+			httpsec.WrapHandler(h, span, nil).ServeHTTP(w, r)
+			return
+		}
 		h.ServeHTTP(w, r)
 	})
 }
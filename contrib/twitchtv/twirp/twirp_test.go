@@ -10,14 +10,18 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/twitchtv/twirp"
 	"github.com/twitchtv/twirp/ctxsetters"
 	"github.com/twitchtv/twirp/example"
@@ -428,3 +432,36 @@ func TestHaberdash(t *testing.T) {
 	assert.Equal(ext.SpanTypeWeb, spans[1].Tag(ext.SpanType))
 	assert.Equal(ext.SpanTypeHTTP, spans[2].Tag(ext.SpanType))
 }
+
+func TestAppSec(t *testing.T) {
+	appsec.Start()
+	defer appsec.Stop()
+
+	if !appsec.Enabled() {
+		t.Skip("appsec disabled")
+	}
+
+	srv := httptest.NewServer(WrapServer(example.NewHaberdasherServer(haberdasher(6), NewServerHooks())))
+	defer srv.Close()
+
+	// Test a PHP injection attack via the JSON request body (according to appsec rule id crs-933-130)
+	t.Run("body", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		req, err := http.NewRequest("POST", srv.URL+example.HaberdasherPathPrefix+"MakeHat", strings.NewReader(`{"inches":6,"name":"$globals"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := srv.Client().Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		// The security event is reported on the twirp.handler span created by WrapServer
+		event, ok := spans[1].Tag("_dd.appsec.json").(string)
+		require.True(t, ok)
+		require.True(t, strings.Contains(event, "crs-933-130"))
+		require.True(t, strings.Contains(event, "server.request.body"))
+	})
+}
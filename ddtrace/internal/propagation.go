@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package internal
+
+import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+
+// PropagatedState holds the parts of a span context which are carried across
+// process boundaries by the propagators of the tracer package.
+type PropagatedState struct {
+	// TraceID holds the 128-bit trace ID, in big endian.
+	TraceID [16]byte
+	// SpanID holds the ID of the span owning the context.
+	SpanID uint64
+	// Priority holds the sampling priority. It is only valid when HasPriority is true.
+	Priority    int
+	HasPriority bool
+	// Origin holds the trace origin (e.g. "synthetics").
+	Origin string
+	// PropagatingTags holds the "_dd.p.*" trace tags, such as the sampling decision maker.
+	PropagatingTags map[string]string
+	// Baggage holds the baggage items.
+	Baggage map[string]string
+}
+
+// SamplingDecision holds the result of applying the tracer's trace sampling rules.
+type SamplingDecision struct {
+	// Priority is the sampling priority chosen for the trace.
+	Priority int
+	// DecisionMaker is the value of the "_dd.p.dm" propagating tag.
+	DecisionMaker string
+	// Metrics holds the sampling metrics that the tracer sets on the root span.
+	Metrics map[string]float64
+}
+
+// Propagator mirrors tracer.Propagator.
+type Propagator interface {
+	Inject(context ddtrace.SpanContext, carrier interface{}) error
+	Extract(carrier interface{}) (ddtrace.SpanContext, error)
+}
+
+// The below functions are registered by the tracer package upon initialization. They allow
+// other tracer implementations, such as the mock tracer, to reuse the tracer's propagators
+// and sampling rules without relying on its internal types.
+var (
+	// ExportSpanContext returns the propagated state of a span context created by the
+	// tracer package. It returns false if ctx was not created by the tracer package.
+	ExportSpanContext func(ctx ddtrace.SpanContext) (PropagatedState, bool)
+
+	// ImportSpanContext returns a tracer span context holding the given state, suitable
+	// for use with the tracer's propagators.
+	ImportSpanContext func(s PropagatedState) ddtrace.SpanContext
+
+	// NewPropagator returns the tracer's default propagator, configured using
+	// the environment (e.g. DD_TRACE_PROPAGATION_STYLE).
+	NewPropagator func() Propagator
+
+	// NewTraceSampler returns a sampler applying the trace sampling rules found in the
+	// environment (DD_TRACE_SAMPLING_RULES, DD_TRACE_SAMPLE_RATE) to a root span having
+	// the given trace ID, service and operation name. The returned function returns false
+	// when no rule applies.
+	NewTraceSampler func() func(traceID uint64, service, name string) (SamplingDecision, bool)
+)
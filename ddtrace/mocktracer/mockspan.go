@@ -12,7 +12,10 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	sharedinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
 
 var _ ddtrace.Span = (*mockspan)(nil)
@@ -72,27 +75,56 @@ func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig)
 		id = nextID()
 	}
 	s.context = &spanContext{spanID: id, traceID: id, span: s}
-	if ctx, ok := cfg.Parent.(*spanContext); ok {
+	ctx, ok := cfg.Parent.(*spanContext)
+	if !ok && cfg.Parent != nil {
+		// the parent was created by the tracer package, e.g. extracted using
+		// one of its propagators
+		if state, ok := internal.ExportSpanContext(cfg.Parent); ok {
+			ctx = newSpanContextFromState(state)
+		}
+	}
+	if ctx != nil {
 		if ctx.span != nil && s.tags[ext.ServiceName] == nil {
 			// if we have a local parent and no service, inherit the parent's
 			s.SetTag(ext.ServiceName, ctx.span.Tag(ext.ServiceName))
 		}
 		if ctx.hasSamplingPriority() {
-			s.SetTag(ext.SamplingPriority, ctx.samplingPriority())
+			// the priority is inherited as is, along with its decision maker,
+			// so bypass SetTag which would consider it a manual decision
+			s.Lock()
+			if s.tags == nil {
+				s.tags = make(map[string]interface{}, 1)
+			}
+			s.tags[ext.SamplingPriority] = ctx.samplingPriority()
+			s.Unlock()
 		}
 		s.parentID = ctx.spanID
-		s.context.priority = ctx.samplingPriority()
-		s.context.hasPriority = ctx.hasSamplingPriority()
+		ctx.RLock()
+		s.context.priority = ctx.priority
+		s.context.hasPriority = ctx.hasPriority
+		s.context.origin = ctx.origin
+		for k, v := range ctx.propagatingTags {
+			s.context.setPropagatingTagLocked(k, v)
+		}
+		ctx.RUnlock()
 		s.context.traceID = ctx.traceID
+		s.context.traceIDUpper = ctx.traceIDUpper
 		s.context.baggage = make(map[string]string, len(ctx.baggage))
 		ctx.ForeachBaggageItem(func(k, v string) bool {
 			s.context.baggage[k] = v
 			return true
 		})
+	} else if sharedinternal.BoolEnv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", false) {
+		// same format as the tracer: <32-bit unix seconds> <32 bits of zero>
+		s.context.traceIDUpper = uint64(uint32(s.startTime.Unix())) << 32
 	}
 	for k, v := range cfg.Tags {
 		s.SetTag(k, v)
 	}
+	if (ctx == nil || ctx.span == nil) && !s.context.hasSamplingPriority() {
+		// local root span with no sampling decision yet
+		t.sample(s)
+	}
 	return s
 }
 
@@ -119,12 +151,21 @@ func (s *mockspan) SetTag(key string, value interface{}) {
 	if s.tags == nil {
 		s.tags = make(map[string]interface{}, 1)
 	}
-	if key == ext.SamplingPriority {
+	switch key {
+	case ext.SamplingPriority:
 		switch p := value.(type) {
 		case int:
-			s.context.setSamplingPriority(p)
+			s.context.setSamplingPriority(p, samplernames.Manual)
 		case float64:
-			s.context.setSamplingPriority(int(p))
+			s.context.setSamplingPriority(int(p), samplernames.Manual)
+		}
+	case ext.ManualKeep:
+		if value == true {
+			s.context.setSamplingPriority(ext.PriorityUserKeep, samplernames.Manual)
+		}
+	case ext.ManualDrop:
+		if value == true {
+			s.context.setSamplingPriority(ext.PriorityUserReject, samplernames.Manual)
 		}
 	}
 	s.tags[key] = value
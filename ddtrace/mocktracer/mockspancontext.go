@@ -6,27 +6,110 @@
 package mocktracer
 
 import (
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
 	"sync"
 	"sync/atomic"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
 
 var _ ddtrace.SpanContext = (*spanContext)(nil)
+var _ ddtrace.SpanContextW3C = (*spanContext)(nil)
+
+const (
+	// keyDecisionMaker is the propagating tag holding the sampling mechanism
+	// which made the sampling decision.
+	keyDecisionMaker = "_dd.p.dm"
+	// keyTraceID128 is the propagating tag holding the upper 64 bits of a
+	// 128-bit trace ID, hex-encoded.
+	keyTraceID128 = "_dd.p.tid"
+)
 
 type spanContext struct {
-	sync.RWMutex // guards below fields
-	baggage      map[string]string
-	priority     int
-	hasPriority  bool
-
-	spanID  uint64
-	traceID uint64
-	span    *mockspan // context owner
+	sync.RWMutex    // guards below fields
+	baggage         map[string]string
+	priority        int
+	hasPriority     bool
+	origin          string
+	propagatingTags map[string]string
+
+	spanID       uint64
+	traceID      uint64
+	traceIDUpper uint64    // upper 64 bits of a 128-bit trace ID, if any
+	span         *mockspan // context owner
+}
+
+// newSpanContextFromState returns a span context which has no owner,
+// holding the given state.
+func newSpanContextFromState(s internal.PropagatedState) *spanContext {
+	sc := &spanContext{
+		spanID:       s.SpanID,
+		traceID:      binary.BigEndian.Uint64(s.TraceID[8:]),
+		traceIDUpper: binary.BigEndian.Uint64(s.TraceID[:8]),
+		priority:     s.Priority,
+		hasPriority:  s.HasPriority,
+		origin:       s.Origin,
+	}
+	for k, v := range s.PropagatingTags {
+		if k == keyTraceID128 {
+			// already part of the trace ID
+			continue
+		}
+		sc.setPropagatingTag(k, v)
+	}
+	for k, v := range s.Baggage {
+		sc.setBaggageItem(k, v)
+	}
+	return sc
+}
+
+// state returns the part of the span context which is propagated across
+// process boundaries.
+func (sc *spanContext) state() internal.PropagatedState {
+	sc.RLock()
+	defer sc.RUnlock()
+	s := internal.PropagatedState{
+		TraceID:     sc.TraceID128Bytes(),
+		SpanID:      sc.spanID,
+		Priority:    sc.priority,
+		HasPriority: sc.hasPriority,
+		Origin:      sc.origin,
+	}
+	if len(sc.propagatingTags) > 0 {
+		s.PropagatingTags = make(map[string]string, len(sc.propagatingTags))
+		for k, v := range sc.propagatingTags {
+			s.PropagatingTags[k] = v
+		}
+	}
+	if len(sc.baggage) > 0 {
+		s.Baggage = make(map[string]string, len(sc.baggage))
+		for k, v := range sc.baggage {
+			s.Baggage[k] = v
+		}
+	}
+	return s
 }
 
 func (sc *spanContext) TraceID() uint64 { return sc.traceID }
 
+// TraceID128 implements ddtrace.SpanContextW3C.
+func (sc *spanContext) TraceID128() string {
+	id := sc.TraceID128Bytes()
+	return hex.EncodeToString(id[:])
+}
+
+// TraceID128Bytes implements ddtrace.SpanContextW3C.
+func (sc *spanContext) TraceID128Bytes() [16]byte {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], sc.traceIDUpper)
+	binary.BigEndian.PutUint64(id[8:], sc.traceID)
+	return id
+}
+
 func (sc *spanContext) SpanID() uint64 { return sc.spanID }
 
 func (sc *spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
@@ -54,11 +137,39 @@ func (sc *spanContext) baggageItem(k string) string {
 	return sc.baggage[k]
 }
 
-func (sc *spanContext) setSamplingPriority(p int) {
+// setSamplingPriority sets the sampling priority of the context, along with the
+// decision maker, the same way the tracer does.
+func (sc *spanContext) setSamplingPriority(p int, sampler samplernames.SamplerName) {
 	sc.Lock()
 	defer sc.Unlock()
 	sc.priority = p
 	sc.hasPriority = true
+	_, ok := sc.propagatingTags[keyDecisionMaker]
+	if p > 0 && !ok && sampler != samplernames.Unknown {
+		sc.setPropagatingTagLocked(keyDecisionMaker, "-"+strconv.Itoa(int(sampler)))
+	}
+	if p <= 0 && ok {
+		delete(sc.propagatingTags, keyDecisionMaker)
+	}
+}
+
+func (sc *spanContext) setPropagatingTag(k, v string) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.setPropagatingTagLocked(k, v)
+}
+
+func (sc *spanContext) setPropagatingTagLocked(k, v string) {
+	if sc.propagatingTags == nil {
+		sc.propagatingTags = make(map[string]string, 1)
+	}
+	sc.propagatingTags[k] = v
+}
+
+func (sc *spanContext) propagatingTag(k string) string {
+	sc.RLock()
+	defer sc.RUnlock()
+	return sc.propagatingTags[k]
 }
 
 func (sc *spanContext) hasSamplingPriority() bool {
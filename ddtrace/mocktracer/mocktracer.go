@@ -10,6 +10,11 @@
 //
 // Simply call "Start" at the beginning of your tests to start and obtain an instance
 // of the mock tracer.
+//
+// The mock tracer honors the trace sampling rules found in the environment. When started
+// with WithTracerPropagation, it also injects and extracts span contexts using the same
+// propagators as the tracer, honoring the propagation styles found in the environment,
+// so that distributed tracing can be tested faithfully.
 package mocktracer
 
 import (
@@ -18,8 +23,10 @@ import (
 	"sync"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
 
 var _ ddtrace.Tracer = (*mocktracer)(nil)
@@ -43,12 +50,27 @@ type Tracer interface {
 	Stop()
 }
 
+// StartOption configures the mock tracer returned by Start.
+type StartOption func(t *mocktracer)
+
+// WithTracerPropagation makes the mock tracer inject and extract span contexts
+// using the tracer's propagators, which honor the propagation styles found in
+// the environment (W3C trace context, B3, ...) and propagate the trace tags and
+// sampling decisions the same way the tracer does. By default, the mock tracer
+// only propagates the Datadog trace ID, parent ID, sampling priority and baggage
+// headers.
+func WithTracerPropagation() StartOption {
+	return func(t *mocktracer) {
+		t.propagator = internal.NewPropagator()
+	}
+}
+
 // Start sets the internal tracer to a mock and returns an interface
 // which allows querying it. Call Start at the beginning of your tests
 // to activate the mock tracer. When your test runs, use the returned
 // interface to query the tracer's state.
-func Start() Tracer {
-	t := newMockTracer()
+func Start(opts ...StartOption) Tracer {
+	t := newMockTracer(opts...)
 	internal.SetGlobalTracer(t)
 	internal.Testing = true
 	return t
@@ -58,11 +80,18 @@ type mocktracer struct {
 	sync.RWMutex  // guards below spans
 	finishedSpans []Span
 	openSpans     map[uint64]Span
+
+	propagator internal.Propagator // the tracer's propagator, nil unless WithTracerPropagation is used
+	sampler    func(traceID uint64, service, name string) (internal.SamplingDecision, bool)
 }
 
-func newMockTracer() *mocktracer {
+func newMockTracer(opts ...StartOption) *mocktracer {
 	var t mocktracer
 	t.openSpans = make(map[uint64]Span)
+	t.sampler = internal.NewTraceSampler()
+	for _, fn := range opts {
+		fn(&t)
+	}
 	return &t
 }
 
@@ -128,11 +157,43 @@ const (
 	baggagePrefix  = tracer.DefaultBaggageHeaderPrefix
 )
 
+// sample applies the trace sampling rules found in the environment to the
+// local root span s, the same way the tracer does.
+func (t *mocktracer) sample(s *mockspan) {
+	sampler := t.sampler
+	if sampler == nil {
+		sampler = internal.NewTraceSampler()
+	}
+	service, _ := s.Tag(ext.ServiceName).(string)
+	d, ok := sampler(s.TraceID(), service, s.OperationName())
+	if !ok {
+		return
+	}
+	for k, v := range d.Metrics {
+		s.SetTag(k, v)
+	}
+	if d.DecisionMaker != "" {
+		s.context.setPropagatingTag(keyDecisionMaker, d.DecisionMaker)
+	}
+	s.context.setSamplingPriority(d.Priority, samplernames.Unknown)
+}
+
 func (t *mocktracer) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
 	reader, ok := carrier.(tracer.TextMapReader)
 	if !ok {
 		return nil, tracer.ErrInvalidCarrier
 	}
+	if t.propagator != nil {
+		ctx, err := t.propagator.Extract(carrier)
+		if err != nil {
+			return nil, err
+		}
+		state, ok := internal.ExportSpanContext(ctx)
+		if !ok {
+			return nil, tracer.ErrSpanContextCorrupted
+		}
+		return newSpanContextFromState(state), nil
+	}
 	var sc spanContext
 	err := reader.ForeachKey(func(key, v string) error {
 		k := strings.ToLower(key)
@@ -181,10 +242,19 @@ func (t *mocktracer) Inject(context ddtrace.SpanContext, carrier interface{}) er
 	if !ok || ctx.traceID == 0 || ctx.spanID == 0 {
 		return tracer.ErrInvalidSpanContext
 	}
+	if t.propagator != nil {
+		state := ctx.state()
+		if !state.HasPriority {
+			// The tracer always takes a sampling decision before propagating a trace,
+			// which keeps it by default. Don't propagate it as dropped.
+			state.Priority, state.HasPriority = ext.PriorityAutoKeep, true
+		}
+		return t.propagator.Inject(internal.ImportSpanContext(state), writer)
+	}
 	writer.Set(traceHeader, strconv.FormatUint(ctx.traceID, 10))
 	writer.Set(spanHeader, strconv.FormatUint(ctx.spanID, 10))
 	if ctx.hasSamplingPriority() {
-		writer.Set(priorityHeader, strconv.Itoa(ctx.priority))
+		writer.Set(priorityHeader, strconv.Itoa(ctx.samplingPriority()))
 	}
 	ctx.ForeachBaggageItem(func(k, v string) bool {
 		writer.Set(baggagePrefix+k, v)
@@ -6,6 +6,8 @@
 package mocktracer
 
 import (
+	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
@@ -267,3 +269,120 @@ func TestTracerExtract(t *testing.T) {
 		assert.Equal("B", got.baggageItem("a"))
 	})
 }
+
+func TestTracerPropagationStyles(t *testing.T) {
+	t.Run("w3c", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PROPAGATION_STYLE", "tracecontext")
+		mt := newMockTracer(WithTracerPropagation())
+		carrier := tracer.TextMapCarrier{
+			"traceparent": "00-00000000000000010000000000000002-0000000000000003-01",
+			"tracestate":  "dd=s:2;t.dm:-4,othervendor=t61rcWkgMzE",
+		}
+		sctx, err := mt.Extract(carrier)
+		assert := assert.New(t)
+		assert.NoError(err)
+		span := mt.StartSpan("child", tracer.ChildOf(sctx))
+		sc := span.Context().(*spanContext)
+		assert.Equal(uint64(2), sc.TraceID())
+		assert.Equal(uint64(1), sc.traceIDUpper)
+		assert.Equal("00000000000000010000000000000002", sc.TraceID128())
+		assert.Equal(uint64(3), span.(*mockspan).ParentID())
+		assert.Equal(2, sc.samplingPriority())
+		assert.Equal("-4", sc.propagatingTag(keyDecisionMaker))
+
+		out := tracer.TextMapCarrier{}
+		assert.NoError(mt.Inject(span.Context(), out))
+		assert.Len(out, 2)
+		assert.Equal(fmt.Sprintf("00-00000000000000010000000000000002-%016x-01", sc.SpanID()), out["traceparent"])
+		assert.Contains(out["tracestate"], "dd=s:2")
+		assert.Contains(out["tracestate"], "t.dm:-4")
+		assert.Contains(out["tracestate"], "othervendor=t61rcWkgMzE")
+	})
+
+	t.Run("datadog", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PROPAGATION_STYLE", "datadog")
+		mt := newMockTracer(WithTracerPropagation())
+		carrier := tracer.TextMapCarrier{
+			traceHeader:      "2",
+			spanHeader:       "3",
+			priorityHeader:   "1",
+			"x-datadog-tags": "_dd.p.dm=-1,_dd.p.tid=0000000000000001",
+		}
+		sctx, err := mt.Extract(carrier)
+		assert := assert.New(t)
+		assert.NoError(err)
+		span := mt.StartSpan("child", tracer.ChildOf(sctx))
+		sc := span.Context().(*spanContext)
+		assert.Equal("00000000000000010000000000000002", sc.TraceID128())
+
+		out := tracer.TextMapCarrier{}
+		assert.NoError(mt.Inject(span.Context(), out))
+		assert.Equal("2", out[traceHeader])
+		assert.Equal("1", out[priorityHeader])
+		assert.Contains(out["x-datadog-tags"], "_dd.p.dm=-1")
+		assert.Contains(out["x-datadog-tags"], "_dd.p.tid=0000000000000001")
+		_, ok := out["traceparent"]
+		assert.False(ok)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PROPAGATION_STYLE", "datadog,tracecontext")
+		mt := newMockTracer()
+		span := mt.StartSpan("op")
+		out := tracer.TextMapCarrier{}
+		assert := assert.New(t)
+		assert.NoError(mt.Inject(span.Context(), out))
+		// Only the Datadog trace and parent IDs, without any sampling decision
+		assert.Equal(tracer.TextMapCarrier{
+			traceHeader: strconv.FormatUint(span.Context().TraceID(), 10),
+			spanHeader:  strconv.FormatUint(span.Context().SpanID(), 10),
+		}, out)
+	})
+}
+
+func TestTracerSamplingRules(t *testing.T) {
+	t.Setenv("DD_TRACE_SAMPLING_RULES", `[{"service": "kept", "sample_rate": 1}, {"service": "dropped", "sample_rate": 0}]`)
+	mt := newMockTracer()
+
+	t.Run("keep", func(t *testing.T) {
+		span := mt.StartSpan("op", tracer.ServiceName("kept"))
+		sc := span.Context().(*spanContext)
+		assert.Equal(t, ext.PriorityUserKeep, sc.samplingPriority())
+		assert.Equal(t, "-3", sc.propagatingTag(keyDecisionMaker))
+		assert.Equal(t, 1.0, span.(*mockspan).Tag("_dd.rule_psr"))
+
+		child := mt.StartSpan("child", tracer.ChildOf(span.Context()))
+		assert.Equal(t, ext.PriorityUserKeep, child.Context().(*spanContext).samplingPriority())
+		assert.Equal(t, "-3", child.Context().(*spanContext).propagatingTag(keyDecisionMaker))
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		span := mt.StartSpan("op", tracer.ServiceName("dropped"))
+		sc := span.Context().(*spanContext)
+		assert.Equal(t, ext.PriorityUserReject, sc.samplingPriority())
+		assert.Equal(t, "", sc.propagatingTag(keyDecisionMaker))
+	})
+
+	t.Run("no-match", func(t *testing.T) {
+		span := mt.StartSpan("op", tracer.ServiceName("other"))
+		assert.False(t, span.Context().(*spanContext).hasSamplingPriority())
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		span := mt.StartSpan("op", tracer.ServiceName("other"))
+		span.SetTag(ext.ManualKeep, true)
+		sc := span.Context().(*spanContext)
+		assert.Equal(t, ext.PriorityUserKeep, sc.samplingPriority())
+		assert.Equal(t, "-4", sc.propagatingTag(keyDecisionMaker))
+	})
+}
+
+func TestTracer128BitTraceID(t *testing.T) {
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", "true")
+	mt := newMockTracer()
+	root := mt.StartSpan("root")
+	child := mt.StartSpan("child", tracer.ChildOf(root.Context()))
+	rc := root.Context().(*spanContext)
+	assert.NotZero(t, rc.traceIDUpper)
+	assert.Equal(t, rc.TraceID128(), child.Context().(*spanContext).TraceID128())
+}
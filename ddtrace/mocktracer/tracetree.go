@@ -0,0 +1,169 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package mocktracer
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// Trace is a tree of finished spans. Its root is the span of the tree which
+// has no finished parent.
+type Trace struct {
+	// Span is the span at this node of the tree.
+	Span Span
+
+	// Children holds the finished direct children of Span, ordered by start time.
+	Children []*Trace
+}
+
+// String implements fmt.Stringer, rendering the tree with one span per line.
+func (tr *Trace) String() string {
+	var sb strings.Builder
+	tr.write(&sb, 0)
+	return sb.String()
+}
+
+func (tr *Trace) write(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%s (resource: %v, id: %d)\n", strings.Repeat("  ", depth), tr.Span.OperationName(), tr.Span.Tag(ext.ResourceName), tr.Span.SpanID())
+	for _, c := range tr.Children {
+		c.write(sb, depth+1)
+	}
+}
+
+// SpanTree describes the expected shape of a trace, to be used with AssertTrace.
+type SpanTree struct {
+	// Name is the expected operation name.
+	Name string
+
+	// Tags holds the tags which the span is expected to have. Tags which are
+	// not listed here are not checked.
+	Tags map[string]interface{}
+
+	// Children holds the expected direct children of the span, in any order.
+	// The span is expected to have exactly this number of children.
+	Children []SpanTree
+}
+
+// Traces returns the spans finished by mt grouped into trees, ordered by the start
+// time of their roots. Spans whose parent has not finished, or belongs to another
+// process, are the roots of their own tree.
+func Traces(mt Tracer) []*Trace {
+	spans := mt.FinishedSpans()
+	nodes := make(map[uint64]*Trace, len(spans))
+	for _, s := range spans {
+		nodes[s.SpanID()] = &Trace{Span: s}
+	}
+	var roots []*Trace
+	for _, s := range spans {
+		n := nodes[s.SpanID()]
+		if p, ok := nodes[s.ParentID()]; ok && s.ParentID() != s.SpanID() {
+			p.Children = append(p.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	for _, n := range nodes {
+		sortTraces(n.Children)
+	}
+	sortTraces(roots)
+	return roots
+}
+
+// sortTraces sorts the given trees by the start time of their root, then by span ID.
+func sortTraces(trs []*Trace) {
+	sort.SliceStable(trs, func(i, j int) bool {
+		si, sj := trs[i].Span, trs[j].Span
+		if si.StartTime().Equal(sj.StartTime()) {
+			return si.SpanID() < sj.SpanID()
+		}
+		return si.StartTime().Before(sj.StartTime())
+	})
+}
+
+// Children returns the direct children of s finished by mt, ordered by start time.
+func Children(mt Tracer, s Span) []Span {
+	var children []*Trace
+	for _, c := range mt.FinishedSpans() {
+		if c.ParentID() == s.SpanID() && c.TraceID() == s.TraceID() && c.SpanID() != s.SpanID() {
+			children = append(children, &Trace{Span: c})
+		}
+	}
+	sortTraces(children)
+	spans := make([]Span, len(children))
+	for i, c := range children {
+		spans[i] = c.Span
+	}
+	return spans
+}
+
+// AssertTrace asserts that one of the traces finished by mt matches want. It
+// reports an error to tb, along with the traces that were found, otherwise.
+func AssertTrace(tb testing.TB, mt Tracer, want SpanTree) bool {
+	tb.Helper()
+	traces := Traces(mt)
+	for _, tr := range traces {
+		if matchTree(tr, want) {
+			return true
+		}
+	}
+	var sb strings.Builder
+	for _, tr := range traces {
+		sb.WriteString(tr.String())
+	}
+	tb.Errorf("no trace matches the expected tree:\n%s\nfinished traces:\n%s", want.String(), sb.String())
+	return false
+}
+
+// String implements fmt.Stringer, rendering the tree with one span per line.
+func (st SpanTree) String() string {
+	var sb strings.Builder
+	st.write(&sb, 0)
+	return sb.String()
+}
+
+func (st SpanTree) write(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%s", strings.Repeat("  ", depth), st.Name)
+	if len(st.Tags) > 0 {
+		fmt.Fprintf(sb, " %v", st.Tags)
+	}
+	sb.WriteByte('\n')
+	for _, c := range st.Children {
+		c.write(sb, depth+1)
+	}
+}
+
+// matchTree reports whether tr matches want. Children are matched regardless
+// of their order.
+func matchTree(tr *Trace, want SpanTree) bool {
+	if tr.Span.OperationName() != want.Name || len(tr.Children) != len(want.Children) {
+		return false
+	}
+	for k, v := range want.Tags {
+		if !reflect.DeepEqual(tr.Span.Tag(k), v) {
+			return false
+		}
+	}
+	used := make([]bool, len(tr.Children))
+	for _, w := range want.Children {
+		var found bool
+		for i, c := range tr.Children {
+			if !used[i] && matchTree(c, w) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
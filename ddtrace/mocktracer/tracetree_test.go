@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package mocktracer
+
+import (
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraces(t *testing.T) {
+	mt := newMockTracer()
+	root := mt.StartSpan("root", tracer.ResourceName("/")).(*mockspan)
+	a := mt.StartSpan("a", tracer.ChildOf(root.Context())).(*mockspan)
+	b := mt.StartSpan("b", tracer.ChildOf(root.Context())).(*mockspan)
+	c := mt.StartSpan("c", tracer.ChildOf(a.Context())).(*mockspan)
+	other := mt.StartSpan("other").(*mockspan)
+	for _, s := range []*mockspan{c, b, a, root, other} {
+		s.Finish()
+	}
+
+	traces := Traces(mt)
+	assert := assert.New(t)
+	assert.Len(traces, 2)
+	assert.Equal(root, traces[0].Span)
+	assert.Len(traces[0].Children, 2)
+	assert.Equal(a, traces[0].Children[0].Span)
+	assert.Equal(c, traces[0].Children[0].Children[0].Span)
+	assert.Equal(b, traces[0].Children[1].Span)
+	assert.Equal(other, traces[1].Span)
+
+	assert.Equal([]Span{a, b}, Children(mt, root))
+	assert.Equal([]Span{c}, Children(mt, a))
+	assert.Empty(Children(mt, c))
+
+	assert.True(AssertTrace(t, mt, SpanTree{
+		Name: "root",
+		Tags: map[string]interface{}{"resource.name": "/"},
+		Children: []SpanTree{
+			{Name: "b"},
+			{Name: "a", Children: []SpanTree{{Name: "c"}}},
+		},
+	}))
+	assert.True(AssertTrace(t, mt, SpanTree{Name: "other"}))
+
+	mockT := &testing.T{}
+	assert.False(AssertTrace(mockT, mt, SpanTree{Name: "root", Children: []SpanTree{{Name: "a"}, {Name: "b"}}}))
+	assert.True(mockT.Failed())
+}
+
+func TestTracesUnfinishedParent(t *testing.T) {
+	mt := newMockTracer()
+	root := mt.StartSpan("root")
+	child := mt.StartSpan("child", tracer.ChildOf(root.Context()))
+	child.Finish()
+
+	traces := Traces(mt)
+	assert.Len(t, traces, 1)
+	assert.Equal(t, "child", traces[0].Span.OperationName())
+}
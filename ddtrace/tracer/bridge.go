@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+func init() {
+	internal.ExportSpanContext = exportSpanContext
+	internal.ImportSpanContext = importSpanContext
+	internal.NewTraceSampler = newEnvTraceSampler
+	internal.NewPropagator = func() internal.Propagator { return newEnvPropagator() }
+}
+
+// exportSpanContext returns the propagated state held by ctx.
+func exportSpanContext(ctx ddtrace.SpanContext) (internal.PropagatedState, bool) {
+	c, ok := ctx.(*spanContext)
+	if !ok {
+		return internal.PropagatedState{}, false
+	}
+	s := internal.PropagatedState{
+		TraceID: c.traceID,
+		SpanID:  c.spanID,
+		Origin:  c.origin,
+	}
+	s.Priority, s.HasPriority = c.samplingPriority()
+	if c.trace != nil {
+		c.trace.mu.RLock()
+		if len(c.trace.propagatingTags) > 0 {
+			s.PropagatingTags = make(map[string]string, len(c.trace.propagatingTags))
+			for k, v := range c.trace.propagatingTags {
+				s.PropagatingTags[k] = v
+			}
+		}
+		c.trace.mu.RUnlock()
+	}
+	c.ForeachBaggageItem(func(k, v string) bool {
+		if s.Baggage == nil {
+			s.Baggage = make(map[string]string, 1)
+		}
+		s.Baggage[k] = v
+		return true
+	})
+	return s, true
+}
+
+// importSpanContext returns a new span context, not attached to any span, holding s.
+func importSpanContext(s internal.PropagatedState) ddtrace.SpanContext {
+	c := &spanContext{
+		traceID: s.TraceID,
+		spanID:  s.SpanID,
+		origin:  s.Origin,
+		trace:   newTrace(),
+		// the state may have changed since it was extracted, so make sure
+		// that it gets fully re-encoded (e.g. tracestate)
+		updated: true,
+	}
+	for k, v := range s.PropagatingTags {
+		c.trace.setPropagatingTag(k, v)
+	}
+	if s.HasPriority {
+		// the decision maker, if any, is part of the propagating tags
+		c.trace.setSamplingPriority(s.Priority, samplernames.Unknown)
+	}
+	for k, v := range s.Baggage {
+		c.setBaggageItem(k, v)
+	}
+	return c
+}
+
+// newEnvTraceSampler returns a function applying the trace sampling rules found in
+// the environment.
+func newEnvTraceSampler() func(traceID uint64, service, name string) (internal.SamplingDecision, bool) {
+	rules, _, err := samplingRulesFromEnv()
+	if err != nil {
+		log.Warn("DIAGNOSTICS Error(s) parsing sampling rules: found errors:%s", err)
+	}
+	rs := newTraceRulesSampler(rules)
+	return func(traceID uint64, service, name string) (internal.SamplingDecision, bool) {
+		s := &span{
+			Name:    name,
+			Service: service,
+			SpanID:  traceID,
+			TraceID: traceID,
+			Meta:    map[string]string{},
+			Metrics: map[string]float64{},
+		}
+		s.context = &spanContext{spanID: traceID, span: s, trace: newTrace()}
+		s.context.traceID.SetLower(traceID)
+		if !rs.apply(s) {
+			return internal.SamplingDecision{}, false
+		}
+		p, _ := s.context.samplingPriority()
+		dm := s.context.trace.propagatingTags[keyDecisionMaker]
+		return internal.SamplingDecision{
+			Priority:      p,
+			DecisionMaker: dm,
+			Metrics:       s.Metrics,
+		}, true
+	}
+}
@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/pprof"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
+
+// keySpanLinks holds the JSON-encoded links of a span to spans of other traces.
+//
+// Experimental: the tracer has no support for span links in its payloads yet, so
+// they are recorded in this tag instead, which may not be understood by the
+// backend and may change.
+const keySpanLinks = "_dd.span_links"
+
+// GoOption is a configuration option for Go and Wrap.
+type GoOption func(*goConfig)
+
+type goConfig struct {
+	linked   bool
+	spanOpts []StartSpanOption
+}
+
+// WithLinkedSpan makes the span of the goroutine the root of a new trace, which is linked
+// to the span found in the context instead of being its child. It is meant for work
+// which outlives the operation that started it, such as fire-and-forget jobs.
+//
+// Experimental: the link is recorded in the _dd.span_links tag of the span, as a JSON
+// array of trace and span IDs, until the tracer supports span links natively. This
+// tag may change or be removed.
+func WithLinkedSpan() GoOption {
+	return func(cfg *goConfig) {
+		cfg.linked = true
+	}
+}
+
+// WithGoSpanOptions sets the options used to start the span of the goroutine.
+func WithGoSpanOptions(opts ...StartSpanOption) GoOption {
+	return func(cfg *goConfig) {
+		cfg.spanOpts = append(cfg.spanOpts, opts...)
+	}
+}
+
+// Go calls fn in a new goroutine, within a span named operationName which is a child of
+// the span found in ctx, if any. The context passed to fn holds the new span, and the
+// pprof labels of ctx and of the span are applied to the goroutine. If fn panics, the
+// panic is recorded as the error of the span, which is finished before re-panicking.
+func Go(ctx context.Context, operationName string, fn func(ctx context.Context), opts ...GoOption) {
+	run := Wrap(ctx, operationName, func(ctx context.Context) error {
+		fn(ctx)
+		return nil
+	}, opts...)
+	go run()
+}
+
+// Wrap returns a function which calls fn within a span named operationName, like Go
+// does, and returns its error. The span is started when the returned function is
+// called, which is meant to happen in another goroutine, such as one of a worker pool
+// or of an errgroup.Group:
+//
+//	g.Go(tracer.Wrap(ctx, "fetch", fetch))
+//
+// A non-nil error returned by fn is set on the span. The pprof labels of the goroutine
+// are cleared when the returned function returns, as the goroutine may be reused.
+func Wrap(ctx context.Context, operationName string, fn func(ctx context.Context) error, opts ...GoOption) func() error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cfg goConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return func() (err error) {
+		// goroutines of a pool don't inherit the labels of the goroutine which submitted the work
+		pprof.SetGoroutineLabels(ctx)
+		defer pprof.SetGoroutineLabels(context.Background())
+
+		span, ctx := startGoSpan(ctx, operationName, &cfg)
+		defer func() {
+			if r := recover(); r != nil {
+				span.Finish(WithError(panicError(r)))
+				panic(r)
+			}
+			span.Finish(WithError(err))
+		}()
+		return fn(ctx)
+	}
+}
+
+// startGoSpan starts the span of a goroutine started with Go or Wrap.
+func startGoSpan(ctx context.Context, operationName string, cfg *goConfig) (Span, context.Context) {
+	parent, ok := SpanFromContext(ctx)
+	if !cfg.linked || !ok {
+		return StartSpanFromContext(ctx, operationName, cfg.spanOpts...)
+	}
+	opts := make([]StartSpanOption, len(cfg.spanOpts), len(cfg.spanOpts)+2)
+	copy(opts, cfg.spanOpts)
+	opts = append(opts, withContext(ctx), Tag(keySpanLinks, spanLinks(parent.Context())))
+	s := StartSpan(operationName, opts...)
+	if sp, ok := s.(*span); ok && sp.pprofCtxActive != nil {
+		ctx = sp.pprofCtxActive
+	}
+	return s, ContextWithSpan(ctx, s)
+}
+
+// spanLink is the serialized form of a link to another span.
+type spanLink struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+// spanLinks returns the value of the keySpanLinks tag of a span linked to sc.
+func spanLinks(sc ddtrace.SpanContext) string {
+	link := spanLink{
+		TraceID: fmt.Sprintf("%032x", sc.TraceID()),
+		SpanID:  fmt.Sprintf("%016x", sc.SpanID()),
+	}
+	if w3c, ok := sc.(ddtrace.SpanContextW3C); ok {
+		link.TraceID = w3c.TraceID128()
+	}
+	b, _ := json.Marshal([]spanLink{link})
+	return string(b)
+}
+
+// panicError returns the error to record on a span for the recovered panic value r.
+func panicError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", r)
+}
@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"strconv"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGo(t *testing.T) {
+	_, transport, flush, stop := startTestTracer(t)
+	defer stop()
+
+	parent, ctx := StartSpanFromContext(context.Background(), "parent")
+	done := make(chan struct{})
+	Go(ctx, "child", func(ctx context.Context) {
+		defer close(done)
+		s, ok := SpanFromContext(ctx)
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, parent.Context().SpanID(), s.(*span).ParentID)
+	})
+	<-done
+	parent.Finish()
+	flush(1)
+
+	spans := transport.Traces()[0]
+	require.Len(t, spans, 2)
+	assert.Equal(t, "parent", spans[0].Name)
+	assert.Equal(t, "child", spans[1].Name)
+	assert.Equal(t, spans[0].SpanID, spans[1].ParentID)
+	assert.Equal(t, spans[0].TraceID, spans[1].TraceID)
+}
+
+func TestWrapError(t *testing.T) {
+	_, transport, flush, stop := startTestTracer(t)
+	defer stop()
+
+	parent, ctx := StartSpanFromContext(context.Background(), "parent")
+	err := Wrap(ctx, "child", func(ctx context.Context) error {
+		return errors.New("oops")
+	}, WithGoSpanOptions(ResourceName("work")))()
+	assert.EqualError(t, err, "oops")
+	parent.Finish()
+	flush(1)
+
+	child := transport.Traces()[0][1]
+	assert.Equal(t, "work", child.Resource)
+	assert.Equal(t, int32(1), child.Error)
+	assert.Equal(t, "oops", child.Meta[ext.ErrorMsg])
+}
+
+func TestWrapPanic(t *testing.T) {
+	_, transport, flush, stop := startTestTracer(t)
+	defer stop()
+
+	run := Wrap(context.Background(), "worker", func(ctx context.Context) error {
+		panic("boom")
+	})
+	assert.PanicsWithValue(t, "boom", func() { run() })
+	flush(1)
+
+	span := transport.Traces()[0][0]
+	assert.Equal(t, int32(1), span.Error)
+	assert.Equal(t, "panic: boom", span.Meta[ext.ErrorMsg])
+	assert.Contains(t, span.Meta[ext.ErrorStack], "TestWrapPanic")
+}
+
+func TestWrapLinked(t *testing.T) {
+	_, transport, flush, stop := startTestTracer(t)
+	defer stop()
+
+	parent, ctx := StartSpanFromContext(context.Background(), "parent")
+	parent.Finish()
+	Wrap(ctx, "job", func(ctx context.Context) error { return nil }, WithLinkedSpan())()
+	flush(2)
+
+	traces := transport.Traces()
+	require.Len(t, traces, 2)
+	job := traces[1][0]
+	assert.Equal(t, "job", job.Name)
+	assert.Zero(t, job.ParentID)
+	assert.NotEqual(t, traces[0][0].TraceID, job.TraceID)
+	var links []spanLink
+	require.NoError(t, json.Unmarshal([]byte(job.Meta[keySpanLinks]), &links))
+	require.Len(t, links, 1)
+	assert.Equal(t, fmt.Sprintf("%016x", parent.Context().SpanID()), links[0].SpanID)
+	assert.Equal(t, parent.Context().(*spanContext).TraceID128(), links[0].TraceID)
+}
+
+func TestWrapPprofLabels(t *testing.T) {
+	_, _, _, stop := startTestTracer(t, WithProfilerCodeHotspots(true))
+	defer stop()
+
+	parent, ctx := StartSpanFromContext(context.Background(), "parent")
+	defer parent.Finish()
+	ctx = pprof.WithLabels(ctx, pprof.Labels("user", "label"))
+	err := Wrap(ctx, "child", func(ctx context.Context) error {
+		span, _ := SpanFromContext(ctx)
+		v, _ := pprof.Label(ctx, traceprof.SpanID)
+		assert.Equal(t, strconv.FormatUint(span.Context().SpanID(), 10), v)
+		v, _ = pprof.Label(ctx, "user")
+		assert.Equal(t, "label", v)
+
+		// the labels are applied to the goroutine itself
+		var buf bytes.Buffer
+		require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+		assert.Contains(t, buf.String(), fmt.Sprintf(`"span id":"%d"`, span.Context().SpanID()))
+		assert.Contains(t, buf.String(), `"user":"label"`)
+		return nil
+	})()
+	assert.NoError(t, err)
+}
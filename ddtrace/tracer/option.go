@@ -115,6 +115,10 @@ type config struct {
 	// runtimeMetrics specifies whether collection of runtime metrics is enabled.
 	runtimeMetrics bool
 
+	// remoteConfigEnabled specifies whether the remote configuration client shared by
+	// the products subscribing to it is started.
+	remoteConfigEnabled bool
+
 	// dogstatsdAddr specifies the address to connect for sending metrics to the
 	// Datadog Agent. If not set, it defaults to "localhost:8125" or to the
 	// combination of the environment variables DD_AGENT_HOST and DD_DOGSTATSD_PORT.
@@ -168,6 +172,24 @@ type StartOption func(*config)
 // maxPropagatedTagsLength limits the size of DD_TRACE_X_DATADOG_TAGS_MAX_LENGTH to prevent HTTP 413 responses.
 const maxPropagatedTagsLength = 512
 
+// newEnvPropagator returns the default propagator, configured using the
+// environment.
+func newEnvPropagator() Propagator {
+	envKey := "DD_TRACE_X_DATADOG_TAGS_MAX_LENGTH"
+	max := internal.IntEnv(envKey, defaultMaxTagsHeaderLen)
+	if max < 0 {
+		log.Warn("Invalid value %d for %s. Setting to 0.", max, envKey)
+		max = 0
+	}
+	if max > maxPropagatedTagsLength {
+		log.Warn("Invalid value %d for %s. Maximum allowed is %d. Setting to %d.", max, envKey, maxPropagatedTagsLength, maxPropagatedTagsLength)
+		max = maxPropagatedTagsLength
+	}
+	return NewPropagator(&PropagatorConfig{
+		MaxTagsHeaderLen: max,
+	})
+}
+
 // newConfig renders the tracer configuration based on defaults, environment variables
 // and passed user opts.
 func newConfig(opts ...StartOption) *config {
@@ -219,6 +241,7 @@ func newConfig(opts ...StartOption) *config {
 	}
 	c.logStartup = internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true)
 	c.runtimeMetrics = internal.BoolEnv("DD_RUNTIME_METRICS_ENABLED", false)
+	c.remoteConfigEnabled = internal.BoolEnv("DD_REMOTE_CONFIGURATION_ENABLED", true)
 	c.debug = internal.BoolEnv("DD_TRACE_DEBUG", false)
 	c.enabled = internal.BoolEnv("DD_TRACE_ENABLED", true)
 	c.profilerEndpoints = internal.BoolEnv(traceprof.EndpointEnvVar, true)
@@ -271,6 +294,8 @@ func newConfig(opts ...StartOption) *config {
 			}
 		}
 	}
+	globalconfig.SetEnv(c.env)
+	globalconfig.SetVersion(c.version)
 	if c.serviceName == "" {
 		if v, ok := c.globalTags["service"]; ok {
 			if s, ok := v.(string); ok {
@@ -285,19 +310,7 @@ func newConfig(opts ...StartOption) *config {
 		c.transport = newHTTPTransport(c.agentURL.String(), c.httpClient)
 	}
 	if c.propagator == nil {
-		envKey := "DD_TRACE_X_DATADOG_TAGS_MAX_LENGTH"
-		max := internal.IntEnv(envKey, defaultMaxTagsHeaderLen)
-		if max < 0 {
-			log.Warn("Invalid value %d for %s. Setting to 0.", max, envKey)
-			max = 0
-		}
-		if max > maxPropagatedTagsLength {
-			log.Warn("Invalid value %d for %s. Maximum allowed is %d. Setting to %d.", max, envKey, maxPropagatedTagsLength, maxPropagatedTagsLength)
-			max = maxPropagatedTagsLength
-		}
-		c.propagator = NewPropagator(&PropagatorConfig{
-			MaxTagsHeaderLen: max,
-		})
+		c.propagator = newEnvPropagator()
 	}
 	if c.logger != nil {
 		log.UseLogger(c.logger)
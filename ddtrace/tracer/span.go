@@ -435,8 +435,25 @@ func (s *span) Finish(opts ...ddtrace.FinishOption) {
 	if s.taskEnd != nil {
 		s.taskEnd()
 	}
+	if s.pprofCtxActive != nil && s.root() == s {
+		// Add the CPU time per request of the endpoint computed by the
+		// profiler, if any.
+		if d, ok := traceprof.EndpointCPUTime(pprofLabel(s.pprofCtxActive, traceprof.TraceEndpoint)); ok {
+			s.SetTag(keyCPUTimePerRequest, float64(d.Nanoseconds()))
+		}
+	}
 	s.finish(t)
 
+	if th := traceprof.SlowSpanThreshold(); th > 0 && s.pprofCtxActive != nil && time.Duration(s.Duration) >= th {
+		// Let the profiler know about the slow span, see profiler's execution
+		// trace flight recorder.
+		traceprof.ReportSlowSpan(traceprof.SlowSpan{
+			SpanID:          pprofLabel(s.pprofCtxActive, traceprof.SpanID),
+			LocalRootSpanID: pprofLabel(s.pprofCtxActive, traceprof.LocalRootSpanID),
+			Endpoint:        pprofLabel(s.pprofCtxActive, traceprof.TraceEndpoint),
+			Duration:        time.Duration(s.Duration),
+		})
+	}
 	if s.pprofCtxRestore != nil {
 		// Restore the labels of the parent span so any CPU samples after this
 		// point are attributed correctly.
@@ -444,6 +461,12 @@ func (s *span) Finish(opts ...ddtrace.FinishOption) {
 	}
 }
 
+// pprofLabel returns the value of the pprof label key in ctx, or "".
+func pprofLabel(ctx context.Context, key string) string {
+	v, _ := pprof.Label(ctx, key)
+	return v
+}
+
 // SetOperationName sets or changes the operation name.
 func (s *span) SetOperationName(operationName string) {
 	s.Lock()
@@ -682,6 +705,9 @@ const (
 	keyTraceID128 = "_dd.p.tid"
 	// keySpanAttributeSchemaVersion holds the selected DD_TRACE_SPAN_ATTRIBUTE_SCHEMA version.
 	keySpanAttributeSchemaVersion = "_dd.trace_span_attribute_schema"
+	// keyCPUTimePerRequest holds the average CPU time, in nanoseconds, of the
+	// requests to the endpoint of a local root span, computed by the profiler.
+	keyCPUTimePerRequest = "_dd.profiling.cpu_time_per_request"
 )
 
 // The following set of tags is used for user monitoring and set through calls to span.SetUser().
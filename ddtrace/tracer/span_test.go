@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -18,6 +19,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
 	"github.com/stretchr/testify/assert"
@@ -206,6 +208,51 @@ func TestSpanFinishWithTime(t *testing.T) {
 	assert.Equal(duration, span.Duration)
 }
 
+func TestSpanFinishSlowSpan(t *testing.T) {
+	_, _, _, stop := startTestTracer(t, WithProfilerCodeHotspots(true), WithProfilerEndpoints(true))
+	defer stop()
+
+	var reported []traceprof.SlowSpan
+	traceprof.SetSlowSpanHandler(time.Second, func(s traceprof.SlowSpan) {
+		reported = append(reported, s)
+	})
+	defer traceprof.SetSlowSpanHandler(0, nil)
+
+	start := time.Now()
+	root := StartSpan("web.request", ResourceName("GET /users"), StartTime(start))
+	child := StartSpan("db.query", ChildOf(root.Context()), StartTime(start))
+	child.Finish(FinishTime(start.Add(time.Millisecond)))
+	root.Finish(FinishTime(start.Add(2 * time.Second)))
+
+	require.Len(t, reported, 1)
+	id := strconv.FormatUint(root.Context().SpanID(), 10)
+	assert.Equal(t, traceprof.SlowSpan{
+		SpanID:          id,
+		LocalRootSpanID: id,
+		Endpoint:        "GET /users",
+		Duration:        2 * time.Second,
+	}, reported[0])
+}
+
+func TestSpanFinishCPUTimePerRequest(t *testing.T) {
+	_, _, _, stop := startTestTracer(t, WithProfilerEndpoints(true))
+	defer stop()
+
+	traceprof.SetEndpointCPUTime(map[string]time.Duration{"GET /users": 3 * time.Millisecond})
+	defer traceprof.SetEndpointCPUTime(nil)
+
+	root := StartSpan("web.request", ResourceName("GET /users")).(*span)
+	child := StartSpan("db.query", ChildOf(root.Context())).(*span)
+	other := StartSpan("web.request", ResourceName("GET /orders")).(*span)
+	child.Finish()
+	root.Finish()
+	other.Finish()
+
+	assert.Equal(t, float64(3*time.Millisecond), root.Metrics[keyCPUTimePerRequest])
+	assert.NotContains(t, child.Metrics, keyCPUTimePerRequest)
+	assert.NotContains(t, other.Metrics, keyCPUTimePerRequest)
+}
+
 func TestSpanFinishWithNegativeDuration(t *testing.T) {
 	assert := assert.New(t)
 	startTime := time.Now()
@@ -0,0 +1,432 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package testagent provides an in-process fake Datadog agent to be used in tests.
+// Unlike the mock tracer, it lets the real tracer run end to end: spans are sampled,
+// encoded, stats are computed and everything is sent over HTTP to the fake agent,
+// which decodes the payloads and makes them available for inspection.
+//
+// The agent runs in memory, without listening on any network interface:
+//
+//	agent := testagent.New()
+//	defer agent.Close()
+//	tracer.Start(agent.StartOptions()...)
+//	defer tracer.Stop()
+//
+//	// ... run the code under test ...
+//
+//	tracer.Flush()
+//	traces := agent.Traces()
+package testagent // import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer/testagent"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/httpmem"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// The endpoints served by the agent.
+const (
+	InfoEndpoint   = "/info"
+	TracesEndpoint = "/v0.4/traces"
+	StatsEndpoint  = "/v0.6/stats"
+	ConfigEndpoint = "/v0.7/config"
+)
+
+// Info is the response of the agent's /info endpoint, which the tracer uses to
+// discover the agent's features.
+type Info struct {
+	Endpoints     []string `json:"endpoints"`
+	ClientDropP0s bool     `json:"client_drop_p0s"`
+	StatsdPort    int      `json:"statsd_port"`
+	FeatureFlags  []string `json:"feature_flags"`
+}
+
+// Span is a span as decoded by the agent.
+type Span struct {
+	Name     string             `json:"name"`
+	Service  string             `json:"service"`
+	Resource string             `json:"resource"`
+	Type     string             `json:"type"`
+	Start    int64              `json:"start"`
+	Duration int64              `json:"duration"`
+	Meta     map[string]string  `json:"meta,omitempty"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+	SpanID   uint64             `json:"span_id"`
+	TraceID  uint64             `json:"trace_id"`
+	ParentID uint64             `json:"parent_id"`
+	Error    int32              `json:"error"`
+}
+
+// Trace is a set of spans sharing the same trace ID, as sent by the tracer.
+type Trace []Span
+
+// TracePayload holds a payload received on the traces endpoint.
+type TracePayload struct {
+	// Header holds the HTTP headers of the request, such as
+	// Datadog-Client-Computed-Stats or Datadog-Client-Dropped-P0-Traces.
+	Header http.Header
+
+	// Traces holds the decoded traces.
+	Traces []Trace
+}
+
+// StatsPayload holds a payload received on the stats endpoint.
+type StatsPayload struct {
+	Hostname string
+	Env      string
+	Version  string
+	Stats    []StatsBucket
+}
+
+// StatsBucket holds the stats computed by the tracer over a period of time.
+type StatsBucket struct {
+	Start    uint64
+	Duration uint64
+	Stats    []GroupedStats
+}
+
+// GroupedStats holds stats aggregated under a set of keys.
+type GroupedStats struct {
+	Service        string
+	Name           string
+	Resource       string
+	HTTPStatusCode uint32
+	Type           string
+	DBType         string
+	Hits           uint64
+	Errors         uint64
+	Duration       uint64
+	OkSummary      []byte
+	ErrorSummary   []byte
+	Synthetics     bool
+	TopLevelHits   uint64
+}
+
+// ConfigRequest holds the parts of a remote configuration request which are
+// relevant to tests.
+type ConfigRequest struct {
+	Client struct {
+		Products     []string `json:"products"`
+		Capabilities []byte   `json:"capabilities"`
+		State        struct {
+			ConfigStates []ConfigState `json:"config_states"`
+			HasError     bool          `json:"has_error"`
+			Error        string        `json:"error"`
+		} `json:"state"`
+		ClientTracer struct {
+			RuntimeID  string `json:"runtime_id"`
+			Service    string `json:"service"`
+			Env        string `json:"env"`
+			AppVersion string `json:"app_version"`
+		} `json:"client_tracer"`
+	} `json:"client"`
+}
+
+// ConfigState holds the state of a remote configuration, as reported by the tracer.
+type ConfigState struct {
+	ID         string `json:"id"`
+	Version    uint64 `json:"version"`
+	Product    string `json:"product"`
+	ApplyState uint64 `json:"apply_state"`
+	ApplyError string `json:"apply_error"`
+}
+
+// Option can be passed to New to configure the agent.
+type Option func(*Agent)
+
+// WithInfo sets the response of the /info endpoint.
+func WithInfo(info Info) Option {
+	return func(a *Agent) {
+		a.info = info
+	}
+}
+
+// WithRates sets the sampling rates returned to the tracer when it sends
+// traces, keyed by "service:<service>,env:<env>".
+func WithRates(rates map[string]float64) Option {
+	return func(a *Agent) {
+		a.rates = rates
+	}
+}
+
+// Agent is an in-process fake Datadog agent. It is safe for concurrent use.
+type Agent struct {
+	server *http.Server
+	client *http.Client
+	notify chan struct{} // notified whenever a payload is received
+
+	mu             sync.Mutex // guards below fields
+	info           Info
+	rates          map[string]float64
+	statusCodes    map[string]int
+	configResponse []byte
+	tracePayloads  []TracePayload
+	statsPayloads  []StatsPayload
+	configRequests []ConfigRequest
+}
+
+// New returns a new fake agent, ready to receive requests. By default, it
+// advertises all the endpoints it serves and supports dropping P0 traces.
+// It should be closed once the test is done.
+func New(opts ...Option) *Agent {
+	a := &Agent{
+		info: Info{
+			Endpoints:     []string{TracesEndpoint, StatsEndpoint, ConfigEndpoint},
+			ClientDropP0s: true,
+		},
+		statusCodes: make(map[string]int),
+		notify:      make(chan struct{}, 1),
+	}
+	for _, fn := range opts {
+		fn(a)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(InfoEndpoint, a.handleInfo)
+	mux.HandleFunc(TracesEndpoint, a.handleTraces)
+	mux.HandleFunc(StatsEndpoint, a.handleStats)
+	mux.HandleFunc(ConfigEndpoint, a.handleConfig)
+	a.server, a.client = httpmem.ServerAndClient(mux)
+	return a
+}
+
+// Client returns an HTTP client connected to the agent.
+func (a *Agent) Client() *http.Client { return a.client }
+
+// StartOptions returns the options which make the tracer send everything
+// to the agent. They should be passed to tracer.Start.
+func (a *Agent) StartOptions() []tracer.StartOption {
+	return []tracer.StartOption{tracer.WithHTTPClient(a.client)}
+}
+
+// Close shuts down the agent.
+func (a *Agent) Close() error {
+	return a.server.Close()
+}
+
+// SetInfo sets the response of the /info endpoint. The tracer only reads it
+// when starting.
+func (a *Agent) SetInfo(info Info) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.info = info
+}
+
+// SetRates sets the sampling rates returned to the tracer when it sends traces,
+// keyed by "service:<service>,env:<env>".
+func (a *Agent) SetRates(rates map[string]float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rates = rates
+}
+
+// SetStatusCode makes the agent answer requests to the given endpoint with the given
+// HTTP status code. Payloads rejected with a status code other than 200 are not recorded.
+// A status code of 0 restores the default behavior.
+func (a *Agent) SetStatusCode(endpoint string, code int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if code == 0 {
+		delete(a.statusCodes, endpoint)
+		return
+	}
+	a.statusCodes[endpoint] = code
+}
+
+// SetConfigResponse sets the body of the responses to remote configuration requests.
+func (a *Agent) SetConfigResponse(body []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configResponse = body
+}
+
+// TracePayloads returns the payloads received on the traces endpoint.
+func (a *Agent) TracePayloads() []TracePayload {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]TracePayload(nil), a.tracePayloads...)
+}
+
+// Traces returns all the traces received so far.
+func (a *Agent) Traces() []Trace {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var traces []Trace
+	for _, p := range a.tracePayloads {
+		traces = append(traces, p.Traces...)
+	}
+	return traces
+}
+
+// Spans returns all the spans received so far.
+func (a *Agent) Spans() []Span {
+	var spans []Span
+	for _, t := range a.Traces() {
+		spans = append(spans, t...)
+	}
+	return spans
+}
+
+// StatsPayloads returns the payloads received on the stats endpoint.
+func (a *Agent) StatsPayloads() []StatsPayload {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]StatsPayload(nil), a.statsPayloads...)
+}
+
+// StatsBuckets returns all the stats buckets received so far.
+func (a *Agent) StatsBuckets() []StatsBucket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var buckets []StatsBucket
+	for _, p := range a.statsPayloads {
+		buckets = append(buckets, p.Stats...)
+	}
+	return buckets
+}
+
+// ConfigRequests returns the remote configuration requests received so far.
+func (a *Agent) ConfigRequests() []ConfigRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ConfigRequest(nil), a.configRequests...)
+}
+
+// Reset discards all the payloads and requests received so far.
+func (a *Agent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tracePayloads = nil
+	a.statsPayloads = nil
+	a.configRequests = nil
+}
+
+// WaitForTraces waits until at least n traces have been received and returns them.
+// It returns an error if ctx is done before that.
+func (a *Agent) WaitForTraces(ctx context.Context, n int) ([]Trace, error) {
+	for {
+		if traces := a.Traces(); len(traces) >= n {
+			return traces, nil
+		}
+		select {
+		case <-a.notify:
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			return a.Traces(), fmt.Errorf("testagent: received %d traces, expected %d: %v", len(a.Traces()), n, ctx.Err())
+		}
+	}
+}
+
+// statusCode returns the status code the given endpoint should answer with.
+func (a *Agent) statusCode(endpoint string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if code, ok := a.statusCodes[endpoint]; ok {
+		return code
+	}
+	return http.StatusOK
+}
+
+// received notifies any waiter that a payload was received.
+func (a *Agent) received() {
+	select {
+	case a.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Agent) handleInfo(w http.ResponseWriter, r *http.Request) {
+	if code := a.statusCode(InfoEndpoint); code != http.StatusOK {
+		w.WriteHeader(code)
+		return
+	}
+	a.mu.Lock()
+	info := a.info
+	a.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func (a *Agent) handleTraces(w http.ResponseWriter, r *http.Request) {
+	if code := a.statusCode(TracesEndpoint); code != http.StatusOK {
+		w.WriteHeader(code)
+		return
+	}
+	var traces []Trace
+	if err := decodeMsgpack(r.Body, &traces); err != nil {
+		http.Error(w, fmt.Sprintf("decoding traces: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	if r.Header.Get("X-Datadog-Trace-Count") != "0" {
+		// empty payloads are only sent by the tracer to check connectivity
+		a.tracePayloads = append(a.tracePayloads, TracePayload{Header: r.Header.Clone(), Traces: traces})
+	}
+	rates := a.rates
+	a.mu.Unlock()
+	a.received()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Rates map[string]float64 `json:"rate_by_service"`
+	}{rates})
+}
+
+func (a *Agent) handleStats(w http.ResponseWriter, r *http.Request) {
+	if code := a.statusCode(StatsEndpoint); code != http.StatusOK {
+		w.WriteHeader(code)
+		return
+	}
+	var p StatsPayload
+	if err := decodeMsgpack(r.Body, &p); err != nil {
+		http.Error(w, fmt.Sprintf("decoding stats: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.statsPayloads = append(a.statsPayloads, p)
+	a.mu.Unlock()
+	a.received()
+}
+
+func (a *Agent) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if code := a.statusCode(ConfigEndpoint); code != http.StatusOK {
+		w.WriteHeader(code)
+		return
+	}
+	var req ConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("decoding config request: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.configRequests = append(a.configRequests, req)
+	body := a.configResponse
+	a.mu.Unlock()
+	a.received()
+	if body == nil {
+		body = []byte(`{}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// decodeMsgpack decodes the msgpack-encoded contents of r into v, going
+// through JSON so that v's field names or tags are used as keys.
+func decodeMsgpack(r io.Reader, v interface{}) error {
+	var js bytes.Buffer
+	if _, err := msgp.CopyToJSON(&js, r); err != nil {
+		return err
+	}
+	return json.Unmarshal(js.Bytes(), v)
+}
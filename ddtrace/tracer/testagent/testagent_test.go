@@ -0,0 +1,160 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package testagent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraces(t *testing.T) {
+	agent := New()
+	defer agent.Close()
+	tracer.Start(append(agent.StartOptions(), tracer.WithService("test-service"), tracer.WithEnv("test"))...)
+	defer tracer.Stop()
+
+	root := tracer.StartSpan("http.request", tracer.ResourceName("GET /"))
+	child := tracer.StartSpan("db.query", tracer.ChildOf(root.Context()))
+	child.Finish()
+	root.Finish()
+	tracer.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	traces, err := agent.WaitForTraces(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	require.Len(t, traces[0], 2)
+
+	assert := assert.New(t)
+	spans := map[string]Span{}
+	for _, s := range traces[0] {
+		spans[s.Name] = s
+	}
+	assert.Equal("test-service", spans["http.request"].Service)
+	assert.Equal("GET /", spans["http.request"].Resource)
+	assert.Equal("test", spans["http.request"].Meta[ext.Environment])
+	assert.Equal(float64(ext.PriorityAutoKeep), spans["http.request"].Metrics["_sampling_priority_v1"])
+	assert.Equal(spans["http.request"].SpanID, spans["db.query"].ParentID)
+	assert.Equal(spans["http.request"].TraceID, spans["db.query"].TraceID)
+	assert.Equal("go", agent.TracePayloads()[0].Header.Get("Datadog-Meta-Lang"))
+	assert.Len(agent.Spans(), 2)
+
+	agent.Reset()
+	assert.Empty(agent.Traces())
+}
+
+func TestRates(t *testing.T) {
+	agent := New(WithRates(map[string]float64{"service:test-service,env:": 0}))
+	defer agent.Close()
+	tracer.Start(append(agent.StartOptions(), tracer.WithService("test-service"))...)
+	defer tracer.Stop()
+
+	// the rates are returned along with the first payload
+	tracer.StartSpan("first").Finish()
+	tracer.Flush()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := agent.WaitForTraces(ctx, 1)
+	require.NoError(t, err)
+	// the tracer reads the rates asynchronously
+	time.Sleep(50 * time.Millisecond)
+
+	span := tracer.StartSpan("second")
+	span.Finish()
+	tracer.Flush()
+	traces, err := agent.WaitForTraces(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, float64(ext.PriorityAutoReject), traces[1][0].Metrics["_sampling_priority_v1"])
+	assert.Equal(t, 0.0, traces[1][0].Metrics["_dd.agent_psr"])
+}
+
+func TestStatusCode(t *testing.T) {
+	agent := New()
+	defer agent.Close()
+	agent.SetStatusCode(TracesEndpoint, http.StatusInternalServerError)
+
+	resp, err := agent.Client().Post("http://agent"+TracesEndpoint, "application/msgpack", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Empty(t, agent.TracePayloads())
+
+	agent.SetStatusCode(InfoEndpoint, http.StatusNotFound)
+	resp, err = agent.Client().Get("http://agent" + InfoEndpoint)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	agent.SetStatusCode(InfoEndpoint, 0)
+	resp, err = agent.Client().Get("http://agent" + InfoEndpoint)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestStats(t *testing.T) {
+	t.Setenv("DD_TRACE_FEATURES", "discovery")
+	agent := New()
+	defer agent.Close()
+	tracer.Start(append(agent.StartOptions(), tracer.WithService("test-service"))...)
+
+	for i := 0; i < 3; i++ {
+		tracer.StartSpan("http.request", tracer.ResourceName("GET /")).Finish()
+	}
+	tracer.Flush()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := agent.WaitForTraces(ctx, 3)
+	require.NoError(t, err)
+	// stats are flushed when stopping
+	tracer.Stop()
+
+	payloads := agent.TracePayloads()
+	require.NotEmpty(t, payloads)
+	assert.Equal(t, "yes", payloads[0].Header.Get("Datadog-Client-Computed-Stats"))
+
+	var hits uint64
+	for _, b := range agent.StatsBuckets() {
+		for _, s := range b.Stats {
+			if s.Service == "test-service" && s.Name == "http.request" && s.Resource == "GET /" {
+				hits += s.Hits
+			}
+		}
+	}
+	assert.Equal(t, uint64(3), hits)
+}
+
+func TestConfig(t *testing.T) {
+	agent := New()
+	defer agent.Close()
+	agent.SetConfigResponse([]byte(`{}`))
+
+	cfg := remoteconfig.DefaultClientConfig()
+	cfg.AgentURL = "http://agent"
+	cfg.HTTP = agent.Client()
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.ServiceName = "test-service"
+	c, err := remoteconfig.NewClient(cfg)
+	require.NoError(t, err)
+	c.RegisterProduct("ASM_FEATURES")
+	c.Start()
+	defer c.Stop()
+
+	require.Eventually(t, func() bool { return len(agent.ConfigRequests()) > 0 }, 5*time.Second, 10*time.Millisecond)
+	req := agent.ConfigRequests()[0]
+	assert.Contains(t, req.Client.Products, "ASM_FEATURES")
+	assert.Equal(t, "test-service", req.Client.ClientTracer.Service)
+}
@@ -135,13 +135,20 @@ func Start(opts ...StartOption) {
 	if t.config.logStartup {
 		logStartup(t)
 	}
-	// Start AppSec with remote configuration
 	cfg := remoteconfig.DefaultClientConfig()
 	cfg.AgentURL = t.config.agentURL.String()
 	cfg.AppVersion = t.config.version
 	cfg.Env = t.config.env
 	cfg.HTTP = t.config.httpClient
 	cfg.ServiceName = t.config.serviceName
+	// Start the remote configuration client shared by the products subscribing to it, such as the profiler. It
+	// only polls the agent once a product is subscribed.
+	if t.config.remoteConfigEnabled {
+		if err := remoteconfig.Start(cfg); err != nil {
+			log.Warn("Remote config: disabled due to a client creation error: %v", err)
+		}
+	}
+	// Start AppSec with remote configuration
 	appsec.Start(appsec.WithRCConfig(cfg))
 	// start instrumentation telemetry unless it is disabled through the
 	// DD_INSTRUMENTATION_TELEMETRY_ENABLED env var
@@ -152,6 +159,7 @@ func Start(opts ...StartOption) {
 // Stop stops the started tracer. Subsequent calls are valid but become no-op.
 func Stop() {
 	internal.SetGlobalTracer(&internal.NoopTracer{})
+	remoteconfig.Stop()
 	log.Flush()
 }
 
@@ -0,0 +1,441 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Command rules-tester replays a corpus of recorded HTTP requests against a
+// security rules file with the WAF, offline, in order to validate custom rules
+// and exclusions before deploying them. The corpus is either a HAR file or a
+// file of JSON lines of the form:
+//
+//	{"method":"GET","url":"http://host/?q=1","headers":{"User-Agent":["curl"]},"body":"","remote_addr":"1.2.3.4:1234","response":{"status":200,"headers":{},"body":""},"expect":["rule-id"]}
+//
+// where every field but the URL is optional. The rule ids expected to match a
+// request are listed in its `expect` field, or in the `_expect` field of its
+// HAR entry, and with the -allow flag for every request. The exit status is 1
+// when a request matches unexpected rules or does not match the expected ones,
+// so that the command can be used in CI:
+//
+//	go run ./internal/appsec/_tools/rules-tester -rules rules.json requests.har
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+
+	waf "github.com/DataDog/go-libddwaf"
+)
+
+// record is a recorded HTTP request, along with its optional response and the
+// ids of the rules expected to match it.
+type record struct {
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+	RemoteAddr string              `json:"remote_addr"`
+	Response   *recordResponse     `json:"response"`
+	Expect     []string            `json:"expect"`
+}
+
+type recordResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+}
+
+// result is the result of the replay of a record.
+type result struct {
+	rules      []string
+	actions    []string
+	duration   time.Duration
+	timeout    bool
+	unexpected []string
+	missing    []string
+}
+
+func (r result) failed() bool {
+	return r.timeout || len(r.unexpected) > 0 || len(r.missing) > 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run runs the command with the given arguments, writing its report to stdout,
+// and returns its exit status: 0 when every request matched the expected
+// rules, 1 when some did not, and 2 when the command could not run.
+func run(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("rules-tester", flag.ContinueOnError)
+	var (
+		rulesFile = fs.String("rules", "", "path to the security rules file (required)")
+		allow     = fs.String("allow", "", "comma-separated list of the rule ids allowed to match any request")
+		timeout   = fs.Duration("timeout", time.Second, "WAF timeout per request")
+		verbose   = fs.Bool("v", false, "print every request, including the ones without any rule match")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rules-tester -rules <rules.json> [flags] <corpus.har|corpus.jsonl>...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *rulesFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	handle, err := newWAFHandle(*rulesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load the rules: %v\n", err)
+		return 2
+	}
+	defer handle.Close()
+	rInfo := handle.RulesetInfo()
+	fmt.Fprintf(stdout, "loaded %d rules (%d failed) of version %q with WAF %s\n", rInfo.Loaded, rInfo.Failed, rInfo.Version, waf.Version())
+	for msg, ids := range rInfo.Errors {
+		fmt.Fprintf(stdout, "  rule error: %s: %v\n", msg, ids)
+	}
+
+	var allowed []string
+	if *allow != "" {
+		allowed = strings.Split(*allow, ",")
+	}
+	var total, matched, failures int
+	for _, path := range fs.Args() {
+		records, err := readCorpus(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read the corpus %s: %v\n", path, err)
+			return 2
+		}
+		for i, rec := range records {
+			expected := append(append([]string(nil), allowed...), rec.Expect...)
+			res, err := replay(handle, rec, expected, *timeout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s:%d: could not replay the request: %v\n", path, i+1, err)
+				return 2
+			}
+			total++
+			if len(res.rules) > 0 {
+				matched++
+			}
+			if res.failed() {
+				failures++
+			}
+			if *verbose || len(res.rules) > 0 || res.failed() {
+				printResult(stdout, fmt.Sprintf("%s:%d", path, i+1), rec, res)
+			}
+		}
+	}
+	fmt.Fprintf(stdout, "%d requests replayed, %d matched, %d failed\n", total, matched, failures)
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// newWAFHandle creates the WAF handle of the given rules file, as done by
+// AppSec, including the obfuscation of the sensitive values of the matches
+// with the default regular expressions, or the ones configured through the
+// environment.
+func newWAFHandle(rulesFile string) (*waf.Handle, error) {
+	if err := waf.Health(); err != nil {
+		return nil, err
+	}
+	rules, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	obfuscator := appsec.ReadObfuscatorConfig()
+	return waf.NewHandle(rules, obfuscator.KeyRegex, obfuscator.ValueRegex)
+}
+
+// replay runs the WAF on the given record, as done by AppSec when monitoring
+// the request and its response, and compares the matching rules with the
+// expected ones.
+func replay(handle *waf.Handle, rec record, expected []string, timeout time.Duration) (result, error) {
+	var res result
+	r, err := rec.request()
+	if err != nil {
+		return res, err
+	}
+	_, clientIP := httpsec.ClientIPTags(r.Header, true, r.RemoteAddr)
+	args := httpsec.MakeHandlerOperationArgs(r, clientIP, nil)
+
+	wafCtx := waf.NewContext(handle)
+	if wafCtx == nil {
+		return res, errors.New("could not create the WAF context")
+	}
+	defer wafCtx.Close()
+
+	values := map[string]interface{}{
+		appsec.ServerRequestRawURIAddr:           args.RequestURI,
+		appsec.ServerRequestHeadersNoCookiesAddr: args.Headers,
+		appsec.ServerRequestQueryAddr:            args.Query,
+	}
+	if args.Cookies != nil {
+		values[appsec.ServerRequestCookiesAddr] = args.Cookies
+	}
+	if args.ClientIP.IsValid() {
+		values[appsec.HTTPClientIPAddr] = args.ClientIP.String()
+	}
+	if body, err := httpsec.ParseBody(r.Header.Get("Content-Type"), []byte(rec.Body)); err == nil && body != nil {
+		values[appsec.ServerRequestBodyAddr] = body
+	}
+	if rec.Response != nil {
+		values[appsec.ServerResponseStatusAddr] = rec.Response.Status
+		if headers := responseHeaders(rec.Response.Headers); len(headers) > 0 {
+			values[appsec.ServerResponseHeadersNoCookiesAddr] = headers
+		}
+		// Only JSON response bodies are monitored by AppSec
+		if contentType := headerValue(rec.Response.Headers, "content-type"); strings.Contains(contentType, "json") {
+			if body, err := httpsec.ParseBody(contentType, []byte(rec.Response.Body)); err == nil && body != nil {
+				values[appsec.ServerResponseBodyAddr] = body
+			}
+		}
+	}
+
+	matches, actions, err := wafCtx.Run(values, timeout)
+	if err != nil {
+		if err != waf.ErrTimeout {
+			return res, err
+		}
+		res.timeout = true
+	}
+	overall, _ := wafCtx.TotalRuntime()
+	res.duration = time.Duration(overall)
+	res.actions = actions
+	if res.rules, err = matchedRules(matches); err != nil {
+		return res, err
+	}
+	res.unexpected = difference(res.rules, expected)
+	res.missing = difference(rec.Expect, res.rules)
+	return res, nil
+}
+
+// request returns the HTTP request of the record, as received by a server.
+func (rec record) request() (*http.Request, error) {
+	method := rec.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequest(method, rec.URL, strings.NewReader(rec.Body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range rec.Headers {
+		for _, v := range v {
+			r.Header.Add(k, v)
+		}
+	}
+	if host := r.Header.Get("Host"); host != "" {
+		r.Host = host
+		r.Header.Del("Host")
+	}
+	r.RequestURI = r.URL.RequestURI()
+	r.RemoteAddr = rec.RemoteAddr
+	return r, nil
+}
+
+// responseHeaders returns the value of the address
+// `server.response.headers.no_cookies` of the given response headers.
+func responseHeaders(h map[string][]string) map[string][]string {
+	headers := make(map[string][]string, len(h))
+	for k, v := range h {
+		if k := strings.ToLower(k); k != "set-cookie" {
+			headers[k] = append(headers[k], v...)
+		}
+	}
+	return headers
+}
+
+// headerValue returns the first value of the given header name, regardless of
+// the case of the header keys.
+func headerValue(h map[string][]string, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// matchedRules returns the sorted ids of the rules of the given WAF matches.
+func matchedRules(matches []byte) ([]string, error) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	var events []struct {
+		Rule struct {
+			ID string `json:"id"`
+		} `json:"rule"`
+	}
+	if err := json.Unmarshal(matches, &events); err != nil {
+		return nil, fmt.Errorf("could not parse the WAF matches: %v", err)
+	}
+	rules := make([]string, 0, len(events))
+	for _, e := range events {
+		rules = append(rules, e.Rule.ID)
+	}
+	sort.Strings(rules)
+	return rules, nil
+}
+
+// difference returns the values of a which are not in b.
+func difference(a, b []string) []string {
+	var diff []string
+	for _, v := range a {
+		found := false
+		for _, w := range b {
+			if v == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
+
+func printResult(w io.Writer, location string, rec record, res result) {
+	status := "ok"
+	if res.failed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "%-4s %s: %s %s (waf %s)\n", status, location, rec.Method, rec.URL, res.duration)
+	if len(res.rules) > 0 {
+		fmt.Fprintf(w, "     rules: %s\n", strings.Join(res.rules, ", "))
+	}
+	if len(res.actions) > 0 {
+		fmt.Fprintf(w, "     actions: %s\n", strings.Join(res.actions, ", "))
+	}
+	if res.timeout {
+		fmt.Fprintf(w, "     WAF timeout reached\n")
+	}
+	if len(res.unexpected) > 0 {
+		fmt.Fprintf(w, "     unexpected rules: %s\n", strings.Join(res.unexpected, ", "))
+	}
+	if len(res.missing) > 0 {
+		fmt.Fprintf(w, "     missing rules: %s\n", strings.Join(res.missing, ", "))
+	}
+}
+
+// readCorpus reads the records of the given HAR or JSON lines file, according
+// to its extension.
+func readCorpus(path string) ([]record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".har") {
+		return parseHAR(data)
+	}
+	return parseJSONLines(data)
+}
+
+func parseJSONLines(data []byte) ([]record, error) {
+	var records []record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// harNameValue is a HAR header.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func parseHAR(data []byte) ([]record, error) {
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					Method   string         `json:"method"`
+					URL      string         `json:"url"`
+					Headers  []harNameValue `json:"headers"`
+					PostData *struct {
+						Text string `json:"text"`
+					} `json:"postData"`
+				} `json:"request"`
+				Response *struct {
+					Status  int            `json:"status"`
+					Headers []harNameValue `json:"headers"`
+					Content struct {
+						Text     string `json:"text"`
+						Encoding string `json:"encoding"`
+					} `json:"content"`
+				} `json:"response"`
+				Expect []string `json:"_expect"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, err
+	}
+	records := make([]record, 0, len(har.Log.Entries))
+	for _, e := range har.Log.Entries {
+		rec := record{
+			Method:  e.Request.Method,
+			URL:     e.Request.URL,
+			Headers: harHeaders(e.Request.Headers),
+			Expect:  e.Expect,
+		}
+		if e.Request.PostData != nil {
+			rec.Body = e.Request.PostData.Text
+		}
+		if r := e.Response; r != nil && r.Status != 0 {
+			body := r.Content.Text
+			if r.Content.Encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(body)
+				if err != nil {
+					return nil, err
+				}
+				body = string(decoded)
+			}
+			rec.Response = &recordResponse{Status: r.Status, Headers: harHeaders(r.Headers), Body: body}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func harHeaders(headers []harNameValue) map[string][]string {
+	h := make(http.Header, len(headers))
+	for _, nv := range headers {
+		// Skip the HTTP/2 pseudo-headers
+		if strings.HasPrefix(nv.Name, ":") {
+			continue
+		}
+		h.Add(nv.Name, nv.Value)
+	}
+	return h
+}
@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	waf "github.com/DataDog/go-libddwaf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rulesFile = "../../testdata/blocking.json"
+
+// writeCorpus writes the corpus to a file of the given name in dir, and
+// returns its path.
+func writeCorpus(t *testing.T, dir, name, corpus string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(corpus), 0644))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	if err := waf.Health(); err != nil {
+		t.Skipf("the WAF is not available: %v", err)
+	}
+	dir := t.TempDir()
+	xss := writeCorpus(t, dir, "xss.jsonl", `{"url":"http://localhost/?q=%3Cscript%3Ealert(1)%3C/script%3E"}`)
+	har := writeCorpus(t, dir, "requests.har", `{"log":{"entries":[
+		{
+			"request":{"method":"POST","url":"http://localhost/","headers":[{"name":":authority","value":"localhost"},{"name":"Content-Type","value":"application/json"}],"postData":{"text":"\"blocked-body\""}},
+			"_expect":["blk-001-003"]
+		},
+		{
+			"request":{"method":"GET","url":"http://localhost/","headers":[]},
+			"response":{"status":200,"headers":[{"name":"X-Test-Response","value":"blocked-response"}],"content":{"text":""}},
+			"_expect":["blk-001-004"]
+		},
+		{
+			"request":{"method":"GET","url":"http://localhost/","headers":[]},
+			"response":{"status":200,"headers":[{"name":"Content-Type","value":"application/json"}],"content":{"text":"`+base64.StdEncoding.EncodeToString([]byte(`"blocked-response"`))+`","encoding":"base64"}},
+			"_expect":["blk-001-004"]
+		},
+		{
+			"request":{"method":"GET","url":"http://localhost/","headers":[]},
+			"response":{"status":200,"headers":[],"content":{"text":"blocked-response"}}
+		}
+	]}}`)
+
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		corpus string // corpus is the JSON lines corpus given after args, if any
+		status int
+		output []string
+	}{
+		{
+			name: "jsonl",
+			corpus: `{"url":"http://localhost/","remote_addr":"1.2.3.4:1234","expect":["blk-001-001"]}
+
+{"method":"GET","url":"http://localhost/","headers":{"X-Test-Action":["custom"]},"expect":["blk-001-006"]}
+{"url":"http://localhost/"}`,
+			output: []string{
+				"corpus.jsonl:1: ",
+				"rules: blk-001-001",
+				"actions: block",
+				"corpus.jsonl:2: GET http://localhost/",
+				"rules: blk-001-006",
+				"3 requests replayed, 2 matched, 0 failed",
+			},
+		},
+		{
+			name: "har",
+			args: []string{har},
+			output: []string{
+				"ok   " + har + ":1: POST http://localhost/",
+				"rules: blk-001-003",
+				"ok   " + har + ":2: GET http://localhost/",
+				"ok   " + har + ":3: GET http://localhost/",
+				"4 requests replayed, 3 matched, 0 failed",
+			},
+		},
+		{
+			name:   "unexpected",
+			args:   []string{xss},
+			status: 1,
+			output: []string{
+				"FAIL " + xss + ":1: ",
+				"unexpected rules: crs-941-110",
+				"1 requests replayed, 1 matched, 1 failed",
+			},
+		},
+		{
+			name:   "allowed",
+			args:   []string{"-allow", "blk-001-001,crs-941-110", xss},
+			output: []string{"1 requests replayed, 1 matched, 0 failed"},
+		},
+		{
+			name:   "missing",
+			corpus: `{"url":"http://localhost/","remote_addr":"1.2.3.5:1234","expect":["blk-001-001"]}`,
+			status: 1,
+			output: []string{
+				"corpus.jsonl:1: ",
+				"missing rules: blk-001-001",
+				"1 requests replayed, 0 matched, 1 failed",
+			},
+		},
+		{
+			name:   "several-corpora",
+			args:   []string{har, xss},
+			status: 1,
+			output: []string{"5 requests replayed, 4 matched, 1 failed"},
+		},
+		{
+			name:   "invalid-jsonl",
+			corpus: "{\"url\":\"http://localhost/\"}\n{\"url\":",
+			status: 2,
+		},
+		{
+			name:   "invalid-har",
+			args:   []string{writeCorpus(t, dir, "invalid.har", `{"log":`)},
+			status: 2,
+		},
+		{
+			name:   "invalid-url",
+			corpus: `{"url":"://localhost"}`,
+			status: 2,
+		},
+		{
+			name:   "missing-corpus",
+			args:   []string{filepath.Join(dir, "missing.jsonl")},
+			status: 2,
+		},
+		{
+			name:   "missing-rules",
+			args:   []string{"-rules", filepath.Join(dir, "missing.json"), xss},
+			status: 2,
+		},
+		{
+			name:   "no-corpus",
+			status: 2,
+		},
+		{
+			name:   "invalid-flag",
+			args:   []string{"-invalid", xss},
+			status: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"-rules", rulesFile}, tc.args...)
+			if tc.corpus != "" {
+				args = append(args, writeCorpus(t, t.TempDir(), "corpus.jsonl", tc.corpus))
+			}
+			var stdout bytes.Buffer
+			require.Equal(t, tc.status, run(args, &stdout), stdout.String())
+			for _, out := range tc.output {
+				assert.Contains(t, stdout.String(), out)
+			}
+		})
+	}
+
+	t.Run("verbose", func(t *testing.T) {
+		corpus := writeCorpus(t, t.TempDir(), "corpus.jsonl", `{"url":"http://localhost/"}`)
+		var stdout bytes.Buffer
+		require.Equal(t, 0, run([]string{"-rules", rulesFile, corpus}, &stdout))
+		assert.NotContains(t, stdout.String(), "corpus.jsonl:1")
+		stdout.Reset()
+		require.Equal(t, 0, run([]string{"-rules", rulesFile, "-v", corpus}, &stdout))
+		assert.Contains(t, stdout.String(), "corpus.jsonl:1: ")
+	})
+}
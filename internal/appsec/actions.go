@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// Types of the actions of the rules
+const (
+	blockRequestActionType     = "block_request"
+	redirectRequestActionType  = "redirect_request"
+	rateLimitRequestActionType = "rate_limit_request"
+)
+
+type (
+	// actionEntry is an action of the rules, returned by the WAF with its ID when a rule matches.
+	actionEntry struct {
+		ID         string           `json:"id"`
+		Type       string           `json:"type"`
+		Parameters actionParameters `json:"parameters"`
+	}
+
+	// actionParameters are the parameters of the block_request, redirect_request and rate_limit_request actions.
+	actionParameters struct {
+		// StatusCode is the HTTP status code of the response
+		StatusCode int `json:"status_code"`
+		// GRPCStatusCode is the gRPC status code of the response, codes.Aborted when nil
+		GRPCStatusCode *int `json:"grpc_status_code,omitempty"`
+		// Type is the type of the blocking response: auto, json or html
+		Type string `json:"type,omitempty"`
+		// Location is the redirection location of the redirect_request action
+		Location string `json:"location,omitempty"`
+		// ContentType and Body are the custom blocking response, replacing the template selected by Type
+		ContentType string `json:"content_type,omitempty"`
+		Body        string `json:"body,omitempty"`
+		// Key is the client whose requests are counted by the rate_limit_request action: ip (default) or user
+		Key string `json:"key,omitempty"`
+		// Threshold is the number of requests of a client allowed by the rate_limit_request action over Period,
+		// in seconds. The configured defaults are used when zero.
+		Threshold uint `json:"threshold,omitempty"`
+		Period    uint `json:"period,omitempty"`
+	}
+)
+
+// parseActions returns the actions of the given rules.
+func parseActions(rules []byte) []actionEntry {
+	var r struct {
+		Actions []actionEntry `json:"actions"`
+	}
+	if err := json.Unmarshal(rules, &r); err != nil {
+		log.Debug("appsec: could not parse the actions of the rules: %v", err)
+		return nil
+	}
+	return r.Actions
+}
+
+// newHTTPActionsHandler returns the HTTP actions handler holding the default actions along with the given ones.
+func newHTTPActionsHandler(actions []actionEntry) *httpsec.ActionsHandler {
+	h := httpsec.NewActionsHandler()
+	for _, a := range actions {
+		p := a.Parameters
+		var action httpsec.BlockRequestAction
+		switch a.Type {
+		case blockRequestActionType, rateLimitRequestActionType:
+			// The rate_limit_request action blocks the requests like block_request once the rate limit is exceeded
+			if a.Type == rateLimitRequestActionType && !p.validRateLimitKey() {
+				log.Debug("appsec: ignoring the action %s of unsupported rate limit key %s", a.ID, p.Key)
+				continue
+			}
+			status := p.StatusCode
+			if status == 0 {
+				status = http.StatusForbidden
+			}
+			if p.Body != "" {
+				contentType := p.ContentType
+				if contentType == "" {
+					contentType = "text/plain"
+				}
+				action = httpsec.NewCustomBlockRequestAction(status, contentType, []byte(p.Body))
+			} else {
+				action = httpsec.NewBlockRequestAction(status, p.Type)
+			}
+		case redirectRequestActionType:
+			if p.Location == "" {
+				// Block the request with the default blocking response when the location is missing
+				action = httpsec.NewBlockRequestAction(http.StatusForbidden, "auto")
+				break
+			}
+			action = httpsec.NewRedirectRequestAction(p.StatusCode, p.Location)
+		default:
+			log.Debug("appsec: ignoring the action %s of unsupported type %s", a.ID, a.Type)
+			continue
+		}
+		h.RegisterAction(a.ID, &action)
+	}
+	return h
+}
+
+// validRateLimitKey returns whether the rate limit key of the rate_limit_request action is supported.
+func (p *actionParameters) validRateLimitKey() bool {
+	return p.Key == "" || p.Key == rateLimitKeyIP || p.Key == rateLimitKeyUser
+}
+
+// newGRPCActionsHandler returns the gRPC actions handler holding the default actions along with the given ones.
+// Redirections are not supported by gRPC and block the requests instead.
+func newGRPCActionsHandler(actions []actionEntry) *grpcsec.ActionsHandler {
+	h := grpcsec.NewActionsHandler()
+	for _, a := range actions {
+		if a.Type != blockRequestActionType && a.Type != redirectRequestActionType && a.Type != rateLimitRequestActionType {
+			continue
+		}
+		p := a.Parameters
+		if a.Type == rateLimitRequestActionType && !p.validRateLimitKey() {
+			continue
+		}
+		action := grpcsec.BlockRequestAction{Status: codes.Aborted}
+		if p.GRPCStatusCode != nil {
+			action.Status = codes.Code(*p.GRPCStatusCode)
+		}
+		if a.Type != redirectRequestActionType {
+			action.Message = p.Body
+		}
+		h.RegisterAction(a.ID, &action)
+	}
+	return &h
+}
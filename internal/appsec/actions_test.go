@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+)
+
+func TestActions(t *testing.T) {
+	actions := parseActions([]byte(`{
+		"actions": [
+			{"id": "redirect", "type": "redirect_request", "parameters": {"status_code": 301, "location": "/home"}},
+			{"id": "redirect-nowhere", "type": "redirect_request", "parameters": {"status_code": 301}},
+			{"id": "custom", "type": "block_request", "parameters": {"status_code": 418, "grpc_status_code": 7, "content_type": "text/plain", "body": "blocked"}},
+			{"id": "block", "type": "block_request", "parameters": {"status_code": 401, "type": "json"}},
+			{"id": "rate-limit", "type": "rate_limit_request", "parameters": {"key": "user", "threshold": 10, "period": 60, "status_code": 429}},
+			{"id": "rate-limit-session", "type": "rate_limit_request", "parameters": {"key": "session"}},
+			{"id": "unknown", "type": "generate_stack", "parameters": {}}
+		]
+	}`))
+	require.Len(t, actions, 7)
+
+	t.Run("http", func(t *testing.T) {
+		h := newHTTPActionsHandler(actions)
+		serve := func(id string) *httptest.ResponseRecorder {
+			unregister := dyngo.Register(httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, _ httpsec.HandlerOperationArgs) {
+				require.True(t, h.Apply(id, op))
+			}))
+			defer unregister()
+			w := httptest.NewRecorder()
+			handler := httpsec.WrapHandler(http.NotFoundHandler(), noopSpan{}, nil)
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			return w
+		}
+
+		w := serve("redirect")
+		require.Equal(t, 301, w.Code)
+		require.Equal(t, "/home", w.Header().Get("Location"))
+
+		w = serve("redirect-nowhere")
+		require.Equal(t, 403, w.Code)
+
+		w = serve("custom")
+		require.Equal(t, 418, w.Code)
+		require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+		require.Equal(t, "blocked", w.Body.String())
+
+		w = serve("block")
+		require.Equal(t, 401, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		w = serve("rate-limit")
+		require.Equal(t, 429, w.Code)
+
+		_, op := httpsec.StartOperation(context.Background(), httpsec.HandlerOperationArgs{})
+		require.False(t, h.Apply("unknown", op))
+		require.False(t, h.Apply("rate-limit-session", op))
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		h := newGRPCActionsHandler(actions)
+		for _, tc := range []struct {
+			id       string
+			expected error
+		}{
+			{id: "redirect", expected: status.Error(codes.Aborted, "Request blocked")},
+			{id: "custom", expected: status.Error(codes.PermissionDenied, "blocked")},
+			{id: "block", expected: status.Error(codes.Aborted, "Request blocked")},
+			{id: "rate-limit", expected: status.Error(codes.Aborted, "Request blocked")},
+		} {
+			t.Run(tc.id, func(t *testing.T) {
+				_, op := grpcsec.StartHandlerOperation(context.Background(), grpcsec.HandlerOperationArgs{}, nil)
+				require.True(t, h.Apply(tc.id, op))
+				require.Equal(t, tc.expected, op.Error)
+			})
+		}
+		_, op := grpcsec.StartHandlerOperation(context.Background(), grpcsec.HandlerOperationArgs{}, nil)
+		require.False(t, h.Apply("unknown", op))
+		require.False(t, h.Apply("rate-limit-session", op))
+	})
+
+	t.Run("rate-limits", func(t *testing.T) {
+		limits := newRateLimits(actions, clientRateLimitConfig{threshold: 5, period: time.Second})
+		require.Equal(t, map[string]rateLimit{
+			"rate-limit": {key: rateLimitKeyUser, threshold: 10, period: time.Minute},
+		}, limits)
+	})
+}
+
+// noopSpan is a ddtrace.Span ignoring its tags.
+type noopSpan struct {
+	ddtrace.Span
+}
+
+func (noopSpan) SetTag(string, interface{}) {}
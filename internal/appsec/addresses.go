@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package appsec
+
+// HTTP rule addresses currently supported by the WAF. They are exported for the
+// tools replaying requests against the rules, see _tools/rules-tester.
+const (
+	ServerRequestRawURIAddr            = "server.request.uri.raw"
+	ServerRequestHeadersNoCookiesAddr  = "server.request.headers.no_cookies"
+	ServerRequestCookiesAddr           = "server.request.cookies"
+	ServerRequestQueryAddr             = "server.request.query"
+	ServerRequestPathParamsAddr        = "server.request.path_params"
+	ServerRequestBodyAddr              = "server.request.body"
+	ServerResponseStatusAddr           = "server.response.status"
+	ServerResponseHeadersNoCookiesAddr = "server.response.headers.no_cookies"
+	ServerResponseBodyAddr             = "server.response.body"
+	HTTPClientIPAddr                   = "http.client_ip"
+	UserIDAddr                         = "usr.id"
+	GraphQLServerAllResolversAddr      = "graphql.server.all_resolvers"
+	ServerIONetURLAddr                 = "server.io.net.url"
+	ServerDBStatementAddr              = "server.db.statement"
+	ServerDBSystemAddr                 = "server.db.system"
+)
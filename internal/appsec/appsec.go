@@ -12,6 +12,7 @@ import (
 	"sync"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
 )
@@ -97,6 +98,11 @@ type appsec struct {
 	limiter       *TokenTicker
 	rc            *remoteconfig.Client
 	started       bool
+	// rulesMu serializes the security rules updates, either from remote config or from the local rules files
+	rulesMu      sync.Mutex
+	rulesWatcher *rulesWatcher
+	// clientLimiter counts the client requests of the rate_limit_request actions across the WAF updates
+	clientLimiter *clientRateLimiter
 }
 
 func newAppSec(cfg *Config) *appsec {
@@ -109,8 +115,9 @@ func newAppSec(cfg *Config) *appsec {
 		log.Error("appsec: Remote config: disabled due to a client creation error: %v", err)
 	}
 	return &appsec{
-		cfg: cfg,
-		rc:  client,
+		cfg:           cfg,
+		rc:            client,
+		clientLimiter: newClientRateLimiter(cfg.clientRateLimit.maxClients),
 	}
 }
 
@@ -124,14 +131,17 @@ func (a *appsec) start() error {
 	}
 	a.enableRCBlocking()
 	a.started = true
+	a.startRulesWatcher()
 	return nil
 }
 
 // Stop AppSec by unregistering the security protections.
 func (a *appsec) stop() {
 	if a.started {
+		a.stopRulesWatcher()
 		a.started = false
 		a.unregisterWAF()
+		httpsec.SetResponseMonitoring(false)
 		a.limiter.Stop()
 		a.disableRCBlocking()
 	}
@@ -0,0 +1,217 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// Keys of the rate_limit_request actions, i.e. the clients whose requests are counted
+const (
+	rateLimitKeyIP   = "ip"
+	rateLimitKeyUser = "user"
+)
+
+// rateLimit is the rate limit of a rate_limit_request action: at most threshold requests of a client over the
+// sliding period.
+type rateLimit struct {
+	key       string
+	threshold uint
+	period    time.Duration
+}
+
+// newRateLimits returns the rate limits of the given rate_limit_request actions, keyed by action ID. The configured
+// defaults are used when the threshold or period of an action is not set.
+func newRateLimits(actions []actionEntry, cfg clientRateLimitConfig) map[string]rateLimit {
+	limits := make(map[string]rateLimit)
+	for _, a := range actions {
+		p := a.Parameters
+		if a.Type != rateLimitRequestActionType || !p.validRateLimitKey() {
+			continue
+		}
+		limit := rateLimit{key: p.Key, threshold: p.Threshold, period: time.Duration(p.Period) * time.Second}
+		if limit.key == "" {
+			limit.key = rateLimitKeyIP
+		}
+		if limit.threshold == 0 {
+			limit.threshold = cfg.threshold
+		}
+		if limit.period == 0 {
+			limit.period = cfg.period
+		}
+		limits[a.ID] = limit
+	}
+	return limits
+}
+
+// clientRateLimiter counts the requests of the clients of the rate_limit_request actions in sliding windows. It is
+// bounded to the given number of clients by evicting the least recently seen ones, and is safe for concurrent use.
+type clientRateLimiter struct {
+	mu       sync.Mutex
+	capacity int
+	clients  map[string]*list.Element
+	lru      *list.List // of *slidingWindow, the most recently seen first
+	now      func() time.Time
+}
+
+// slidingWindow approximates the request rate of a client over a sliding period by weighting the request count of
+// the previous period by its overlap with the sliding one.
+type slidingWindow struct {
+	client   string
+	start    time.Time // start of the current period
+	current  uint      // requests of the current period
+	previous uint      // requests of the previous period
+}
+
+func newClientRateLimiter(capacity int) *clientRateLimiter {
+	if capacity <= 0 {
+		capacity = defaultRateLimitMaxClients
+	}
+	return &clientRateLimiter{
+		capacity: capacity,
+		clients:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// exceeded counts a request of the given client in the rate limit of the action id, and returns whether the rate
+// limit is now exceeded.
+func (l *clientRateLimiter) exceeded(id string, limit rateLimit, client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	key := id + "|" + client
+	e, ok := l.clients[key]
+	if ok {
+		l.lru.MoveToFront(e)
+	} else {
+		if l.lru.Len() >= l.capacity {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.clients, oldest.Value.(*slidingWindow).client)
+		}
+		e = l.lru.PushFront(&slidingWindow{client: key, start: now})
+		l.clients[key] = e
+	}
+	return e.Value.(*slidingWindow).add(now, limit.period) > float64(limit.threshold)
+}
+
+// add counts a request at time now and returns the request count over the sliding period ending now.
+func (w *slidingWindow) add(now time.Time, period time.Duration) float64 {
+	if elapsed := now.Sub(w.start); elapsed >= 2*period {
+		w.start, w.previous, w.current = now, 0, 0
+	} else if elapsed >= period {
+		w.start, w.previous, w.current = w.start.Add(period), w.current, 0
+	}
+	w.current++
+	overlap := 1 - float64(now.Sub(w.start))/float64(period)
+	return float64(w.previous)*overlap + float64(w.current)
+}
+
+// requestRateLimits applies the rate limits of the actions returned by the WAF to a request, by counting the request
+// at most once per action as soon as its client is known. It is safe for concurrent use.
+type requestRateLimits struct {
+	mu       sync.Mutex
+	limiter  *clientRateLimiter
+	limits   map[string]rateLimit
+	clientIP string
+	userID   string
+	// exceeded holds whether the rate limit of the actions the request was already counted in are exceeded
+	exceeded map[string]bool
+	// pending holds the actions keyed by user the request is not counted in yet, until the user gets known
+	pending []string
+}
+
+func newRequestRateLimits(limiter *clientRateLimiter, limits map[string]rateLimit, clientIP netip.Addr) *requestRateLimits {
+	r := &requestRateLimits{limiter: limiter, limits: limits}
+	if clientIP.IsValid() {
+		r.clientIP = clientIP.String()
+	}
+	return r
+}
+
+// filter returns the given action IDs which must be applied: the actions other than rate_limit_request, and the
+// rate_limit_request actions whose rate limit is exceeded by the request client.
+func (r *requestRateLimits) filter(ids []string) []string {
+	if len(r.limits) == 0 || len(ids) == 0 {
+		return ids
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	filtered := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := r.limits[id]; !ok || r.count(id) {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered
+}
+
+// setUserID sets the user of the request and returns the pending rate_limit_request actions keyed by user whose
+// rate limit is exceeded by this user.
+func (r *requestRateLimits) setUserID(userID string) []string {
+	if len(r.limits) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.userID = userID
+	pending := r.pending
+	r.pending = nil
+	var exceeded []string
+	for _, id := range pending {
+		if r.count(id) {
+			exceeded = append(exceeded, id)
+		}
+	}
+	return exceeded
+}
+
+// count counts the request in the rate limit of the action id, once, and returns whether the rate limit is exceeded.
+// The action is kept pending when the client of its key is not known yet.
+func (r *requestRateLimits) count(id string) bool {
+	if exceeded, ok := r.exceeded[id]; ok {
+		return exceeded
+	}
+	limit := r.limits[id]
+	client := r.clientIP
+	if limit.key == rateLimitKeyUser {
+		client = r.userID
+	}
+	if client == "" {
+		if limit.key == rateLimitKeyUser {
+			r.addPending(id)
+		}
+		return false
+	}
+	if r.exceeded == nil {
+		r.exceeded = make(map[string]bool, len(r.limits))
+	}
+	exceeded := r.limiter.exceeded(id, limit, client)
+	r.exceeded[id] = exceeded
+	if exceeded {
+		log.Debug("appsec: the rate limit of the action %s is exceeded by the %s %s", id, limit.key, client)
+	}
+	return exceeded
+}
+
+func (r *requestRateLimits) addPending(id string) {
+	for _, p := range r.pending {
+		if p == id {
+			return
+		}
+	}
+	r.pending = append(r.pending, id)
+}
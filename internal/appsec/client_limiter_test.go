@@ -0,0 +1,162 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sharedsec"
+
+	waf "github.com/DataDog/go-libddwaf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	newTestLimiter := func(capacity int) *clientRateLimiter {
+		l := newClientRateLimiter(capacity)
+		l.now = func() time.Time { return now }
+		return l
+	}
+	limit := rateLimit{key: rateLimitKeyIP, threshold: 3, period: time.Minute}
+
+	t.Run("sliding-window", func(t *testing.T) {
+		l := newTestLimiter(10)
+		for i := 0; i < 3; i++ {
+			require.False(t, l.exceeded("rl", limit, "1.2.3.4"))
+		}
+		require.True(t, l.exceeded("rl", limit, "1.2.3.4"))
+		require.False(t, l.exceeded("rl", limit, "5.6.7.8"))
+		require.False(t, l.exceeded("other", limit, "1.2.3.4"))
+
+		// The 4 requests of the previous period are fully part of the sliding period
+		now = now.Add(time.Minute)
+		require.True(t, l.exceeded("rl", limit, "1.2.3.4"))
+		// Only a quarter of them is still part of it: 4*0.25 + 2 requests
+		now = now.Add(45 * time.Second)
+		require.False(t, l.exceeded("rl", limit, "1.2.3.4"))
+		// Both periods are over
+		now = now.Add(2 * time.Minute)
+		require.False(t, l.exceeded("rl", limit, "1.2.3.4"))
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		l := newTestLimiter(2)
+		l.exceeded("rl", limit, "a")
+		l.exceeded("rl", limit, "b")
+		l.exceeded("rl", limit, "a")
+		l.exceeded("rl", limit, "c")
+		require.Equal(t, 2, l.lru.Len())
+		require.Len(t, l.clients, 2)
+		require.Contains(t, l.clients, "rl|a")
+		require.Contains(t, l.clients, "rl|c")
+	})
+
+	t.Run("request", func(t *testing.T) {
+		l := newTestLimiter(10)
+		limits := newRateLimits([]actionEntry{
+			{ID: "rl-ip", Type: rateLimitRequestActionType},
+			{ID: "rl-user", Type: rateLimitRequestActionType, Parameters: actionParameters{Key: rateLimitKeyUser}},
+		}, clientRateLimitConfig{threshold: 1, period: time.Minute})
+		require.Equal(t, rateLimit{key: rateLimitKeyIP, threshold: 1, period: time.Minute}, limits["rl-ip"])
+
+		// The first request of the clients doesn't exceed the rate limits, and is counted once per action
+		r := newRequestRateLimits(l, limits, netip.MustParseAddr("1.2.3.4"))
+		require.Equal(t, []string{"block"}, r.filter([]string{"rl-ip", "rl-user", "block"}))
+		require.Empty(t, r.filter([]string{"rl-ip", "rl-user"}))
+		require.Empty(t, r.setUserID("bob"))
+
+		// The second request exceeds them, as soon as the user is known for the user rate limit
+		r = newRequestRateLimits(l, limits, netip.MustParseAddr("1.2.3.4"))
+		require.Equal(t, []string{"rl-ip"}, r.filter([]string{"rl-ip", "rl-user"}))
+		require.Equal(t, []string{"rl-user"}, r.setUserID("bob"))
+		require.Equal(t, []string{"rl-ip", "rl-user"}, r.filter([]string{"rl-ip", "rl-user"}))
+
+		// The client IP is unknown
+		r = newRequestRateLimits(l, limits, netip.Addr{})
+		require.Empty(t, r.filter([]string{"rl-ip"}))
+	})
+}
+
+func TestRateLimitingActions(t *testing.T) {
+	if waf.Health() != nil {
+		t.Skip("WAF needs to be available for this test")
+	}
+	rules := []byte(`{
+		"version": "2.2",
+		"metadata": {"rules_version": "1.2.3"},
+		"rules": [
+			{
+				"id": "rl-001",
+				"name": "Scanner",
+				"tags": {"type": "security_scanner", "category": "attack_attempt"},
+				"conditions": [{"operator": "match_regex", "parameters": {"inputs": [{"address": "server.request.headers.no_cookies", "key_path": ["x-scanner"]}], "regex": "."}}],
+				"transformers": [],
+				"on_match": ["rate-limit-ip"]
+			},
+			{
+				"id": "rl-002",
+				"name": "Credential stuffing",
+				"tags": {"type": "credential_stuffing", "category": "attack_attempt"},
+				"conditions": [{"operator": "match_regex", "parameters": {"inputs": [{"address": "server.request.uri.raw"}], "regex": "^/login"}}],
+				"transformers": [],
+				"on_match": ["rate-limit-user"]
+			}
+		],
+		"actions": [
+			{"id": "rate-limit-ip", "type": "rate_limit_request", "parameters": {"threshold": 2, "period": 60, "status_code": 429}},
+			{"id": "rate-limit-user", "type": "rate_limit_request", "parameters": {"key": "user", "threshold": 2, "period": 60}}
+		]
+	}`)
+	cfg, err := newConfig()
+	require.NoError(t, err)
+	a := newAppSec(cfg)
+	a.limiter = NewTokenTicker(int64(cfg.traceRateLimit), int64(cfg.traceRateLimit))
+	require.NoError(t, a.swapWAF(rules))
+	defer func() { a.unregisterWAF() }()
+
+	t.Run("ip", func(t *testing.T) {
+		scan := func(ip string) []httpsec.Action {
+			_, op := httpsec.StartOperation(context.Background(), httpsec.HandlerOperationArgs{
+				ClientIP: netip.MustParseAddr(ip),
+				Headers:  map[string][]string{"x-scanner": {"1"}},
+			})
+			defer op.Finish(httpsec.HandlerOperationRes{Status: 200})
+			return op.Actions()
+		}
+		require.Empty(t, scan("1.2.3.4"))
+		require.Empty(t, scan("1.2.3.4"))
+		require.Len(t, scan("1.2.3.4"), 1)
+		require.Empty(t, scan("5.6.7.8"))
+
+		// The rate limits are kept across the WAF updates
+		require.NoError(t, a.swapWAF(rules))
+		require.Len(t, scan("1.2.3.4"), 1)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		login := func(ip, user string) error {
+			_, op := httpsec.StartOperation(context.Background(), httpsec.HandlerOperationArgs{
+				ClientIP:   netip.MustParseAddr(ip),
+				RequestURI: "/login",
+			})
+			defer op.Finish(httpsec.HandlerOperationRes{Status: 200})
+			return sharedsec.ExecuteUserIDOperation(op, sharedsec.UserIDOperationArgs{UserID: user})
+		}
+		// Different client IPs, but the same user
+		require.NoError(t, login("10.0.0.1", "bob"))
+		require.NoError(t, login("10.0.0.2", "bob"))
+		require.Error(t, login("10.0.0.3", "bob"))
+		require.NoError(t, login("10.0.0.3", "alice"))
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 	"unicode"
 	"unicode/utf8"
@@ -19,17 +20,28 @@ import (
 )
 
 const (
-	enabledEnvVar         = "DD_APPSEC_ENABLED"
-	rulesEnvVar           = "DD_APPSEC_RULES"
-	wafTimeoutEnvVar      = "DD_APPSEC_WAF_TIMEOUT"
-	traceRateLimitEnvVar  = "DD_APPSEC_TRACE_RATE_LIMIT"
-	obfuscatorKeyEnvVar   = "DD_APPSEC_OBFUSCATION_PARAMETER_KEY_REGEXP"
-	obfuscatorValueEnvVar = "DD_APPSEC_OBFUSCATION_PARAMETER_VALUE_REGEXP"
+	enabledEnvVar             = "DD_APPSEC_ENABLED"
+	rulesEnvVar               = "DD_APPSEC_RULES"
+	wafTimeoutEnvVar          = "DD_APPSEC_WAF_TIMEOUT"
+	traceRateLimitEnvVar      = "DD_APPSEC_TRACE_RATE_LIMIT"
+	obfuscatorKeyEnvVar       = "DD_APPSEC_OBFUSCATION_PARAMETER_KEY_REGEXP"
+	obfuscatorValueEnvVar     = "DD_APPSEC_OBFUSCATION_PARAMETER_VALUE_REGEXP"
+	apiSecEnabledEnvVar       = "DD_EXPERIMENTAL_API_SECURITY_ENABLED"
+	apiSecSampleRateEnvVar    = "DD_API_SECURITY_REQUEST_SAMPLE_RATE"
+	rulesFragmentsEnvVar      = "DD_APPSEC_RULES_FRAGMENTS"
+	rulesReloadEnvVar         = "DD_APPSEC_RULES_RELOAD_INTERVAL"
+	rateLimitThresholdEnvVar  = "DD_APPSEC_RATE_LIMIT_THRESHOLD"
+	rateLimitPeriodEnvVar     = "DD_APPSEC_RATE_LIMIT_PERIOD"
+	rateLimitMaxClientsEnvVar = "DD_APPSEC_RATE_LIMIT_MAX_CLIENTS"
 )
 
 const (
 	defaultWAFTimeout           = 4 * time.Millisecond
 	defaultTraceRate            = 100 // up to 100 appsec traces/s
+	defaultAPISecSampleRate     = 0.1 // 10% of the requests
+	defaultRateLimitThreshold   = 100 // up to 100 requests per client and period
+	defaultRateLimitPeriod      = time.Minute
+	defaultRateLimitMaxClients  = 10000 // number of clients whose request rates are tracked
 	defaultObfuscatorKeyRegex   = `(?i)(?:p(?:ass)?w(?:or)?d|pass(?:_?phrase)?|secret|(?:api_?|private_?|public_?)key)|token|consumer_?(?:id|key|secret)|sign(?:ed|ature)|bearer|authorization`
 	defaultObfuscatorValueRegex = `(?i)(?:p(?:ass)?w(?:or)?d|pass(?:_?phrase)?|secret|(?:api_?|private_?|public_?|access_?|secret_?)key(?:_?id)?|token|consumer_?(?:id|key|secret)|sign(?:ed|ature)?|auth(?:entication|orization)?)(?:\s*=[^;]|"\s*:\s*"[^"]+")|bearer\s+[a-z0-9\._\-]+|token:[a-z0-9]{13}|gh[opsu]_[0-9a-zA-Z]{36}|ey[I-L][\w=-]+\.ey[I-L][\w=-]+(?:\.[\w.+\/=-]+)?|[\-]{5}BEGIN[a-z\s]+PRIVATE\sKEY[\-]{5}[^\-]+[\-]{5}END[a-z\s]+PRIVATE\sKEY|ssh-rsa\s*[a-z0-9\/\.+]{100,}`
 )
@@ -50,6 +62,12 @@ type Config struct {
 	obfuscator ObfuscatorConfig
 	// rc is the remote configuration client used to receive product configuration updates. Nil if rc is disabled (default)
 	rc *remoteconfig.ClientConfig
+	// API Security configuration parameters
+	apiSec APISecConfig
+	// Hot reload configuration of the local rules file and rules fragments
+	rulesReload rulesReloadConfig
+	// Default configuration of the rate_limit_request actions
+	clientRateLimit clientRateLimitConfig
 }
 
 // WithRCConfig sets the AppSec remote config client configuration to the specified cfg
@@ -65,6 +83,36 @@ type ObfuscatorConfig struct {
 	ValueRegex string
 }
 
+// APISecConfig holds the API Security configuration parameters. When enabled,
+// the schemas of a sample of the requests and responses are extracted and
+// reported in the service entry span. The schema extraction and the scanning
+// of their values for PII are implemented in Go by the tracer: they don't
+// use the WAF handle nor the processors of its rules.
+type APISecConfig struct {
+	Enabled bool
+	// SampleRate is the ratio of the requests whose schemas are extracted, between 0 and 1.
+	SampleRate float64
+}
+
+// rulesReloadConfig holds the configuration of the hot reload of the local security rules. The rules file and the
+// JSON files of the fragments directories are polled at the given interval, and the security rules get reloaded
+// when they change. The hot reload is disabled when the interval is zero.
+type rulesReloadConfig struct {
+	rulesFile     string
+	fragmentsDirs []string
+	interval      time.Duration
+}
+
+// clientRateLimitConfig holds the configuration of the rate_limit_request actions, which block the requests of a
+// client IP or user once they exceed the threshold of the action in its sliding period. The threshold and period
+// are the defaults of the actions not specifying them, while the request rates of at most maxClients clients are
+// tracked.
+type clientRateLimitConfig struct {
+	threshold  uint
+	period     time.Duration
+	maxClients int
+}
+
 // isEnabled returns true when appsec is enabled when the environment variable
 // It also returns whether the env var is actually set in the env or not
 // DD_APPSEC_ENABLED is set to true.
@@ -84,12 +132,26 @@ func newConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	rulesReload := readRulesReloadConfig()
+	rulesManager := newRulesManager(rules)
+	if len(rulesReload.fragmentsDirs) > 0 {
+		if fragments, err := readRulesFragments(rulesReload.fragmentsDirs); err != nil {
+			log.Error("appsec: could not load the rules fragments: %v", err)
+		} else {
+			log.Info("appsec: starting with %d rules fragments from %v", len(fragments), rulesReload.fragmentsDirs)
+			rulesManager.setLocalFragments(fragments)
+			rulesManager.compile()
+		}
+	}
 
 	return &Config{
-		rulesManager:   newRulesManager(rules),
-		wafTimeout:     readWAFTimeoutConfig(),
-		traceRateLimit: readRateLimitConfig(),
-		obfuscator:     readObfuscatorConfig(),
+		rulesManager:    rulesManager,
+		wafTimeout:      readWAFTimeoutConfig(),
+		traceRateLimit:  readRateLimitConfig(),
+		obfuscator:      ReadObfuscatorConfig(),
+		apiSec:          readAPISecConfig(),
+		rulesReload:     rulesReload,
+		clientRateLimit: readClientRateLimitConfig(),
 	}, nil
 }
 
@@ -137,7 +199,35 @@ func readRateLimitConfig() (rate uint) {
 	return uint(parsed)
 }
 
-func readObfuscatorConfig() ObfuscatorConfig {
+func readAPISecConfig() APISecConfig {
+	cfg := APISecConfig{SampleRate: defaultAPISecSampleRate}
+	if value := os.Getenv(apiSecEnabledEnvVar); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			logUnexpectedEnvVarValue(apiSecEnabledEnvVar, value, "expecting a boolean value", false)
+		}
+		cfg.Enabled = enabled
+	}
+	value := os.Getenv(apiSecSampleRateEnvVar)
+	if value == "" {
+		return cfg
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logUnexpectedEnvVarValue(apiSecSampleRateEnvVar, value, "expecting a float value", cfg.SampleRate)
+		return cfg
+	}
+	if rate < 0 || rate > 1 {
+		logUnexpectedEnvVarValue(apiSecSampleRateEnvVar, rate, "expecting a value between 0 and 1", cfg.SampleRate)
+		return cfg
+	}
+	cfg.SampleRate = rate
+	return cfg
+}
+
+// ReadObfuscatorConfig returns the obfuscator configuration of AppSec: the
+// regular expressions configured through the environment, or the default ones.
+func ReadObfuscatorConfig() ObfuscatorConfig {
 	keyRE := readObfuscatorConfigRegexp(obfuscatorKeyEnvVar, defaultObfuscatorKeyRegex)
 	valueRE := readObfuscatorConfigRegexp(obfuscatorValueEnvVar, defaultObfuscatorValueRegex)
 	return ObfuscatorConfig{KeyRegex: keyRE, ValueRegex: valueRE}
@@ -175,6 +265,62 @@ func readRulesConfig() (rules []byte, err error) {
 	return buf, nil
 }
 
+func readRulesReloadConfig() rulesReloadConfig {
+	cfg := rulesReloadConfig{rulesFile: os.Getenv(rulesEnvVar)}
+	for _, dir := range strings.Split(os.Getenv(rulesFragmentsEnvVar), ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			cfg.fragmentsDirs = append(cfg.fragmentsDirs, dir)
+		}
+	}
+	value := os.Getenv(rulesReloadEnvVar)
+	if value == "" {
+		return cfg
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		logEnvVarParsingError(rulesReloadEnvVar, value, err, 0)
+		return cfg
+	}
+	if interval < 0 {
+		logUnexpectedEnvVarValue(rulesReloadEnvVar, interval, "negative value", 0)
+		return cfg
+	}
+	cfg.interval = interval
+	return cfg
+}
+
+func readClientRateLimitConfig() clientRateLimitConfig {
+	cfg := clientRateLimitConfig{
+		threshold:  defaultRateLimitThreshold,
+		period:     defaultRateLimitPeriod,
+		maxClients: defaultRateLimitMaxClients,
+	}
+	if value := os.Getenv(rateLimitThresholdEnvVar); value != "" {
+		if threshold, err := strconv.ParseUint(value, 10, 0); err != nil || threshold == 0 {
+			logUnexpectedEnvVarValue(rateLimitThresholdEnvVar, value, "expecting a value strictly greater than 0", cfg.threshold)
+		} else {
+			cfg.threshold = uint(threshold)
+		}
+	}
+	if value := os.Getenv(rateLimitPeriodEnvVar); value != "" {
+		if period, err := time.ParseDuration(value); err != nil {
+			logEnvVarParsingError(rateLimitPeriodEnvVar, value, err, cfg.period)
+		} else if period <= 0 {
+			logUnexpectedEnvVarValue(rateLimitPeriodEnvVar, period, "expecting a strictly positive duration", cfg.period)
+		} else {
+			cfg.period = period
+		}
+	}
+	if value := os.Getenv(rateLimitMaxClientsEnvVar); value != "" {
+		if maxClients, err := strconv.Atoi(value); err != nil || maxClients <= 0 {
+			logUnexpectedEnvVarValue(rateLimitMaxClientsEnvVar, value, "expecting a value strictly greater than 0", cfg.maxClients)
+		} else {
+			cfg.maxClients = maxClients
+		}
+	}
+	return cfg
+}
+
 func logEnvVarParsingError(name, value string, err error, defaultValue interface{}) {
 	log.Error("appsec: could not parse the env var %s=%s as a duration: %v. Using default value %v.", name, value, err, defaultValue)
 }
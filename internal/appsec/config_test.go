@@ -10,6 +10,7 @@ package appsec
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -25,6 +26,12 @@ func TestConfig(t *testing.T) {
 			KeyRegex:   defaultObfuscatorKeyRegex,
 			ValueRegex: defaultObfuscatorValueRegex,
 		},
+		apiSec: APISecConfig{SampleRate: defaultAPISecSampleRate},
+		clientRateLimit: clientRateLimitConfig{
+			threshold:  defaultRateLimitThreshold,
+			period:     defaultRateLimitPeriod,
+			maxClients: defaultRateLimitMaxClients,
+		},
 	}
 
 	t.Run("default", func(t *testing.T) {
@@ -125,6 +132,7 @@ func TestConfig(t *testing.T) {
 			}()
 			expCfg := *expectedDefaultConfig
 			expCfg.rulesManager = newRulesManager([]byte(staticRecommendedRules))
+			expCfg.rulesReload.rulesFile = file.Name()
 			_, err = file.WriteString(staticRecommendedRules)
 			require.NoError(t, err)
 			os.Setenv(rulesEnvVar, file.Name())
@@ -246,15 +254,120 @@ func TestConfig(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("api-security", func(t *testing.T) {
+		t.Run("enabled", func(t *testing.T) {
+			expCfg := *expectedDefaultConfig
+			expCfg.apiSec = APISecConfig{Enabled: true, SampleRate: 0.5}
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(apiSecEnabledEnvVar, "true"))
+			require.NoError(t, os.Setenv(apiSecSampleRateEnvVar, "0.5"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, &expCfg, cfg)
+		})
+
+		t.Run("not-parsable", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(apiSecEnabledEnvVar, "not a bool"))
+			require.NoError(t, os.Setenv(apiSecSampleRateEnvVar, "not a float"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, expectedDefaultConfig, cfg)
+		})
+
+		t.Run("out-of-range", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(apiSecSampleRateEnvVar, "1.5"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, expectedDefaultConfig, cfg)
+		})
+	})
+
+	t.Run("rules-reload", func(t *testing.T) {
+		t.Run("enabled", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "exclusions.json"), []byte(`{"exclusions":[{"id":"exc-000-001","rules_target":[{"rule_id":"crs-913-120"}]}]}`), 0644))
+			require.NoError(t, os.Setenv(rulesFragmentsEnvVar, dir+", "))
+			require.NoError(t, os.Setenv(rulesReloadEnvVar, "10s"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, rulesReloadConfig{fragmentsDirs: []string{dir}, interval: 10 * time.Second}, cfg.rulesReload)
+			require.Len(t, cfg.rulesManager.latest.Exclusions, 1)
+		})
+
+		t.Run("invalid-fragment", func(t *testing.T) {
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.json"), []byte(`{`), 0644))
+			require.NoError(t, os.Setenv(rulesFragmentsEnvVar, dir))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Empty(t, cfg.rulesManager.latest.Exclusions)
+		})
+
+		for _, value := range []string{"not a duration", "-1s"} {
+			t.Run(value, func(t *testing.T) {
+				restoreEnv := cleanEnv()
+				defer restoreEnv()
+				require.NoError(t, os.Setenv(rulesReloadEnvVar, value))
+				cfg, err := newConfig()
+				require.NoError(t, err)
+				require.Equal(t, expectedDefaultConfig, cfg)
+			})
+		}
+	})
+
+	t.Run("client-rate-limit", func(t *testing.T) {
+		t.Run("env-vars", func(t *testing.T) {
+			expCfg := *expectedDefaultConfig
+			expCfg.clientRateLimit = clientRateLimitConfig{threshold: 5, period: 10 * time.Second, maxClients: 42}
+			restoreEnv := cleanEnv()
+			defer restoreEnv()
+			require.NoError(t, os.Setenv(rateLimitThresholdEnvVar, "5"))
+			require.NoError(t, os.Setenv(rateLimitPeriodEnvVar, "10s"))
+			require.NoError(t, os.Setenv(rateLimitMaxClientsEnvVar, "42"))
+			cfg, err := newConfig()
+			require.NoError(t, err)
+			require.Equal(t, &expCfg, cfg)
+		})
+
+		for _, value := range []string{"0", "-1", "not a number"} {
+			t.Run(value, func(t *testing.T) {
+				restoreEnv := cleanEnv()
+				defer restoreEnv()
+				require.NoError(t, os.Setenv(rateLimitThresholdEnvVar, value))
+				require.NoError(t, os.Setenv(rateLimitPeriodEnvVar, value))
+				require.NoError(t, os.Setenv(rateLimitMaxClientsEnvVar, value))
+				cfg, err := newConfig()
+				require.NoError(t, err)
+				require.Equal(t, expectedDefaultConfig, cfg)
+			})
+		}
+	})
 }
 
 func cleanEnv() func() {
 	env := map[string]string{
-		wafTimeoutEnvVar:      os.Getenv(wafTimeoutEnvVar),
-		rulesEnvVar:           os.Getenv(rulesEnvVar),
-		traceRateLimitEnvVar:  os.Getenv(traceRateLimitEnvVar),
-		obfuscatorKeyEnvVar:   os.Getenv(obfuscatorKeyEnvVar),
-		obfuscatorValueEnvVar: os.Getenv(obfuscatorValueEnvVar),
+		wafTimeoutEnvVar:          os.Getenv(wafTimeoutEnvVar),
+		rulesEnvVar:               os.Getenv(rulesEnvVar),
+		traceRateLimitEnvVar:      os.Getenv(traceRateLimitEnvVar),
+		obfuscatorKeyEnvVar:       os.Getenv(obfuscatorKeyEnvVar),
+		obfuscatorValueEnvVar:     os.Getenv(obfuscatorValueEnvVar),
+		apiSecEnabledEnvVar:       os.Getenv(apiSecEnabledEnvVar),
+		apiSecSampleRateEnvVar:    os.Getenv(apiSecSampleRateEnvVar),
+		rulesFragmentsEnvVar:      os.Getenv(rulesFragmentsEnvVar),
+		rulesReloadEnvVar:         os.Getenv(rulesReloadEnvVar),
+		rateLimitThresholdEnvVar:  os.Getenv(rateLimitThresholdEnvVar),
+		rateLimitPeriodEnvVar:     os.Getenv(rateLimitPeriodEnvVar),
+		rateLimitMaxClientsEnvVar: os.Getenv(rateLimitMaxClientsEnvVar),
 	}
 	for k, _ := range env {
 		if err := os.Unsetenv(k); err != nil {
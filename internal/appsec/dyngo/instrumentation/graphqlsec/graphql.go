@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package graphqlsec defines the GraphQL instrumentation API and contract for
+// AppSec. It defines an abstract representation of GraphQL resolvers that
+// GraphQL integrations must use to enable AppSec features for GraphQL, which
+// listens to this package's operation events.
+package graphqlsec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+)
+
+type (
+	// ResolverOperation type representing the execution of a GraphQL field
+	// resolver. It gets both created and destroyed in a single call to
+	// ExecuteResolverOperation.
+	ResolverOperation struct {
+		dyngo.Operation
+		// Error is set by the operation listeners when the request must be
+		// blocked.
+		Error error
+	}
+	// ResolverOperationArgs is the resolver operation arguments.
+	ResolverOperationArgs struct {
+		// TypeName is the name of the type of the resolved field.
+		TypeName string
+		// FieldName is the name of the resolved field.
+		FieldName string
+		// Arguments corresponds to the arguments of the resolver, which are
+		// part of the address `graphql.server.all_resolvers`.
+		Arguments map[string]interface{}
+	}
+	// ResolverOperationRes is the resolver operation results.
+	ResolverOperationRes struct{}
+
+	// OnResolverOperationStart function type, called when a resolver
+	// operation starts.
+	OnResolverOperationStart func(*ResolverOperation, ResolverOperationArgs)
+)
+
+// ErrBlocked is the error returned by MonitorResolver when the request must be
+// blocked.
+var ErrBlocked = errors.New("Request blocked")
+
+var resolverOperationArgsType = reflect.TypeOf((*ResolverOperationArgs)(nil)).Elem()
+
+// ExecuteResolverOperation starts and finishes the resolver operation by
+// emitting a dyngo start and finish events. An error is returned if the request
+// must be blocked.
+func ExecuteResolverOperation(parent dyngo.Operation, args ResolverOperationArgs) error {
+	op := &ResolverOperation{Operation: dyngo.NewOperation(parent)}
+	dyngo.StartOperation(op, args)
+	dyngo.FinishOperation(op, ResolverOperationRes{})
+	return op.Error
+}
+
+// ListenedType returns the type a OnResolverOperationStart event listener
+// listens to, which is the ResolverOperationArgs type.
+func (OnResolverOperationStart) ListenedType() reflect.Type { return resolverOperationArgsType }
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnResolverOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*ResolverOperation), v.(ResolverOperationArgs))
+}
+
+// MonitorResolver starts and finishes a resolver operation in the HTTP
+// handler operation of the given context, if any. The WAF checks the resolver
+// arguments and ErrBlocked is returned if the request must be blocked. The
+// return value is nil otherwise, and when the request is not monitored.
+func MonitorResolver(ctx context.Context, typeName, fieldName string, arguments map[string]interface{}) error {
+	parent, ok := ctx.Value(instrumentation.ContextKey{}).(dyngo.Operation)
+	if !ok {
+		// The GraphQL server is not served by a monitored HTTP handler
+		return nil
+	}
+	return ExecuteResolverOperation(parent, ResolverOperationArgs{
+		TypeName:  typeName,
+		FieldName: fieldName,
+		Arguments: arguments,
+	})
+}
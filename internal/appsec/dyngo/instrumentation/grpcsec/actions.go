@@ -57,7 +57,11 @@ func (h *ActionsHandler) Apply(id string, op *HandlerOperation) bool {
 	}
 	// Currently, only the "block_request" type is supported, so we only need to check for blockRequestParams
 	if p, ok := a.(*BlockRequestAction); ok {
-		op.Error = status.Error(p.Status, "Request blocked")
+		msg := p.Message
+		if msg == "" {
+			msg = "Request blocked"
+		}
+		op.Error = status.Error(p.Status, msg)
 		op.AddTag(instrumentation.BlockedRequestTag, true)
 		return true
 	}
@@ -68,6 +72,8 @@ func (h *ActionsHandler) Apply(id string, op *HandlerOperation) bool {
 type BlockRequestAction struct {
 	// Status is the return code to use when blocking the request
 	Status codes.Code
+	// Message is the error message to use when blocking the request, "Request blocked" when empty
+	Message string
 }
 
 func (*BlockRequestAction) isAction() {}
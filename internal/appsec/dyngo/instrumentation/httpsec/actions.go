@@ -56,6 +56,24 @@ func NewBlockRequestAction(status int, template string) BlockRequestAction {
 
 }
 
+// NewCustomBlockRequestAction creates, initializes and returns a new BlockRequestAction responding with the given
+// status code, content type and body.
+func NewCustomBlockRequestAction(status int, contentType string, body []byte) BlockRequestAction {
+	return BlockRequestAction{handler: newBlockRequestHandler(status, contentType, body)}
+}
+
+// NewRedirectRequestAction creates, initializes and returns a new BlockRequestAction redirecting the request to the
+// given location with the given redirection status code. It responds with a 303 See Other status code when the given
+// one isn't a redirection status code.
+func NewRedirectRequestAction(status int, location string) BlockRequestAction {
+	if status < 300 || status > 399 {
+		status = http.StatusSeeOther
+	}
+	return BlockRequestAction{handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, location, status)
+	})}
+}
+
 func newBlockRequestHandler(status int, ct string, payload []byte) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", ct)
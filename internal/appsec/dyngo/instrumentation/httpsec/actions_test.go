@@ -153,3 +153,33 @@ func TestNewBlockRequestAction(t *testing.T) {
 		}
 	})
 }
+
+func TestNewCustomBlockRequestAction(t *testing.T) {
+	a := NewCustomBlockRequestAction(418, "text/plain", []byte("blocked"))
+	w := httptest.NewRecorder()
+	a.handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 418, w.Code)
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Equal(t, "blocked", w.Body.String())
+}
+
+func TestNewRedirectRequestAction(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		expected int
+	}{
+		{name: "found", status: 302, expected: 302},
+		{name: "permanent", status: 308, expected: 308},
+		{name: "not-a-redirection", status: 200, expected: 303},
+		{name: "default", expected: 303},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewRedirectRequestAction(tc.status, "/blocked")
+			w := httptest.NewRecorder()
+			a.handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			require.Equal(t, tc.expected, w.Code)
+			require.Equal(t, "/blocked", w.Header().Get("Location"))
+		})
+	}
+}
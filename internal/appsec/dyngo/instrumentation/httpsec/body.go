@@ -0,0 +1,304 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httpsec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// envBodyMaxSize is the name of the env var used to specify the maximum
+	// size, in bytes, of the request bodies read and parsed for the WAF, and of
+	// the response bodies held back until the request handler returns. Zero
+	// disables the request body parsing and the response body buffering.
+	envBodyMaxSize = "DD_APPSEC_HTTP_BODY_MAX_SIZE"
+	// defaultBodyMaxSize is the default maximum size of the request bodies read
+	// and parsed for the WAF.
+	defaultBodyMaxSize = 64 << 10
+)
+
+// bodyMaxSize is the maximum size of the request bodies read and parsed for
+// the WAF. Defined at init-time in the init() function below.
+var bodyMaxSize int64 = defaultBodyMaxSize
+
+func init() {
+	value, ok := os.LookupEnv(envBodyMaxSize)
+	if !ok {
+		return
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		log.Error("appsec: could not parse %s value `%s` as a positive number of bytes: using the default value of %d", envBodyMaxSize, value, defaultBodyMaxSize)
+		return
+	}
+	bodyMaxSize = size
+}
+
+// errBodyTooLarge is returned by readBody when the request body is larger than
+// the maximum size.
+var errBodyTooLarge = errors.New("request body too large")
+
+// bodyTruncatedTag is set on the span when the request body was larger than
+// the maximum size, and only its beginning could be monitored, if any.
+const bodyTruncatedTag = "_dd.appsec.request.body_truncated"
+
+// readBody reads the request body up to maxSize bytes, and replaces r.Body
+// with a body returning the same bytes so that the request handler can still
+// read it. It returns the first maxSize bytes along with errBodyTooLarge when
+// the body is larger than maxSize.
+func readBody(r *http.Request, maxSize int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body := r.Body
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), body), body}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return data[:maxSize], errBodyTooLarge
+	}
+	return data, nil
+}
+
+// bodyParser returns the function parsing the request bodies of the given
+// content type into the value of the address `server.request.body`, or nil
+// when the content type is not supported.
+func bodyParser(contentType string) func(data []byte) (interface{}, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return parseJSONBody
+	case mediaType == "application/x-www-form-urlencoded":
+		return parseFormBody
+	case mediaType == "multipart/form-data":
+		return func(data []byte) (interface{}, error) {
+			return parseMultipartBody(data, params["boundary"])
+		}
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return parseXMLBody
+	default:
+		return nil
+	}
+}
+
+// truncatedBodyParser returns the function parsing the beginning of the
+// request bodies of the given content type, when they are larger than the
+// maximum size, or nil when the format doesn't allow it. Only the complete
+// fields of the URL-encoded and multipart forms are parsed: the last field
+// may be cut.
+func truncatedBodyParser(contentType string) func(data []byte) (interface{}, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		return func(data []byte) (interface{}, error) {
+			i := bytes.LastIndexByte(data, '&')
+			if i < 0 {
+				return nil, errors.New("no complete form field")
+			}
+			return parseFormBody(data[:i])
+		}
+	case "multipart/form-data":
+		return func(data []byte) (interface{}, error) {
+			body, err := parseMultipartParts(data, params["boundary"])
+			if len(body) == 0 {
+				if err == nil {
+					err = errors.New("no complete form field")
+				}
+				return nil, err
+			}
+			return body, nil
+		}
+	default:
+		return nil
+	}
+}
+
+// ParseBody parses the given body according to its content type into the
+// value of the address `server.request.body`, as done by WrapHandler before
+// executing the handler. A nil value is returned when the content type is not
+// supported or the body is empty.
+func ParseBody(contentType string, data []byte) (interface{}, error) {
+	parse := bodyParser(contentType)
+	if parse == nil || len(data) == 0 {
+		return nil, nil
+	}
+	return parse(data)
+}
+
+func parseJSONBody(data []byte) (interface{}, error) {
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func parseFormBody(data []byte) (interface{}, error) {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]string(values), nil
+}
+
+// parseMultipartBody parses the multipart form data into the map of the form
+// values. File parts are not read, and their value is their file name.
+func parseMultipartBody(data []byte, boundary string) (map[string][]string, error) {
+	body, err := parseMultipartParts(data, boundary)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// parseMultipartParts parses the multipart form data as parseMultipartBody
+// does, but also returns the fields parsed before the error, if any.
+func parseMultipartParts(data []byte, boundary string) (map[string][]string, error) {
+	if boundary == "" {
+		return nil, errors.New("missing multipart boundary")
+	}
+	body := map[string][]string{}
+	mr := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return body, nil
+		}
+		if err != nil {
+			return body, err
+		}
+		name := part.FormName()
+		// part.FileName() is not used as it strips the directories of the
+		// file name, which could be part of the attack.
+		if _, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); params["filename"] != "" {
+			body[name] = append(body[name], params["filename"])
+			continue
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return body, err
+		}
+		body[name] = append(body[name], string(value))
+	}
+}
+
+// parseXMLBody parses the XML document into a generic value. Every element is
+// represented by a map of its name to the list of its content: the map of its
+// attributes, if any, followed by its child elements and non-blank texts.
+func parseXMLBody(data []byte) (interface{}, error) {
+	type element struct {
+		name    string
+		content []interface{}
+	}
+	var (
+		stack []*element
+		root  interface{}
+	)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: tok.Name.Local}
+			if len(tok.Attr) > 0 {
+				attrs := make(map[string]string, len(tok.Attr))
+				for _, a := range tok.Attr {
+					attrs[a.Name.Local] = a.Value
+				}
+				e.content = append(e.content, attrs)
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			v := map[string]interface{}{e.name: e.content}
+			if len(stack) == 0 {
+				root = v
+			} else {
+				parent := stack[len(stack)-1]
+				parent.content = append(parent.content, v)
+			}
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			if text := strings.TrimSpace(string(tok)); text != "" {
+				parent := stack[len(stack)-1]
+				parent.content = append(parent.content, text)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no xml element found")
+	}
+	return root, nil
+}
+
+// monitorBody reads and parses the request body, if any, and monitors it with
+// the SDK body operation so that the WAF runs on it before the request handler
+// executes. The request body remains readable by the handler. Only the
+// beginning of the bodies larger than the maximum size is monitored, when the
+// format allows it, see truncatedBodyParser.
+func monitorBody(op *Operation, r *http.Request) {
+	if bodyMaxSize == 0 {
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	parse := bodyParser(contentType)
+	if parse == nil {
+		return
+	}
+	data, err := readBody(r, bodyMaxSize)
+	if err == errBodyTooLarge {
+		op.AddTag(bodyTruncatedTag, true)
+		if parse = truncatedBodyParser(contentType); parse == nil {
+			log.Debug("appsec: ignoring the request body: larger than %d bytes", bodyMaxSize)
+			return
+		}
+	} else if err != nil {
+		log.Debug("appsec: ignoring the request body: %v", err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	body, err := parse(data)
+	if err != nil {
+		log.Debug("appsec: ignoring the request body: could not parse it as %s: %v", contentType, err)
+		return
+	}
+	StartSDKBodyOperation(op, SDKBodyOperationArgs{Body: body}).Finish()
+}
@@ -0,0 +1,315 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httpsec
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBody(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	require.NoError(t, mw.WriteField("name", "value"))
+	fw, err := mw.CreateFormFile("file", "../../etc/passwd")
+	require.NoError(t, err)
+	fw.Write([]byte("file content"))
+	require.NoError(t, mw.Close())
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		expected    interface{}
+		err         bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"a":[1,"b"]}`,
+			expected:    map[string]interface{}{"a": []interface{}{1.0, "b"}},
+		},
+		{
+			name:        "json-suffix",
+			contentType: "application/vnd.api+json",
+			body:        `"a"`,
+			expected:    "a",
+		},
+		{
+			name:        "json-invalid",
+			contentType: "application/json",
+			body:        `{"a":`,
+			err:         true,
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "a=1&a=2&b=3",
+			expected:    map[string][]string{"a": {"1", "2"}, "b": {"3"}},
+		},
+		{
+			name:        "multipart",
+			contentType: mw.FormDataContentType(),
+			body:        multipartBody.String(),
+			expected:    map[string][]string{"name": {"value"}, "file": {"../../etc/passwd"}},
+		},
+		{
+			name:        "multipart-no-boundary",
+			contentType: "multipart/form-data",
+			body:        multipartBody.String(),
+			err:         true,
+		},
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body:        `<?xml version="1.0"?><a id="1"> <b>text</b><c/></a>`,
+			expected: map[string]interface{}{"a": []interface{}{
+				map[string]string{"id": "1"},
+				map[string]interface{}{"b": []interface{}{"text"}},
+				map[string]interface{}{"c": []interface{}(nil)},
+			}},
+		},
+		{
+			name:        "xml-invalid",
+			contentType: "text/xml",
+			body:        `<a><b></a>`,
+			err:         true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parse := bodyParser(tc.contentType)
+			require.NotNil(t, parse)
+			body, err := parse([]byte(tc.body))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, body)
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		require.Nil(t, bodyParser("text/plain"))
+		require.Nil(t, bodyParser("invalid;;"))
+	})
+}
+
+func TestParseBodyContentType(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		expected    interface{}
+		err         bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"a":"b"}`,
+			expected:    map[string]interface{}{"a": "b"},
+		},
+		{
+			name:        "invalid",
+			contentType: "application/json",
+			body:        `{"a":`,
+			err:         true,
+		},
+		{
+			name:        "unsupported",
+			contentType: "text/plain",
+			body:        "a",
+		},
+		{
+			name:        "empty",
+			contentType: "application/json",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := ParseBody(tc.contentType, []byte(tc.body))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, body)
+		})
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	t.Run("restored", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		data, err := readBody(r, 10)
+		require.NoError(t, err)
+		require.Equal(t, "0123456789", string(data))
+		rest, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "0123456789", string(rest))
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		r.ContentLength = -1 // unknown length, e.g. chunked encoding
+		data, err := readBody(r, 5)
+		require.Equal(t, errBodyTooLarge, err)
+		require.Equal(t, "01234", string(data))
+		rest, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "0123456789", string(rest))
+
+		r = httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		data, err = readBody(r, 5)
+		require.Equal(t, errBodyTooLarge, err)
+		require.Equal(t, "01234", string(data))
+	})
+
+	t.Run("no-body", func(t *testing.T) {
+		data, err := readBody(httptest.NewRequest("GET", "/", nil), 10)
+		require.NoError(t, err)
+		require.Nil(t, data)
+	})
+}
+
+func TestTruncatedBodyParser(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	require.NoError(t, mw.WriteField("a", "1"))
+	require.NoError(t, mw.WriteField("b", "2"))
+	require.NoError(t, mw.Close())
+	multipartData := multipartBody.Bytes()
+	// cut in the value of the second field
+	cut := bytes.LastIndex(multipartData, []byte("2"))
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        []byte
+		expected    interface{}
+		err         bool
+	}{
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        []byte("a=1&b=2&c=trunc"),
+			expected:    map[string][]string{"a": {"1"}, "b": {"2"}},
+		},
+		{
+			name:        "form-single-field",
+			contentType: "application/x-www-form-urlencoded",
+			body:        []byte("a=trunc"),
+			err:         true,
+		},
+		{
+			name:        "multipart",
+			contentType: mw.FormDataContentType(),
+			body:        multipartData[:cut],
+			expected:    map[string][]string{"a": {"1"}},
+		},
+		{
+			name:        "multipart-first-field",
+			contentType: mw.FormDataContentType(),
+			body:        multipartData[:bytes.Index(multipartData, []byte("1"))],
+			err:         true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parse := truncatedBodyParser(tc.contentType)
+			require.NotNil(t, parse)
+			body, err := parse(tc.body)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, body)
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		require.Nil(t, truncatedBodyParser("application/json"))
+		require.Nil(t, truncatedBodyParser("application/xml"))
+	})
+}
+
+func TestWrapHandlerBody(t *testing.T) {
+	var monitored interface{}
+	unregister := dyngo.Register(OnHandlerOperationStart(func(op *Operation, _ HandlerOperationArgs) {
+		op.On(OnSDKBodyOperationStart(func(_ *SDKBodyOperation, args SDKBodyOperationArgs) {
+			monitored = args.Body
+			if m, ok := args.Body.(map[string]interface{}); ok && m["attack"] != nil {
+				block := NewBlockRequestAction(403, "json")
+				op.AddAction(&block)
+			}
+		}))
+	}))
+	defer unregister()
+
+	var span *testSpan
+	serve := func(contentType, body string) (*httptest.ResponseRecorder, string) {
+		var read []byte
+		span = &testSpan{tags: map[string]interface{}{}}
+		h := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			read, _ = io.ReadAll(r.Body)
+			w.Write([]byte("ok"))
+		}), span, nil)
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w, string(read)
+	}
+
+	t.Run("monitored", func(t *testing.T) {
+		w, read := serve("application/json", `{"a":"b"}`)
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, `{"a":"b"}`, read)
+		require.Equal(t, map[string]interface{}{"a": "b"}, monitored)
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		w, read := serve("application/json", `{"attack":1}`)
+		require.Equal(t, 403, w.Code)
+		require.Empty(t, read)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		defer func(size int64) { bodyMaxSize = size }(bodyMaxSize)
+		bodyMaxSize = 8
+
+		monitored = nil
+		w, read := serve("application/x-www-form-urlencoded", "a=1&b=2&c=3")
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, "a=1&b=2&c=3", read)
+		require.Equal(t, map[string][]string{"a": {"1"}, "b": {"2"}}, monitored)
+		require.Equal(t, true, span.tags[bodyTruncatedTag])
+
+		monitored = nil
+		w, read = serve("application/json", `{"a":"bcdefgh"}`)
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, `{"a":"bcdefgh"}`, read)
+		require.Nil(t, monitored)
+		require.Equal(t, true, span.tags[bodyTruncatedTag])
+	})
+}
+
+// testSpan is a ddtrace.Span recording its tags.
+type testSpan struct {
+	ddtrace.Span
+	tags map[string]interface{}
+}
+
+func (s *testSpan) SetTag(key string, value interface{}) { s.tags[key] = value }
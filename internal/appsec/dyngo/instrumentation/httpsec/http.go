@@ -51,6 +51,12 @@ type (
 	HandlerOperationRes struct {
 		// Status corresponds to the address `server.response.status`.
 		Status int
+		// Headers corresponds to the address `server.response.headers.no_cookies`.
+		Headers map[string][]string
+		// Body corresponds to the address `server.response.body`. It is only
+		// set for JSON response bodies held back in full by the response
+		// writer, see responseWriter.
+		Body interface{}
 	}
 
 	// SDKBodyOperationArgs is the SDK body operation arguments.
@@ -104,16 +110,49 @@ func WrapHandler(handler http.Handler, span ddtrace.Span, pathParams map[string]
 
 		if h := applyActions(op); h != nil {
 			handler = h
+		} else {
+			// Monitor the request body before the handler executes so that
+			// the request can still be blocked.
+			monitorBody(op, r)
+			if h := applyActions(op); h != nil {
+				handler = h
+			}
+		}
+		// Only hold the response back when the rules monitor it.
+		var rw *responseWriter
+		if monitoringResponse() {
+			rw = newResponseWriter(w, bodyMaxSize)
 		}
 		defer func() {
-			var status int
-			if mw, ok := w.(interface{ Status() int }); ok {
+			var (
+				status int
+				body   interface{}
+			)
+			if rw != nil {
+				status, body = rw.status, rw.responseBody()
+			}
+			if mw, ok := w.(interface{ Status() int }); ok && status == 0 {
 				status = mw.Status()
 			}
 
-			events := op.Finish(HandlerOperationRes{Status: status})
+			events := op.Finish(HandlerOperationRes{
+				Status:  status,
+				Headers: makeResponseHeaders(w.Header()),
+				Body:    body,
+			})
 			if h := applyActions(op); h != nil {
-				h.ServeHTTP(w, r)
+				// Replace the response by the blocking response, unless it
+				// was already written.
+				if rw != nil && rw.reset() {
+					h.ServeHTTP(w, r)
+				} else {
+					log.Debug("appsec: could not block the response: it was already written")
+				}
+			}
+			if rw != nil {
+				if err := rw.commit(); err != nil {
+					log.Debug("appsec: could not write the response: %v", err)
+				}
 			}
 			instrumentation.SetTags(span, op.Tags())
 			if len(events) == 0 {
@@ -122,8 +161,11 @@ func WrapHandler(handler http.Handler, span ddtrace.Span, pathParams map[string]
 			SetSecurityEventTags(span, events, args.Headers, w.Header())
 		}()
 
-		handler.ServeHTTP(w, r)
-
+		if rw != nil {
+			handler.ServeHTTP(rw, r)
+		} else {
+			handler.ServeHTTP(w, r)
+		}
 	})
 }
 
@@ -0,0 +1,214 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httpsec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// responseMonitoring is 1 when the loaded rules use server.response.*
+// addresses, see SetResponseMonitoring.
+var responseMonitoring int32
+
+// SetResponseMonitoring sets whether the loaded rules use server.response.*
+// addresses. WrapHandler only holds the responses back when they do, so that
+// the responses are not buffered for nothing.
+func SetResponseMonitoring(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&responseMonitoring, v)
+}
+
+func monitoringResponse() bool {
+	return atomic.LoadInt32(&responseMonitoring) == 1
+}
+
+// responseWriter is the response writer given to the request handlers by
+// WrapHandler. It holds back the response status code, headers and up to
+// maxSize bytes of the response body until the handler returns, so that the
+// WAF can monitor them and the response can still be replaced by a blocking
+// response. The response is written as soon as it is larger than maxSize, or
+// when the handler flushes or hijacks it, in which case it can no longer be
+// blocked.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     bytes.Buffer
+	maxSize int64
+	// committed is true once the response was written to the underlying
+	// response writer.
+	committed bool
+}
+
+func newResponseWriter(w http.ResponseWriter, maxSize int64) *responseWriter {
+	return &responseWriter{ResponseWriter: w, maxSize: maxSize}
+}
+
+// WriteHeader records the response status code. Informational 1xx status
+// codes are written right away, as they don't end the response headers.
+func (w *responseWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.committed || w.status != 0 {
+		return
+	}
+	w.status = status
+}
+
+// Write buffers the response body until it is larger than the maximum size.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.committed {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if int64(w.buf.Len()+len(b)) <= w.maxSize {
+		return w.buf.Write(b)
+	}
+	if err := w.commit(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom holds back the beginning of the response body read from src, as
+// Write does, and copies the rest of it to the underlying response writer, so
+// that it can still use its io.ReaderFrom implementation (e.g. sendfile).
+func (w *responseWriter) ReadFrom(src io.Reader) (n int64, err error) {
+	if !w.committed {
+		n, err = io.Copy(writerOnly{w}, io.LimitReader(src, w.maxSize-int64(w.buf.Len())+1))
+		if err != nil || !w.committed {
+			return n, err
+		}
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		m, err := rf.ReadFrom(src)
+		return n + m, err
+	}
+	m, err := io.Copy(w.ResponseWriter, src)
+	return n + m, err
+}
+
+// writerOnly hides the io.ReaderFrom implementation of the response writer
+// from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// Unwrap returns the underlying response writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush writes the response, and flushes it if the underlying response writer
+// is an http.Flusher.
+func (w *responseWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection if the underlying response writer is an
+// http.Hijacker.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not implement http.Hijacker")
+	}
+	w.committed = true
+	return h.Hijack()
+}
+
+// Push initiates an HTTP/2 server push if the underlying response writer is an
+// http.Pusher.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// commit writes the held back response to the underlying response writer.
+func (w *responseWriter) commit() error {
+	if w.committed {
+		return nil
+	}
+	w.committed = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// reset discards the held back response, including its headers, so that
+// another response can be written instead. It returns false when the response
+// was already written.
+func (w *responseWriter) reset() bool {
+	if w.committed {
+		return false
+	}
+	w.status = 0
+	w.buf.Reset()
+	h := w.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	return true
+}
+
+// makeResponseHeaders returns the response headers following the
+// specification of the rule address `server.response.headers.no_cookies`.
+func makeResponseHeaders(h http.Header) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+	headers := make(map[string][]string, len(h))
+	for k, v := range h {
+		k := strings.ToLower(k)
+		if k == "set-cookie" {
+			// Do not include cookies in the response headers
+			continue
+		}
+		headers[k] = v
+	}
+	return headers
+}
+
+// responseBody returns the parsed response body when it is held back in full
+// and is a JSON document, or nil otherwise.
+func (w *responseWriter) responseBody() interface{} {
+	if w.committed || w.buf.Len() == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	body, err := parseJSONBody(w.buf.Bytes())
+	if err != nil {
+		return nil
+	}
+	return body
+}
@@ -0,0 +1,194 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httpsec
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWriter(t *testing.T) {
+	t.Run("held-back", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := newResponseWriter(rec, 10)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(201)
+		w.Write([]byte(`{"a":`))
+		w.Write([]byte(`"b"}`))
+		require.False(t, rec.Flushed)
+		require.Empty(t, rec.Body.String())
+		require.Equal(t, map[string]interface{}{"a": "b"}, w.responseBody())
+
+		require.NoError(t, w.commit())
+		require.Equal(t, 201, rec.Code)
+		require.Equal(t, `{"a":"b"}`, rec.Body.String())
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := newResponseWriter(rec, 4)
+		w.Write([]byte("012"))
+		w.Write([]byte("345"))
+		require.Equal(t, 200, rec.Code)
+		require.Equal(t, "012345", rec.Body.String())
+		require.Nil(t, w.responseBody())
+		require.False(t, w.reset())
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := newResponseWriter(rec, 10)
+		w.Write([]byte("0"))
+		w.Flush()
+		require.True(t, rec.Flushed)
+		require.Equal(t, "0", rec.Body.String())
+		require.False(t, w.reset())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := newResponseWriter(rec, 10)
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(200)
+		w.Write([]byte("0"))
+		require.True(t, w.reset())
+		require.Empty(t, w.Header())
+		require.NoError(t, w.commit())
+		require.Empty(t, rec.Body.String())
+	})
+
+	t.Run("informational", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := newResponseWriter(rec, 10)
+		w.WriteHeader(103)
+		require.Equal(t, 103, rec.Code)
+		w.WriteHeader(201)
+		require.NoError(t, w.commit())
+		require.Equal(t, 201, w.status)
+	})
+
+	t.Run("read-from", func(t *testing.T) {
+		rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		w := newResponseWriter(rec, 4)
+		n, err := w.ReadFrom(strings.NewReader("012"))
+		require.NoError(t, err)
+		require.Equal(t, int64(3), n)
+		require.Empty(t, rec.Body.String())
+		n, err = w.ReadFrom(strings.NewReader("3456789"))
+		require.NoError(t, err)
+		require.Equal(t, int64(7), n)
+		require.Equal(t, "0123456789", rec.Body.String())
+		require.Equal(t, "56789", rec.readFrom)
+	})
+
+	t.Run("unwrap", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		require.Same(t, rec, newResponseWriter(rec, 10).Unwrap())
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		require.Nil(t, makeResponseHeaders(nil))
+		require.Equal(t, map[string][]string{"content-type": {"text/plain"}}, makeResponseHeaders(http.Header{
+			"Content-Type": {"text/plain"},
+			"Set-Cookie":   {"a=b"},
+		}))
+	})
+}
+
+// readerFromRecorder is a response recorder implementing io.ReaderFrom, which
+// records the bytes it read.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom string
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	b, err := io.ReadAll(src)
+	r.readFrom += string(b)
+	r.Write(b)
+	return int64(len(b)), err
+}
+
+func TestWrapHandlerResponse(t *testing.T) {
+	SetResponseMonitoring(true)
+	defer SetResponseMonitoring(false)
+	var monitored HandlerOperationRes
+	unregister := dyngo.Register(OnHandlerOperationStart(func(op *Operation, _ HandlerOperationArgs) {
+		op.On(OnHandlerOperationFinish(func(op *Operation, res HandlerOperationRes) {
+			monitored = res
+			if len(res.Headers["x-block"]) > 0 {
+				block := NewBlockRequestAction(403, "json")
+				op.AddAction(&block)
+			}
+		}))
+	}))
+	defer unregister()
+
+	serve := func(h http.HandlerFunc) *httptest.ResponseRecorder {
+		span := &testSpan{tags: map[string]interface{}{}}
+		w := httptest.NewRecorder()
+		WrapHandler(h, span, nil).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w
+	}
+
+	t.Run("monitored", func(t *testing.T) {
+		w := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "a=b")
+			w.WriteHeader(202)
+			w.Write([]byte(`{"a":"b"}`))
+		})
+		require.Equal(t, 202, w.Code)
+		require.Equal(t, `{"a":"b"}`, w.Body.String())
+		require.Equal(t, HandlerOperationRes{
+			Status:  202,
+			Headers: map[string][]string{"content-type": {"application/json"}},
+			Body:    map[string]interface{}{"a": "b"},
+		}, monitored)
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		w := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Block", "1")
+			w.Write([]byte("response"))
+		})
+		require.Equal(t, 403, w.Code)
+		require.Equal(t, blockedTemplateJSON, w.Body.Bytes())
+		require.Empty(t, w.Header().Get("X-Block"))
+	})
+
+	t.Run("already-written", func(t *testing.T) {
+		w := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Block", "1")
+			w.Write([]byte("response"))
+			w.(http.Flusher).Flush()
+		})
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, "response", w.Body.String())
+	})
+
+	t.Run("not-monitored", func(t *testing.T) {
+		SetResponseMonitoring(false)
+		defer SetResponseMonitoring(true)
+		var rw http.ResponseWriter
+		w := serve(func(w http.ResponseWriter, r *http.Request) {
+			rw = w
+			w.Header().Set("X-Block", "1")
+			w.Write([]byte("response"))
+		})
+		// the response is neither held back nor blocked
+		require.IsType(t, &httptest.ResponseRecorder{}, rw)
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, "response", w.Body.String())
+	})
+}
@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httpsec
+
+import (
+	"context"
+	"reflect"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+)
+
+type (
+	// RoundTripOperation type representing an outbound HTTP request sent
+	// while handling a monitored HTTP request. It gets both created and
+	// destroyed in a single call to ExecuteRoundTripOperation.
+	RoundTripOperation struct {
+		dyngo.Operation
+		// Error is set by the operation listeners when the outbound request
+		// must be blocked.
+		Error error
+	}
+	// RoundTripOperationArgs is the round trip operation arguments.
+	RoundTripOperationArgs struct {
+		// URL corresponds to the address `server.io.net.url`.
+		URL string
+	}
+	// RoundTripOperationRes is the round trip operation results.
+	RoundTripOperationRes struct{}
+
+	// OnRoundTripOperationStart function type, called when a round trip
+	// operation starts.
+	OnRoundTripOperationStart func(*RoundTripOperation, RoundTripOperationArgs)
+)
+
+var roundTripOperationArgsType = reflect.TypeOf((*RoundTripOperationArgs)(nil)).Elem()
+
+// ExecuteRoundTripOperation starts and finishes the round trip operation by
+// emitting a dyngo start and finish events. An error is returned if the
+// outbound request must be blocked.
+func ExecuteRoundTripOperation(parent dyngo.Operation, args RoundTripOperationArgs) error {
+	op := &RoundTripOperation{Operation: dyngo.NewOperation(parent)}
+	dyngo.StartOperation(op, args)
+	dyngo.FinishOperation(op, RoundTripOperationRes{})
+	return op.Error
+}
+
+// ListenedType returns the type a OnRoundTripOperationStart event listener
+// listens to, which is the RoundTripOperationArgs type.
+func (OnRoundTripOperationStart) ListenedType() reflect.Type { return roundTripOperationArgsType }
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnRoundTripOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*RoundTripOperation), v.(RoundTripOperationArgs))
+}
+
+// ProtectRoundTrip starts and finishes a round trip operation in the HTTP
+// handler operation of the given context, if any. The WAF checks the URL of the
+// outbound request against the inputs of the monitored HTTP request, and a
+// *sharedsec.ExploitPreventionError is returned if the outbound request must be
+// blocked. The return value is nil otherwise, and when the context is not the
+// context of a monitored HTTP request.
+func ProtectRoundTrip(ctx context.Context, url string) error {
+	parent, ok := ctx.Value(instrumentation.ContextKey{}).(dyngo.Operation)
+	if !ok {
+		return nil
+	}
+	return ExecuteRoundTripOperation(parent, RoundTripOperationArgs{URL: url})
+}
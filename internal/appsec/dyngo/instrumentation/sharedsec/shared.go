@@ -37,6 +37,12 @@ type (
 	UserMonitoringError struct {
 		error
 	}
+
+	// ExploitPreventionError is the error returned to the callers of the outbound operations blocked by the exploit
+	// prevention, such as the HTTP requests and SQL queries of SSRF and SQL injection exploits.
+	ExploitPreventionError struct {
+		error
+	}
 )
 
 // NewUserMonitoringError creates a new user monitoring error that returns `msg` upon calling `Error()`
@@ -46,6 +52,13 @@ func NewUserMonitoringError(msg string) *UserMonitoringError {
 	}
 }
 
+// NewExploitPreventionError creates a new exploit prevention error that returns `msg` upon calling `Error()`
+func NewExploitPreventionError(msg string) *ExploitPreventionError {
+	return &ExploitPreventionError{
+		errors.New(msg),
+	}
+}
+
 var userIDOperationArgsType = reflect.TypeOf((*UserIDOperationArgs)(nil)).Elem()
 
 // ExecuteUserIDOperation starts and finishes the UserID operation by emitting a dyngo start and finish events
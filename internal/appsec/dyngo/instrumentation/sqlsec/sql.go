@@ -0,0 +1,78 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package sqlsec defines the SQL instrumentation API and contract for AppSec.
+// It defines an abstract representation of the SQL queries that SQL
+// integrations must use to enable the AppSec exploit prevention of SQL
+// injections, which listens to this package's operation events.
+package sqlsec
+
+import (
+	"context"
+	"reflect"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation"
+)
+
+type (
+	// SQLOperation type representing a SQL query sent while handling a
+	// monitored HTTP request. It gets both created and destroyed in a single
+	// call to ExecuteSQLOperation.
+	SQLOperation struct {
+		dyngo.Operation
+		// Error is set by the operation listeners when the query must be
+		// blocked.
+		Error error
+	}
+	// SQLOperationArgs is the SQL operation arguments.
+	SQLOperationArgs struct {
+		// Query corresponds to the address `server.db.statement`.
+		Query string
+		// System corresponds to the address `server.db.system`.
+		System string
+	}
+	// SQLOperationRes is the SQL operation results.
+	SQLOperationRes struct{}
+
+	// OnSQLOperationStart function type, called when a SQL operation starts.
+	OnSQLOperationStart func(*SQLOperation, SQLOperationArgs)
+)
+
+var sqlOperationArgsType = reflect.TypeOf((*SQLOperationArgs)(nil)).Elem()
+
+// ExecuteSQLOperation starts and finishes the SQL operation by emitting a
+// dyngo start and finish events. An error is returned if the query must be
+// blocked.
+func ExecuteSQLOperation(parent dyngo.Operation, args SQLOperationArgs) error {
+	op := &SQLOperation{Operation: dyngo.NewOperation(parent)}
+	dyngo.StartOperation(op, args)
+	dyngo.FinishOperation(op, SQLOperationRes{})
+	return op.Error
+}
+
+// ListenedType returns the type a OnSQLOperationStart event listener listens
+// to, which is the SQLOperationArgs type.
+func (OnSQLOperationStart) ListenedType() reflect.Type { return sqlOperationArgsType }
+
+// Call calls the underlying event listener function by performing the
+// type-assertion on v whose type is the one returned by ListenedType().
+func (f OnSQLOperationStart) Call(op dyngo.Operation, v interface{}) {
+	f(op.(*SQLOperation), v.(SQLOperationArgs))
+}
+
+// ProtectSQLOperation starts and finishes a SQL operation in the HTTP handler
+// operation of the given context, if any. The WAF checks the query against the
+// inputs of the monitored HTTP request, and a
+// *sharedsec.ExploitPreventionError is returned if the query must be blocked.
+// The return value is nil otherwise, and when the context is not the context of
+// a monitored HTTP request.
+func ProtectSQLOperation(ctx context.Context, query, system string) error {
+	parent, ok := ctx.Value(instrumentation.ContextKey{}).(dyngo.Operation)
+	if !ok {
+		return nil
+	}
+	return ExecuteSQLOperation(parent, SQLOperationArgs{Query: query, System: system})
+}
@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sharedsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	waf "github.com/DataDog/go-libddwaf"
+)
+
+const (
+	// exploitStackTag is the span tag holding the stack trace of the blocked or monitored exploit
+	exploitStackTag = "_dd.appsec.exploit.stack"
+	// exploitStackMaxDepth is the maximum number of stack frames reported in exploitStackTag
+	exploitStackMaxDepth = 32
+	// internalPackagePrefix is the package path prefix of the tracer internal packages, skipped from stack traces
+	internalPackagePrefix = "gopkg.in/DataDog/dd-trace-go.v1/internal/"
+)
+
+// runExploitPrevention runs the WAF on the given values of an operation happening during the execution of the
+// HTTP handler operation op, such as an outgoing HTTP request or a SQL query. When the WAF detects an exploit,
+// the stack trace of the operation is added to the span and the actions are applied to op. It returns whether a
+// rule was triggered, and a non-nil error when the operation must not be performed.
+// A rule only matches once per WAF context, so every operation is evaluated in a WAF context of its own for all
+// the exploits of the request to be detected. The context is first given the request values, the addresses of
+// the request the exploit rules depend on, so that only the matches of the operation values get reported.
+func runExploitPrevention(op *httpsec.Operation, handle *waf.Handle, requestValues, values map[string]interface{}, timeout time.Duration, actionHandler *httpsec.ActionsHandler, requestLimits *requestRateLimits, kind string) (bool, error) {
+	wafCtx := waf.NewContext(handle)
+	if wafCtx == nil {
+		// The WAF handle got concurrently released
+		return false, nil
+	}
+	defer wafCtx.Close()
+	runWAF(wafCtx, requestValues, timeout)
+	matches, actionIds := runWAF(wafCtx, values, timeout)
+	if len(matches) == 0 {
+		return false, nil
+	}
+	interrupt := false
+	for _, id := range requestLimits.filter(actionIds) {
+		interrupt = actionHandler.Apply(id, op) || interrupt
+	}
+	op.AddTag(exploitStackTag, takeStackTrace(exploitStackMaxDepth))
+	op.AddSecurityEvents(matches)
+	log.Debug("appsec: WAF detected a %s exploit attempt", kind)
+	if !interrupt {
+		return true, nil
+	}
+	return true, sharedsec.NewExploitPreventionError(fmt.Sprintf("%s exploit attempt blocked", kind))
+}
+
+// takeStackTrace returns the stack trace of the calling goroutine, made of at most max frames and without the
+// frames of the Go runtime and of the tracer internal packages.
+func takeStackTrace(max int) string {
+	pcs := make([]uintptr, 128)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for depth := 0; depth < max; {
+		frame, more := frames.Next()
+		if !isInternalFunction(frame.Function) {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+			depth++
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// isInternalFunction returns true when the given fully-qualified function name belongs to the Go runtime or to the
+// tracer internal packages, excluding their tests.
+func isInternalFunction(fn string) bool {
+	pkg := functionPackage(fn)
+	if pkg == "" || pkg == "runtime" {
+		return true
+	}
+	return strings.HasPrefix(pkg, internalPackagePrefix) && !strings.HasSuffix(pkg, "_test")
+}
+
+// functionPackage returns the package path of the given fully-qualified function name, e.g. net/http for
+// net/http.(*Client).Do.
+func functionPackage(fn string) string {
+	slash := strings.LastIndexByte(fn, '/')
+	if dot := strings.IndexByte(fn[slash+1:], '.'); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
@@ -146,6 +146,8 @@ func (a *appsec) onRCRulesUpdate(updates map[string]remoteconfig.ProductUpdate)
 		return map[string]rc.ApplyStatus{}
 	}
 
+	a.rulesMu.Lock()
+	defer a.rulesMu.Unlock()
 	r := a.cfg.rulesManager.clone()
 	statuses, err := combineRCRulesUpdates(r, updates)
 	if err != nil {
@@ -368,6 +370,7 @@ func (a *appsec) enableRCBlocking() {
 		a.registerRCCapability(remoteconfig.ASMIPBlocking)
 		a.registerRCCapability(remoteconfig.ASMDDRules)
 		a.registerRCCapability(remoteconfig.ASMExclusions)
+		a.registerRCCapability(remoteconfig.ASMCustomBlockingResponse)
 	}
 }
 
@@ -380,4 +383,5 @@ func (a *appsec) disableRCBlocking() {
 	a.unregisterRCCapability(remoteconfig.ASMIPBlocking)
 	a.unregisterRCCapability(remoteconfig.ASMRequestBlocking)
 	a.unregisterRCCapability(remoteconfig.ASMUserBlocking)
+	a.unregisterRCCapability(remoteconfig.ASMCustomBlockingResponse)
 }
@@ -9,8 +9,10 @@
 package appsec
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net/netip"
 	"os"
 	"reflect"
 	"sort"
@@ -21,6 +23,7 @@ import (
 	waf "github.com/DataDog/go-libddwaf"
 	"github.com/stretchr/testify/require"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
 )
 
@@ -375,7 +378,7 @@ func TestCapabilities(t *testing.T) {
 			env:  map[string]string{enabledEnvVar: "1"},
 			expected: []remoteconfig.Capability{
 				remoteconfig.ASMRequestBlocking, remoteconfig.ASMUserBlocking, remoteconfig.ASMExclusions,
-				remoteconfig.ASMDDRules, remoteconfig.ASMIPBlocking,
+				remoteconfig.ASMDDRules, remoteconfig.ASMIPBlocking, remoteconfig.ASMCustomBlockingResponse,
 			},
 		},
 		{
@@ -696,7 +699,7 @@ func TestWafRCUpdate(t *testing.T) {
 		wafCtx := waf.NewContext(wafHandle)
 		defer wafCtx.Close()
 		values := map[string]interface{}{
-			serverRequestPathParamsAddr: "/rfiinc.txt",
+			ServerRequestPathParamsAddr: "/rfiinc.txt",
 		}
 		// Make sure the rule matches as expected
 		matches, actions := runWAF(wafCtx, values, cfg.wafTimeout)
@@ -718,4 +721,44 @@ func TestWafRCUpdate(t *testing.T) {
 		require.NotEmpty(t, matches)
 		require.Contains(t, actions, "block")
 	})
+
+	t.Run("rate-limiting", func(t *testing.T) {
+		cfg, err := newConfig()
+		require.NoError(t, err)
+		a := newAppSec(cfg)
+		require.NoError(t, a.start())
+		defer a.stop()
+		// Rate-limit the security scanner requests through an ASM config
+		rateLimit := rulesFragment{
+			Overrides: []rulesOverrideEntry{
+				{
+					ID:      "ua0-600-12x",
+					Enabled: true,
+					OnMatch: []string{"rate-limit-scanner"},
+				},
+			},
+			Actions: []interface{}{
+				map[string]interface{}{
+					"id":         "rate-limit-scanner",
+					"type":       rateLimitRequestActionType,
+					"parameters": map[string]interface{}{"threshold": 1, "period": 60},
+				},
+			},
+		}
+		statuses := a.onRCRulesUpdate(craftRCUpdates(map[string]rulesFragment{"rate-limit": rateLimit}))
+		for _, status := range statuses {
+			require.Equal(t, rc.ApplyStateAcknowledged, status.State)
+		}
+
+		scan := func() []httpsec.Action {
+			_, op := httpsec.StartOperation(context.Background(), httpsec.HandlerOperationArgs{
+				ClientIP: netip.MustParseAddr("1.2.3.4"),
+				Headers:  map[string][]string{"user-agent": {"Arachni/v1"}},
+			})
+			defer op.Finish(httpsec.HandlerOperationRes{Status: 200})
+			return op.Actions()
+		}
+		require.Empty(t, scan())
+		require.Len(t, scan(), 1)
+	})
 }
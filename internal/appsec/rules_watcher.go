@@ -0,0 +1,144 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"os"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// rulesWatcher polls the local rules file and rules fragments directories, and calls the reload function when any
+// of their files is added, modified or removed.
+type rulesWatcher struct {
+	cfg      rulesReloadConfig
+	reload   func() error
+	snapshot map[string]rulesFileState
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// rulesFileState is the state of a watched file used to detect its changes.
+type rulesFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func newRulesWatcher(cfg rulesReloadConfig, reload func() error) *rulesWatcher {
+	return &rulesWatcher{
+		cfg:      cfg,
+		reload:   reload,
+		snapshot: snapshotRulesFiles(cfg),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (w *rulesWatcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			snapshot := snapshotRulesFiles(w.cfg)
+			if equalRulesSnapshots(snapshot, w.snapshot) {
+				continue
+			}
+			// Keep the new snapshot even when the reload fails in order to retry only once the files change again
+			w.snapshot = snapshot
+			if err := w.reload(); err != nil {
+				log.Error("appsec: could not reload the security rules, keeping the previous ones: %v", err)
+			}
+		}
+	}
+}
+
+// snapshotRulesFiles returns the state of the watched files, keyed by path. Missing files are not part of it.
+func snapshotRulesFiles(cfg rulesReloadConfig) map[string]rulesFileState {
+	files := rulesFragmentsFiles(cfg.fragmentsDirs)
+	if cfg.rulesFile != "" {
+		files = append(files, cfg.rulesFile)
+	}
+	snapshot := make(map[string]rulesFileState, len(files))
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		snapshot[path] = rulesFileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return snapshot
+}
+
+func equalRulesSnapshots(a, b map[string]rulesFileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(state.modTime) || other.size != state.size {
+			return false
+		}
+	}
+	return true
+}
+
+// startRulesWatcher starts watching the local rules files when their hot reload is enabled.
+func (a *appsec) startRulesWatcher() {
+	cfg := a.cfg.rulesReload
+	if cfg.interval <= 0 || (cfg.rulesFile == "" && len(cfg.fragmentsDirs) == 0) {
+		return
+	}
+	log.Debug("appsec: watching the local security rules every %s", cfg.interval)
+	a.rulesWatcher = newRulesWatcher(cfg, a.reloadLocalRules)
+	go a.rulesWatcher.run()
+}
+
+// stopRulesWatcher stops watching the local rules files and waits for any ongoing reload to return.
+func (a *appsec) stopRulesWatcher() {
+	if a.rulesWatcher == nil {
+		return
+	}
+	close(a.rulesWatcher.stop)
+	<-a.rulesWatcher.done
+	a.rulesWatcher = nil
+}
+
+// reloadLocalRules merges the current local rules file and rules fragments with the remote configuration ones, and
+// swaps the WAF with the resulting security rules. The current rules are kept when the new ones fail to load.
+func (a *appsec) reloadLocalRules() error {
+	cfg := a.cfg.rulesReload
+	a.rulesMu.Lock()
+	defer a.rulesMu.Unlock()
+
+	r := a.cfg.rulesManager.clone()
+	// The base rules received through ASM_DD take precedence over the local rules file
+	if cfg.rulesFile != "" && r.basePath == "" {
+		base, err := readRulesFile(cfg.rulesFile)
+		if err != nil {
+			return err
+		}
+		r.changeBase(base, "")
+	}
+	fragments, err := readRulesFragments(cfg.fragmentsDirs)
+	if err != nil {
+		return err
+	}
+	r.setLocalFragments(fragments)
+	r.compile()
+	if err := a.swapWAF(r.raw()); err != nil {
+		return err
+	}
+	a.cfg.rulesManager = r
+	log.Info("appsec: reloaded the local security rules")
+	return nil
+}
@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+
+	waf "github.com/DataDog/go-libddwaf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesWatcher(t *testing.T) {
+	if waf.Health() != nil {
+		t.Skip("WAF needs to be available for this test")
+	}
+	const interval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	rulesFile := filepath.Join(dir, "rules.json")
+	fragmentsDir := filepath.Join(dir, "fragments")
+	require.NoError(t, os.Mkdir(fragmentsDir, 0755))
+	blockingRules, err := os.ReadFile("testdata/blocking.json")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(rulesFile, blockingRules, 0644))
+	t.Setenv(rulesEnvVar, rulesFile)
+	t.Setenv(rulesFragmentsEnvVar, fragmentsDir)
+	t.Setenv(rulesReloadEnvVar, interval.String())
+
+	cfg, err := newConfig()
+	require.NoError(t, err)
+	a := newAppSec(cfg)
+	require.NoError(t, a.start())
+	defer a.stop()
+
+	// matches returns whether the request of the given args matches the given rule
+	matches := func(args httpsec.HandlerOperationArgs, rule string) bool {
+		_, op := httpsec.StartOperation(context.Background(), args)
+		events := op.Finish(httpsec.HandlerOperationRes{Status: 200})
+		return strings.Contains(fmt.Sprintf("%s", events), rule)
+	}
+	blockedIP := httpsec.HandlerOperationArgs{ClientIP: netip.MustParseAddr("1.2.3.4")}
+	custom := httpsec.HandlerOperationArgs{Headers: map[string][]string{"x-custom": {"hot-reload"}}}
+	rulesVersion := func() interface{} {
+		a.rulesMu.Lock()
+		defer a.rulesMu.Unlock()
+		return a.cfg.rulesManager.latest.Metadata.(map[string]interface{})["rules_version"]
+	}
+
+	require.True(t, matches(blockedIP, "blk-001-001"))
+	require.False(t, matches(custom, "custom-001"))
+
+	t.Run("custom-rules", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(fragmentsDir, "custom.json"), []byte(`{
+			"rules": [{
+				"id": "custom-001",
+				"name": "Custom rule",
+				"tags": {"type": "custom", "category": "attack_attempt"},
+				"conditions": [{
+					"operator": "match_regex",
+					"parameters": {"inputs": [{"address": "server.request.headers.no_cookies", "key_path": ["x-custom"]}], "regex": "^hot-reload$"}
+				}],
+				"transformers": []
+			}]
+		}`), 0644))
+		require.Eventually(t, func() bool { return matches(custom, "custom-001") }, time.Second, interval)
+		require.True(t, matches(blockedIP, "blk-001-001"))
+	})
+
+	t.Run("exclusions", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(fragmentsDir, "exclusions.json"), []byte(`{
+			"exclusions": [{"id": "exc-001", "rules_target": [{"rule_id": "blk-001-001"}]}]
+		}`), 0644))
+		require.Eventually(t, func() bool { return !matches(blockedIP, "blk-001-001") }, time.Second, interval)
+		require.True(t, matches(custom, "custom-001"))
+	})
+
+	t.Run("invalid-rules", func(t *testing.T) {
+		// The previous rules are kept when the new ones cannot be loaded
+		require.NoError(t, os.WriteFile(rulesFile, []byte(`{`), 0644))
+		time.Sleep(10 * interval)
+		require.True(t, matches(custom, "custom-001"))
+		require.False(t, matches(blockedIP, "blk-001-001"))
+
+		require.NoError(t, os.WriteFile(filepath.Join(fragmentsDir, "invalid.json"), []byte(`{"exclusions":[{"id":"invalid"}]}`), 0644))
+		time.Sleep(10 * interval)
+		require.True(t, matches(custom, "custom-001"))
+		require.Equal(t, "1.4.2", rulesVersion())
+	})
+
+	t.Run("rules-file", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(fragmentsDir, "invalid.json")))
+		require.NoError(t, os.Remove(filepath.Join(fragmentsDir, "exclusions.json")))
+		require.NoError(t, os.WriteFile(rulesFile, []byte(strings.Replace(string(blockingRules), "1.4.2", "1.4.3", 1)), 0644))
+		require.Eventually(t, func() bool { return rulesVersion() == "1.4.3" }, time.Second, interval)
+		require.True(t, matches(blockedIP, "blk-001-001"))
+		require.True(t, matches(custom, "custom-001"))
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		a.stop()
+		require.Nil(t, a.rulesWatcher)
+		require.False(t, matches(blockedIP, "blk-001-001"))
+	})
+}
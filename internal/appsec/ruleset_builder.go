@@ -7,6 +7,11 @@ package appsec
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
@@ -103,9 +108,11 @@ func (r_ *rulesFragment) clone() rulesFragment {
 	var f rulesFragment
 	f.Version = r_.Version
 	f.Metadata = r_.Metadata
+	f.Rules = append(f.Rules, r_.Rules...)
 	f.Overrides = append(f.Overrides, r_.Overrides...)
 	f.Exclusions = append(f.Exclusions, r_.Exclusions...)
 	f.RulesData = append(f.RulesData, r_.RulesData...)
+	f.Actions = append(f.Actions, r_.Actions...)
 	// TODO (Francois Mazeau): copy more fields once we handle them
 	return f
 }
@@ -155,9 +162,15 @@ func (r *rulesManager) compile() {
 		r.base = defaultRulesFragment()
 	}
 	r.latest = r.base
+	// Avoid appending the rules of the edits to the base rules
+	r.latest.Rules = r.base.Rules[:len(r.base.Rules):len(r.base.Rules)]
 
 	// Simply concatenate the content of each top level rule field as specified in our RFCs
-	for _, v := range r.edits {
+	for k, v := range r.edits {
+		// Custom rules are only supported in the local rules fragments
+		if strings.HasPrefix(k, localFragmentEditPrefix) {
+			r.latest.Rules = append(r.latest.Rules, v.Rules...)
+		}
 		r.latest.Overrides = append(r.latest.Overrides, v.Overrides...)
 		r.latest.Exclusions = append(r.latest.Exclusions, v.Exclusions...)
 		r.latest.Actions = append(r.latest.Actions, v.Actions...)
@@ -165,6 +178,76 @@ func (r *rulesManager) compile() {
 	}
 }
 
+// localFragmentEditPrefix prefixes the keys of the rulesManager edits holding the local rules fragments files, so
+// that they don't collide with the remote configuration ones.
+const localFragmentEditPrefix = "file:"
+
+// setLocalFragments replaces the local rules fragments edits of the rulesManager with the given fragments, keyed by
+// file path.
+func (r *rulesManager) setLocalFragments(fragments map[string]rulesFragment) {
+	for k := range r.edits {
+		if strings.HasPrefix(k, localFragmentEditPrefix) {
+			r.removeEdit(k)
+		}
+	}
+	for path, f := range fragments {
+		r.addEdit(localFragmentEditPrefix+path, f)
+	}
+}
+
+// readRulesFile reads the full ruleset of the given rules file.
+func readRulesFile(path string) (rulesFragment, error) {
+	var f rulesFragment
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return f, fmt.Errorf("could not parse the rules file %s: %v", path, err)
+	}
+	if len(f.Rules) == 0 {
+		return f, fmt.Errorf("no rules found in the rules file %s", path)
+	}
+	return f, nil
+}
+
+// readRulesFragments reads the rules fragments of the JSON files of the given directories, such as exclusions or
+// custom rules, and returns them keyed by file path. An error is returned when any of them can't be read or is not
+// a valid fragment.
+func readRulesFragments(dirs []string) (map[string]rulesFragment, error) {
+	fragments := make(map[string]rulesFragment)
+	for _, path := range rulesFragmentsFiles(dirs) {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f rulesFragment
+		if err := json.Unmarshal(buf, &f); err != nil {
+			return nil, fmt.Errorf("could not parse the rules fragment %s: %v", path, err)
+		}
+		if !f.validate() {
+			return nil, fmt.Errorf("invalid rules fragment %s", path)
+		}
+		fragments[path] = f
+	}
+	return fragments, nil
+}
+
+// rulesFragmentsFiles returns the sorted paths of the JSON files of the given directories.
+func rulesFragmentsFiles(dirs []string) []string {
+	var files []string
+	for _, dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			log.Debug("appsec: could not list the rules fragments of the directory %s: %v", dir, err)
+			continue
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
+
 // raw returns a compact json version of the rules
 func (r *rulesManager) raw() []byte {
 	data, _ := json.Marshal(r.latest)
@@ -0,0 +1,326 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// API Security span tags holding the schemas of the requests and responses
+const (
+	apiSecRequestQueryTag     = "_dd.appsec.s.req.query"
+	apiSecRequestHeadersTag   = "_dd.appsec.s.req.headers"
+	apiSecRequestCookiesTag   = "_dd.appsec.s.req.cookies"
+	apiSecRequestParamsTag    = "_dd.appsec.s.req.params"
+	apiSecRequestBodyTag      = "_dd.appsec.s.req.body"
+	apiSecResponseHeadersTag  = "_dd.appsec.s.res.headers"
+	apiSecResponseBodyTag     = "_dd.appsec.s.res.body"
+	apiSecMaxDepth            = 18  // Maximum depth of the schemas
+	apiSecMaxContainerSize    = 256 // Maximum number of object keys and array items inspected
+	apiSecMaxArrayItemSchemas = 10  // Maximum number of distinct array item schemas
+)
+
+// Types of the scalar values in the schemas
+const (
+	schemaNull   = 1
+	schemaBool   = 2
+	schemaInt    = 4
+	schemaString = 8
+	schemaFloat  = 16
+)
+
+// piiScanner classifies the string values of the schemas whose value matches.
+type piiScanner struct {
+	category string
+	typ      string
+	value    *regexp.Regexp
+	// check is an optional extra validation of the matching value
+	check func(string) bool
+}
+
+var piiScanners = []piiScanner{
+	{
+		category: "pii",
+		typ:      "email",
+		value:    regexp.MustCompile(`^[\w.+-]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)+$`),
+	},
+	{
+		category: "pii",
+		typ:      "us_ssn",
+		value:    regexp.MustCompile(`^\d{3}-\d{2}-\d{4}$`),
+	},
+	{
+		category: "payment",
+		typ:      "card",
+		value:    regexp.MustCompile(`^(?:\d[ -]?){12,18}\d$`),
+		check:    luhn,
+	},
+}
+
+// luhn returns true when the digits of the given string pass the Luhn checksum
+// of card numbers.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// apiSecSampler decides which requests get their schemas extracted.
+type apiSecSampler struct {
+	rate float64
+}
+
+func newAPISecSampler(cfg APISecConfig) apiSecSampler {
+	if !cfg.Enabled {
+		return apiSecSampler{}
+	}
+	return apiSecSampler{rate: cfg.SampleRate}
+}
+
+// sample returns true when the schemas of the current request must be extracted.
+func (s apiSecSampler) sample() bool {
+	return s.rate > 0 && rand.Float64() < s.rate
+}
+
+// addAPISecTags extracts the schemas of the given request and response data and
+// adds them to the operation as gzip-compressed and base64-encoded span tags.
+func addAPISecTags(op *httpsec.Operation, args httpsec.HandlerOperationArgs, requestBody interface{}, res httpsec.HandlerOperationRes) {
+	for tag, value := range map[string]interface{}{
+		apiSecRequestQueryTag:    args.Query,
+		apiSecRequestHeadersTag:  args.Headers,
+		apiSecRequestCookiesTag:  args.Cookies,
+		apiSecRequestParamsTag:   args.PathParams,
+		apiSecRequestBodyTag:     requestBody,
+		apiSecResponseHeadersTag: res.Headers,
+		apiSecResponseBodyTag:    res.Body,
+	} {
+		if isEmptySchemaValue(value) {
+			continue
+		}
+		encoded, err := encodeSchema(extractSchema(value))
+		if err != nil {
+			log.Debug("appsec: could not encode the schema of %s: %v", tag, err)
+			continue
+		}
+		op.AddTag(tag, encoded)
+	}
+}
+
+func isEmptySchemaValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	}
+	return false
+}
+
+// encodeSchema returns the gzip-compressed and base64-encoded JSON
+// representation of the schema.
+func encodeSchema(schema interface{}) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(schema); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// extractSchema returns the schema of the given value:
+//   - scalars are represented by their type in an array, followed by the
+//     category and type of their value when it is sensitive, e.g. [8] or
+//     [8,{"category":"pii","type":"email"}];
+//   - objects, and structs, by the schemas of their keys in an array, e.g.
+//     [{"a":[8]}];
+//   - arrays by the distinct schemas of their items, followed by their length,
+//     e.g. [[[8],[4]],{"len":3}].
+//
+// Containers which were not fully inspected are marked as truncated.
+func extractSchema(v interface{}) interface{} {
+	return extractSchemaValue(reflect.ValueOf(v), 0)
+}
+
+func extractSchemaValue(v reflect.Value, depth int) interface{} {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) {
+		if v.IsNil() {
+			return []interface{}{schemaNull}
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return []interface{}{schemaNull}
+	}
+	if n, ok := v.Interface().(json.Number); ok {
+		if _, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return []interface{}{schemaInt}
+		}
+		return []interface{}{schemaFloat}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return []interface{}{schemaBool}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return []interface{}{schemaInt}
+	case reflect.Float32, reflect.Float64:
+		return []interface{}{schemaFloat}
+	case reflect.String:
+		return extractStringSchema(v.String())
+	case reflect.Map:
+		return extractObjectSchema(v, depth)
+	case reflect.Struct:
+		return extractStructSchema(v, depth)
+	case reflect.Slice, reflect.Array:
+		return extractArraySchema(v, depth)
+	}
+	return []interface{}{schemaNull}
+}
+
+func extractStringSchema(s string) interface{} {
+	for _, scanner := range piiScanners {
+		if scanner.value.MatchString(s) && (scanner.check == nil || scanner.check(s)) {
+			return []interface{}{schemaString, map[string]string{"category": scanner.category, "type": scanner.typ}}
+		}
+	}
+	return []interface{}{schemaString}
+}
+
+func extractObjectSchema(v reflect.Value, depth int) interface{} {
+	if depth >= apiSecMaxDepth {
+		return []interface{}{map[string]interface{}{}, map[string]bool{"truncated": true}}
+	}
+	fields := make(map[string]reflect.Value, v.Len())
+	for _, k := range v.MapKeys() {
+		fields[mapKeyString(k)] = v.MapIndex(k)
+	}
+	return extractFieldsSchema(fields, depth)
+}
+
+// extractStructSchema returns the schema of a struct as the object of its
+// exported fields, named after their json tag when present, as the WAF
+// encodes them.
+func extractStructSchema(v reflect.Value, depth int) interface{} {
+	if depth >= apiSecMaxDepth {
+		return []interface{}{map[string]interface{}{}, map[string]bool{"truncated": true}}
+	}
+	typ := v.Type()
+	fields := make(map[string]reflect.Value, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if i := strings.IndexByte(tag, ','); i >= 0 {
+				tag = tag[:i]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields[name] = v.Field(i)
+	}
+	return extractFieldsSchema(fields, depth)
+}
+
+// extractFieldsSchema returns the schema of the object of the given fields.
+func extractFieldsSchema(fields map[string]reflect.Value, depth int) interface{} {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	// Sort the keys so that the inspected keys are the same ones when truncating
+	sort.Strings(keys)
+	truncated := len(keys) > apiSecMaxContainerSize
+	if truncated {
+		keys = keys[:apiSecMaxContainerSize]
+	}
+	object := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		object[k] = extractSchemaValue(fields[k], depth+1)
+	}
+	if truncated {
+		return []interface{}{object, map[string]bool{"truncated": true}}
+	}
+	return []interface{}{object}
+}
+
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	b, _ := json.Marshal(k.Interface())
+	return string(b)
+}
+
+func extractArraySchema(v reflect.Value, depth int) interface{} {
+	n := v.Len()
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		// Byte slices are raw strings
+		return extractStringSchema(string(v.Bytes()))
+	}
+	meta := map[string]interface{}{"len": n}
+	if depth >= apiSecMaxDepth {
+		meta["truncated"] = true
+		return []interface{}{[]interface{}{}, meta}
+	}
+	size := n
+	if size > apiSecMaxContainerSize {
+		size = apiSecMaxContainerSize
+		meta["truncated"] = true
+	}
+	items := make([]interface{}, 0, 1)
+	seen := make(map[string]struct{}, 1)
+	for i := 0; i < size; i++ {
+		item := extractSchemaValue(v.Index(i), depth+1)
+		b, _ := json.Marshal(item)
+		if _, ok := seen[string(b)]; ok {
+			continue
+		}
+		if len(items) == apiSecMaxArrayItemSchemas {
+			meta["truncated"] = true
+			break
+		}
+		seen[string(b)] = struct{}{}
+		items = append(items, item)
+	}
+	return []interface{}{items, meta}
+}
@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSchema(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{name: "null", value: nil, expected: `[1]`},
+		{name: "bool", value: true, expected: `[2]`},
+		{name: "int", value: 42, expected: `[4]`},
+		{name: "float", value: 4.2, expected: `[16]`},
+		{name: "json-number", value: json.Number("42"), expected: `[4]`},
+		{name: "string", value: "hello", expected: `[8]`},
+		{name: "bytes", value: []byte("hello"), expected: `[8]`},
+		{name: "email", value: "john.doe@example.com", expected: `[8,{"category":"pii","type":"email"}]`},
+		{name: "ssn", value: "123-45-6789", expected: `[8,{"category":"pii","type":"us_ssn"}]`},
+		{name: "card", value: "4111 1111 1111 1111", expected: `[8,{"category":"payment","type":"card"}]`},
+		{name: "not-a-card", value: "4111 1111 1111 1112", expected: `[8]`},
+		{
+			name:     "object",
+			value:    map[string]interface{}{"a": "b", "c": 1.5, "d": nil},
+			expected: `[{"a":[8],"c":[16],"d":[1]}]`,
+		},
+		{
+			name: "struct",
+			value: struct {
+				Name    string `json:"name,omitempty"`
+				Email   string
+				private string
+				Nested  *struct{ Count int }
+			}{Email: "john.doe@example.com", Nested: &struct{ Count int }{1}},
+			expected: `[{"name":[8],"Email":[8,{"category":"pii","type":"email"}],"Nested":[{"Count":[4]}]}]`,
+		},
+		{
+			name:     "headers",
+			value:    map[string][]string{"content-type": {"application/json"}},
+			expected: `[{"content-type":[[[8]],{"len":1}]}]`,
+		},
+		{
+			name:     "array",
+			value:    []interface{}{"a", "b", 1, map[string]interface{}{"e": "john@example.com"}},
+			expected: `[[[8],[4],[{"e":[8,{"category":"pii","type":"email"}]}]],{"len":4}]`,
+		},
+		{
+			name:     "array-too-many-schemas",
+			value:    []interface{}{1, 1.5, "a", true, nil, []int{}, map[string]int{}, []int{1}, map[string]int{"a": 1}, []string{"a"}, []bool{true}},
+			expected: `[[[4],[16],[8],[2],[1],[[],{"len":0}],[{}],[[[4]],{"len":1}],[{"a":[4]}],[[[8]],{"len":1}]],{"len":11,"truncated":true}]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := json.Marshal(extractSchema(tc.value))
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(schema))
+		})
+	}
+
+	t.Run("max-depth", func(t *testing.T) {
+		var v interface{} = "leaf"
+		for i := 0; i < apiSecMaxDepth+2; i++ {
+			v = map[string]interface{}{"a": v}
+		}
+		schema, err := json.Marshal(extractSchema(v))
+		require.NoError(t, err)
+		require.Contains(t, string(schema), `[{},{"truncated":true}]`)
+		require.NotContains(t, string(schema), `[8]`)
+	})
+
+	t.Run("max-container-size", func(t *testing.T) {
+		v := make(map[string]int, apiSecMaxContainerSize+1)
+		for i := 0; i <= apiSecMaxContainerSize; i++ {
+			v[string(rune('a'+i%26))+string(rune('a'+i/26))] = i
+		}
+		schema, ok := extractSchema(v).([]interface{})
+		require.True(t, ok)
+		require.Len(t, schema, 2)
+		require.Len(t, schema[0], apiSecMaxContainerSize)
+		require.Equal(t, map[string]bool{"truncated": true}, schema[1])
+	})
+}
+
+func TestEncodeSchema(t *testing.T) {
+	encoded, err := encodeSchema(extractSchema(map[string]interface{}{"a": "b"}))
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"a":[8]}]`, decodeSchema(t, encoded))
+}
+
+func TestAPISecSampler(t *testing.T) {
+	require.False(t, newAPISecSampler(APISecConfig{Enabled: false, SampleRate: 1}).sample())
+	require.False(t, newAPISecSampler(APISecConfig{Enabled: true, SampleRate: 0}).sample())
+	require.True(t, newAPISecSampler(APISecConfig{Enabled: true, SampleRate: 1}).sample())
+}
+
+// decodeSchema returns the JSON schema of the given gzip-compressed and base64-encoded schema.
+func decodeSchema(t *testing.T, encoded string) string {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	schema, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	return string(schema)
+}
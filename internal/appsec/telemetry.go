@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	waf "github.com/DataDog/go-libddwaf"
+)
+
+// WAF telemetry metrics reported under the appsec namespace
+const (
+	wafInitMetric            = "waf.init"                // Count of the WAF instantiations at start-up
+	wafUpdatesMetric         = "waf.updates"             // Count of the WAF instantiations following a rules update
+	wafRequestsMetric        = "waf.requests"            // Count of the requests monitored by the WAF
+	wafRulesLoadedMetric     = "event_rules.loaded"      // Gauge of the number of rules successfully loaded
+	wafRulesErrorCountMetric = "event_rules.error_count" // Gauge of the number of rules which failed to load
+)
+
+func init() {
+	// Let the telemetry client know whether AppSec is enabled when reporting the products
+	telemetry.RegisterAppSecEnabledFunc(Enabled)
+}
+
+// reportWAFLoad reports the telemetry metrics of the instantiation of a WAF handle with new security rules, either at
+// start-up or following a rules update. handle is nil when the WAF couldn't be instantiated.
+func reportWAFLoad(update bool, handle *waf.Handle, err error) {
+	name := wafInitMetric
+	if update {
+		name = wafUpdatesMetric
+	}
+	success := err == nil && handle != nil
+	var tags []string
+	if handle != nil {
+		rInfo := handle.RulesetInfo()
+		tags = wafMetricTags(rInfo.Version)
+		telemetry.GlobalClient.Gauge(telemetry.NamespaceASM, wafRulesLoadedMetric, float64(rInfo.Loaded), tags, true)
+		telemetry.GlobalClient.Gauge(telemetry.NamespaceASM, wafRulesErrorCountMetric, float64(rInfo.Failed), tags, true)
+		if rInfo.Failed > 0 {
+			log.Debug("appsec: %d security rules failed to load: %v", rInfo.Failed, rInfo.Errors)
+		}
+	} else {
+		tags = wafMetricTags("")
+	}
+	telemetry.GlobalClient.Count(telemetry.NamespaceASM, name, 1, append(tags, "success:"+strconv.FormatBool(success)), true)
+}
+
+// reportWAFRequest reports the telemetry metric of a request monitored by the WAF.
+func reportWAFRequest(rulesVersion string, ruleTriggered, requestBlocked, wafTimeout bool) {
+	tags := append(wafMetricTags(rulesVersion),
+		"rule_triggered:"+strconv.FormatBool(ruleTriggered),
+		"request_blocked:"+strconv.FormatBool(requestBlocked),
+		"waf_timeout:"+strconv.FormatBool(wafTimeout),
+	)
+	telemetry.GlobalClient.Count(telemetry.NamespaceASM, wafRequestsMetric, 1, tags, true)
+}
+
+// wafMetricTags returns the tags common to the WAF telemetry metrics. The rules version tag is omitted when empty.
+func wafMetricTags(rulesVersion string) []string {
+	tags := []string{"waf_version:" + waf.Version()}
+	if rulesVersion != "" {
+		tags = append(tags, "event_rules_version:"+rulesVersion)
+	}
+	return tags
+}
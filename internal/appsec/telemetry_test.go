@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build appsec
+// +build appsec
+
+package appsec
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sqlsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry/telemetrytest"
+
+	waf "github.com/DataDog/go-libddwaf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAFTelemetry(t *testing.T) {
+	if waf.Health() != nil {
+		t.Skip("WAF needs to be available for this test")
+	}
+	blockingRules, err := os.ReadFile("testdata/blocking.json")
+	require.NoError(t, err)
+
+	newTestAppSec := func(t *testing.T) *appsec {
+		cfg, err := newConfig()
+		require.NoError(t, err)
+		a := newAppSec(cfg)
+		a.limiter = NewTokenTicker(int64(cfg.traceRateLimit), int64(cfg.traceRateLimit))
+		a.limiter.Start()
+		t.Cleanup(a.limiter.Stop)
+		return a
+	}
+	wafVersionTag := "waf_version:" + waf.Version()
+
+	t.Run("init-and-updates", func(t *testing.T) {
+		client := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(client)()
+		a := newTestAppSec(t)
+
+		require.NoError(t, a.swapWAF(a.cfg.rulesManager.raw()))
+		defer func() { a.unregisterWAF() }()
+		require.Equal(t, 1.0, client.MetricValue(telemetry.NamespaceASM, wafInitMetric, wafVersionTag, "success:true"))
+		require.Greater(t, client.MetricValue(telemetry.NamespaceASM, wafRulesLoadedMetric), 0.0)
+		require.Equal(t, 0.0, client.MetricValue(telemetry.NamespaceASM, wafRulesErrorCountMetric))
+
+		// Successful update
+		require.NoError(t, a.swapWAF(blockingRules))
+		require.Equal(t, 1.0, client.MetricValue(telemetry.NamespaceASM, wafUpdatesMetric, "success:true"))
+
+		// Failed update: the rules have no supported address
+		require.Error(t, a.swapWAF([]byte(`{"version":"2.2","metadata":{"rules_version":"1.2.3"},"rules":[{"id":"1","name":"test","tags":{"type":"test","category":"test"},"conditions":[{"operator":"match_regex","parameters":{"inputs":[{"address":"unsupported"}],"regex":"x"}}]}]}`)))
+		require.Equal(t, 1.0, client.MetricValue(telemetry.NamespaceASM, wafUpdatesMetric, "success:false", "event_rules_version:1.2.3"))
+		require.Equal(t, 1.0, client.MetricValue(telemetry.NamespaceASM, wafInitMetric))
+
+		// Failed update: the rules cannot be parsed by the WAF
+		require.Error(t, a.swapWAF([]byte(`{}`)))
+		require.Equal(t, 2.0, client.MetricValue(telemetry.NamespaceASM, wafUpdatesMetric, "success:false"))
+	})
+
+	t.Run("requests", func(t *testing.T) {
+		client := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(client)()
+		a := newTestAppSec(t)
+		require.NoError(t, a.swapWAF(blockingRules))
+		defer func() { a.unregisterWAF() }()
+
+		for _, args := range []httpsec.HandlerOperationArgs{
+			{RequestURI: "/"},
+			{RequestURI: "/", Headers: map[string][]string{"user-agent": {"<script>alert(1)</script>"}}},
+			{RequestURI: "/", ClientIP: netip.MustParseAddr("1.2.3.4")},
+		} {
+			_, op := httpsec.StartOperation(context.Background(), args)
+			op.Finish(httpsec.HandlerOperationRes{Status: 200})
+		}
+
+		requests := func(tags ...string) float64 {
+			return client.MetricValue(telemetry.NamespaceASM, wafRequestsMetric, append(tags, wafVersionTag)...)
+		}
+		require.Equal(t, 3.0, requests())
+		require.Equal(t, 1.0, requests("rule_triggered:false", "request_blocked:false", "waf_timeout:false"))
+		require.Equal(t, 1.0, requests("rule_triggered:true", "request_blocked:false"))
+		require.Equal(t, 1.0, requests("rule_triggered:true", "request_blocked:true"))
+	})
+	// The SQL queries of a request can be executed concurrently by the request handler
+	t.Run("concurrent-listeners", func(t *testing.T) {
+		client := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(client)()
+		a := newTestAppSec(t)
+		require.NoError(t, a.swapWAF(blockingRules))
+		defer func() { a.unregisterWAF() }()
+
+		name := "' OR 1=1 --"
+		ctx, op := httpsec.StartOperation(context.Background(), httpsec.HandlerOperationArgs{
+			RequestURI: "/?name=" + url.QueryEscape(name),
+			Query:      url.Values{"name": {name}},
+		})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sqlsec.ProtectSQLOperation(ctx, "SELECT * FROM users WHERE name = '"+name+"'", "postgresql")
+			}()
+		}
+		wg.Wait()
+		op.Finish(httpsec.HandlerOperationRes{Status: 403})
+
+		require.Equal(t, 1.0, client.MetricValue(telemetry.NamespaceASM, wafRequestsMetric, wafVersionTag, "rule_triggered:true", "request_blocked:true"))
+	})
+}
@@ -19,9 +19,11 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/grpcsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/httpsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sharedsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sqlsec"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 
@@ -40,21 +42,25 @@ const (
 )
 
 func (a *appsec) swapWAF(rules []byte) error {
+	// The WAF is being updated when a WAF is currently registered
+	update := a.unregisterWAF != nil
 	// 1 - Instantiate a new WAF handle and verify its state
 	waf, err := freshWAFHandle(rules, a.cfg)
 	if err != nil {
+		reportWAFLoad(update, nil, err)
 		return err
 	} else if waf == nil { // Nil handle but no error means the handle was not updated (ddwaf_update, not supported yet)
 		return nil
 	}
-	// Close the WAF in case of an error in what's following
+	// Report the WAF telemetry metrics, and close the WAF in case of an error in what's following
 	defer func() {
+		reportWAFLoad(update, waf, err)
 		if err != nil {
 			waf.Close()
 		}
 	}()
 	// 2 - Register dyngo listeners now that we know that the new handle is valid
-	unreg, err := registerDyngoListeners(waf, a.cfg, a.limiter)
+	unreg, err := registerDyngoListeners(waf, a.cfg, a.limiter, a.clientLimiter, parseActions(rules))
 	if err != nil {
 		return err
 	}
@@ -86,7 +92,7 @@ func freshWAFHandle(rules []byte, cfg *Config) (*waf.Handle, error) {
 	return waf.NewHandle(rules, cfg.obfuscator.KeyRegex, cfg.obfuscator.ValueRegex)
 }
 
-func registerDyngoListeners(waf *waf.Handle, cfg *Config, l Limiter) (dyngo.UnregisterFunc, error) {
+func registerDyngoListeners(waf *waf.Handle, cfg *Config, l Limiter, cl *clientRateLimiter, actions []actionEntry) (dyngo.UnregisterFunc, error) {
 	// Check if there are addresses in the rule
 	ruleAddresses := waf.Addresses()
 	if len(ruleAddresses) == 0 {
@@ -101,14 +107,21 @@ func registerDyngoListeners(waf *waf.Handle, cfg *Config, l Limiter) (dyngo.Unre
 	}
 
 	// Register the WAF event listener
+	rateLimits := newRateLimits(actions, cfg.clientRateLimit)
 	var unregisterHTTP, unregisterGRPC dyngo.UnregisterFunc
 	if len(httpAddresses) > 0 {
 		log.Debug("appsec: registering http waf listening to addresses %v", httpAddresses)
-		unregisterHTTP = dyngo.Register(newHTTPWAFEventListener(waf, httpAddresses, cfg.wafTimeout, l))
+		unregisterHTTP = dyngo.Register(newHTTPWAFEventListener(waf, httpAddresses, cfg.wafTimeout, l, actions, cfg.apiSec, cl, rateLimits))
 	}
+	// Only hold the HTTP responses back when the rules monitor them. This is
+	// not reset when unregistering, as the listeners of the previous rules are
+	// unregistered after the new ones are registered, see swapWAF.
+	httpsec.SetResponseMonitoring(hasAddress(httpAddresses, ServerResponseStatusAddr) ||
+		hasAddress(httpAddresses, ServerResponseHeadersNoCookiesAddr) ||
+		hasAddress(httpAddresses, ServerResponseBodyAddr))
 	if len(grpcAddresses) > 0 {
 		log.Debug("appsec: registering grpc waf listening to addresses %v", grpcAddresses)
-		unregisterGRPC = dyngo.Register(newGRPCWAFEventListener(waf, grpcAddresses, cfg.wafTimeout, l))
+		unregisterGRPC = dyngo.Register(newGRPCWAFEventListener(waf, grpcAddresses, cfg.wafTimeout, l, actions, cl, rateLimits))
 	}
 
 	return func() {
@@ -122,35 +135,57 @@ func registerDyngoListeners(waf *waf.Handle, cfg *Config, l Limiter) (dyngo.Unre
 }
 
 // newWAFEventListener returns the WAF event listener to register in order to enable it.
-func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter) dyngo.EventListener {
+func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter, actions []actionEntry, apiSec APISecConfig, clientLimiter *clientRateLimiter, rateLimits map[string]rateLimit) dyngo.EventListener {
 	var monitorRulesOnce sync.Once // per instantiation
-	actionHandler := httpsec.NewActionsHandler()
+	actionHandler := newHTTPActionsHandler(actions)
+	sampler := newAPISecSampler(apiSec)
 
 	return httpsec.OnHandlerOperationStart(func(op *httpsec.Operation, args httpsec.HandlerOperationArgs) {
-		var body interface{}
 		wafCtx := waf.NewContext(handle)
 		if wafCtx == nil {
 			// The WAF event listener got concurrently released
 			return
 		}
+		// API Security: whether the schemas of this request get extracted, and its parsed body
+		apiSecSampled := sampler.sample()
+		var requestBody interface{}
+		// Whether a rule was triggered and whether the request got blocked, reported in the WAF telemetry metrics.
+		// They are atomically updated as the SQL, RoundTrip and resolver listeners can run on other goroutines.
+		var ruleTriggered, blocked uint32
+		// Rate limits of the rate_limit_request actions returned by the WAF for this request
+		requestLimits := newRequestRateLimits(clientLimiter, rateLimits, args.ClientIP)
+		// The request addresses, given to the WAF contexts of the outgoing HTTP requests and SQL queries. They are
+		// guarded by a mutex as the body is added once parsed, while these operations can run on other goroutines.
+		var (
+			requestValuesMu sync.Mutex
+			requestValues   map[string]interface{}
+		)
+		getRequestValues := func() map[string]interface{} {
+			requestValuesMu.Lock()
+			defer requestValuesMu.Unlock()
+			return requestValues
+		}
 
 		// OnUserIDOperationStart happens when appsec.SetUser() is called. We run the WAF and apply actions to
 		// see if the associated user should be blocked. Since we don't control the execution flow in this case
-		// (SetUser is SDK), we delegate the responsibility of interrupting the handler to the user.
+		// (SetUser is SDK), we delegate the responsibility of interrupting the handler to the user. The request
+		// also gets blocked when the user exceeds the rate limit of a previously returned rate_limit_request action.
 		op.On(sharedsec.OnUserIDOperationStart(func(operation *sharedsec.UserIDOperation, args sharedsec.UserIDOperationArgs) {
 			values := map[string]interface{}{}
 			for _, addr := range addresses {
-				if addr == userIDAddr {
-					values[userIDAddr] = args.UserID
+				if addr == UserIDAddr {
+					values[UserIDAddr] = args.UserID
 				}
 			}
+			rateLimited := requestLimits.setUserID(args.UserID)
 			matches, actionIds := runWAF(wafCtx, values, timeout)
-			if len(matches) > 0 {
-				for _, id := range actionIds {
-					if actionHandler.Apply(id, op) {
-						operation.Error = sharedsec.NewUserMonitoringError("Request blocked")
-					}
+			for _, id := range append(rateLimited, requestLimits.filter(actionIds)...) {
+				if actionHandler.Apply(id, op) {
+					atomic.StoreUint32(&blocked, 1)
+					operation.Error = sharedsec.NewUserMonitoringError("Request blocked")
 				}
+			}
+			if len(matches) > 0 {
 				op.AddSecurityEvents(matches)
 				log.Debug("appsec: WAF detected a suspicious user: %s", args.UserID)
 			}
@@ -159,73 +194,183 @@ func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 		values := map[string]interface{}{}
 		for _, addr := range addresses {
 			switch addr {
-			case httpClientIPAddr:
+			case HTTPClientIPAddr:
 				if args.ClientIP.IsValid() {
-					values[httpClientIPAddr] = args.ClientIP.String()
+					values[HTTPClientIPAddr] = args.ClientIP.String()
 				}
-			case serverRequestRawURIAddr:
-				values[serverRequestRawURIAddr] = args.RequestURI
-			case serverRequestHeadersNoCookiesAddr:
+			case ServerRequestRawURIAddr:
+				values[ServerRequestRawURIAddr] = args.RequestURI
+			case ServerRequestHeadersNoCookiesAddr:
 				if headers := args.Headers; headers != nil {
-					values[serverRequestHeadersNoCookiesAddr] = headers
+					values[ServerRequestHeadersNoCookiesAddr] = headers
 				}
-			case serverRequestCookiesAddr:
+			case ServerRequestCookiesAddr:
 				if cookies := args.Cookies; cookies != nil {
-					values[serverRequestCookiesAddr] = cookies
+					values[ServerRequestCookiesAddr] = cookies
 				}
-			case serverRequestQueryAddr:
+			case ServerRequestQueryAddr:
 				if query := args.Query; query != nil {
-					values[serverRequestQueryAddr] = query
+					values[ServerRequestQueryAddr] = query
 				}
-			case serverRequestPathParamsAddr:
+			case ServerRequestPathParamsAddr:
 				if pathParams := args.PathParams; pathParams != nil {
-					values[serverRequestPathParamsAddr] = pathParams
+					values[ServerRequestPathParamsAddr] = pathParams
 				}
 			}
 		}
 
+		requestValues = values
 		matches, actionIds := runWAF(wafCtx, values, timeout)
 		if len(matches) > 0 {
 			interrupt := false
-			for _, id := range actionIds {
+			for _, id := range requestLimits.filter(actionIds) {
 				interrupt = actionHandler.Apply(id, op) || interrupt
 			}
 			op.AddSecurityEvents(matches)
 			log.Debug("appsec: WAF detected an attack before executing the request")
 			if interrupt {
+				reportWAFRequest(handle.RulesetInfo().Version, true, true, wafCtx.TotalTimeouts() > 0)
 				wafCtx.Close()
 				return
 			}
 		}
+		if len(matches) > 0 {
+			ruleTriggered = 1
+		}
+
+		// OnSDKBodyOperationStart happens when the request body is parsed, either automatically by httpsec.WrapHandler
+		// before executing the request handler, or by the SDK function appsec.MonitorParsedHTTPBody(). The actions
+		// are applied to the handler operation so that the request can be blocked when the handler is not executing
+		// yet.
+		op.On(httpsec.OnSDKBodyOperationStart(func(_ *httpsec.SDKBodyOperation, args httpsec.SDKBodyOperationArgs) {
+			if args.Body == nil {
+				return
+			}
+			requestBody = args.Body
+			if !hasAddress(addresses, ServerRequestBodyAddr) {
+				return
+			}
+			requestValuesMu.Lock()
+			withBody := make(map[string]interface{}, len(requestValues)+1)
+			for addr, v := range requestValues {
+				withBody[addr] = v
+			}
+			withBody[ServerRequestBodyAddr] = args.Body
+			requestValues = withBody
+			requestValuesMu.Unlock()
+			matches, actionIds := runWAF(wafCtx, map[string]interface{}{ServerRequestBodyAddr: args.Body}, timeout)
+			if len(matches) > 0 {
+				atomic.StoreUint32(&ruleTriggered, 1)
+				for _, id := range requestLimits.filter(actionIds) {
+					if actionHandler.Apply(id, op) {
+						atomic.StoreUint32(&blocked, 1)
+					}
+				}
+				op.AddSecurityEvents(matches)
+				log.Debug("appsec: WAF detected an attack in the request body")
+			}
+		}))
+
+		// OnResolverOperationStart happens when a GraphQL field resolver served by this handler is executed. The
+		// resolver arguments are monitored under the graphql.server.all_resolvers address, keyed by field name, and
+		// the resolver gets interrupted when the request must be blocked.
+		op.On(graphqlsec.OnResolverOperationStart(func(operation *graphqlsec.ResolverOperation, args graphqlsec.ResolverOperationArgs) {
+			if !hasAddress(addresses, GraphQLServerAllResolversAddr) || len(args.Arguments) == 0 {
+				return
+			}
+			values := map[string]interface{}{
+				GraphQLServerAllResolversAddr: map[string]interface{}{args.FieldName: []interface{}{args.Arguments}},
+			}
+			matches, actionIds := runWAF(wafCtx, values, timeout)
+			if len(matches) > 0 {
+				atomic.StoreUint32(&ruleTriggered, 1)
+				for _, id := range requestLimits.filter(actionIds) {
+					if actionHandler.Apply(id, op) {
+						atomic.StoreUint32(&blocked, 1)
+						operation.Error = graphqlsec.ErrBlocked
+					}
+				}
+				op.AddSecurityEvents(matches)
+				log.Debug("appsec: WAF detected an attack in the arguments of the GraphQL resolver %s.%s", args.TypeName, args.FieldName)
+			}
+		}))
 
-		op.On(httpsec.OnSDKBodyOperationStart(func(op *httpsec.SDKBodyOperation, args httpsec.SDKBodyOperationArgs) {
-			body = args.Body
+		// OnRoundTripOperationStart happens when an outgoing HTTP request is about to be sent by the handler. The
+		// request URL is monitored to prevent SSRF exploits, and the outgoing request is not sent when blocked.
+		op.On(httpsec.OnRoundTripOperationStart(func(operation *httpsec.RoundTripOperation, args httpsec.RoundTripOperationArgs) {
+			if !hasAddress(addresses, ServerIONetURLAddr) {
+				return
+			}
+			values := map[string]interface{}{ServerIONetURLAddr: args.URL}
+			triggered, err := runExploitPrevention(op, handle, getRequestValues(), values, timeout, actionHandler, requestLimits, "SSRF")
+			if triggered {
+				atomic.StoreUint32(&ruleTriggered, 1)
+			}
+			if err != nil {
+				atomic.StoreUint32(&blocked, 1)
+			}
+			operation.Error = err
+		}))
+
+		// OnSQLOperationStart happens when a SQL query is about to be executed by the handler. The query is monitored
+		// to prevent SQL injection exploits, and it is not executed when blocked.
+		op.On(sqlsec.OnSQLOperationStart(func(operation *sqlsec.SQLOperation, args sqlsec.SQLOperationArgs) {
+			if !hasAddress(addresses, ServerDBStatementAddr) {
+				return
+			}
+			values := map[string]interface{}{ServerDBStatementAddr: args.Query}
+			if hasAddress(addresses, ServerDBSystemAddr) {
+				values[ServerDBSystemAddr] = args.System
+			}
+			triggered, err := runExploitPrevention(op, handle, getRequestValues(), values, timeout, actionHandler, requestLimits, "SQL injection")
+			if triggered {
+				atomic.StoreUint32(&ruleTriggered, 1)
+			}
+			if err != nil {
+				atomic.StoreUint32(&blocked, 1)
+			}
+			operation.Error = err
 		}))
 
-		// At the moment, AppSec doesn't block the requests, and so we can use the fact we are in monitoring-only mode
-		// to call the WAF only once at the end of the handler operation.
 		op.On(httpsec.OnHandlerOperationFinish(func(op *httpsec.Operation, res httpsec.HandlerOperationRes) {
 			defer wafCtx.Close()
 			// Run the WAF on the rule addresses available in the request args
 			values := make(map[string]interface{}, len(addresses))
 			for _, addr := range addresses {
 				switch addr {
-				case serverRequestBodyAddr:
-					if body != nil {
-						values[serverRequestBodyAddr] = body
+				case ServerResponseStatusAddr:
+					values[ServerResponseStatusAddr] = res.Status
+				case ServerResponseHeadersNoCookiesAddr:
+					if headers := res.Headers; headers != nil {
+						values[ServerResponseHeadersNoCookiesAddr] = headers
+					}
+				case ServerResponseBodyAddr:
+					if body := res.Body; body != nil {
+						values[ServerResponseBodyAddr] = body
 					}
-				case serverResponseStatusAddr:
-					values[serverResponseStatusAddr] = res.Status
 				}
 			}
-			// Run the WAF, ignoring the returned actions - if any - since blocking after the request handler's
-			// response is not supported at the moment.
-			matches, _ := runWAF(wafCtx, values, timeout)
+			// Run the WAF and apply the returned actions, if any, so that the response can be replaced by the
+			// blocking response when it is still held back by httpsec.WrapHandler.
+			matches, actionIds := runWAF(wafCtx, values, timeout)
+			if len(matches) > 0 {
+				atomic.StoreUint32(&ruleTriggered, 1)
+				for _, id := range requestLimits.filter(actionIds) {
+					if actionHandler.Apply(id, op) {
+						atomic.StoreUint32(&blocked, 1)
+					}
+				}
+			}
+
+			if apiSecSampled {
+				addAPISecTags(op, args, requestBody, res)
+			}
 
 			// Add WAF metrics.
 			rInfo := handle.RulesetInfo()
 			overallRuntimeNs, internalRuntimeNs := wafCtx.TotalRuntime()
 			addWAFMonitoringTags(op, rInfo.Version, overallRuntimeNs, internalRuntimeNs, wafCtx.TotalTimeouts())
+			reportWAFRequest(rInfo.Version, atomic.LoadUint32(&ruleTriggered) == 1, atomic.LoadUint32(&blocked) == 1, wafCtx.TotalTimeouts() > 0)
 
 			// Add the following metrics once per instantiation of a WAF handle
 			monitorRulesOnce.Do(func() {
@@ -247,9 +392,9 @@ func newHTTPWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 
 // newGRPCWAFEventListener returns the WAF event listener to register in order
 // to enable it.
-func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter) dyngo.EventListener {
+func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout time.Duration, limiter Limiter, actions []actionEntry, clientLimiter *clientRateLimiter, rateLimits map[string]rateLimit) dyngo.EventListener {
 	var monitorRulesOnce sync.Once // per instantiation
-	actionHandler := grpcsec.NewActionsHandler()
+	actionHandler := newGRPCActionsHandler(actions)
 
 	return grpcsec.OnHandlerOperationStart(func(op *grpcsec.HandlerOperation, handlerArgs grpcsec.HandlerOperationArgs) {
 		// Limit the maximum number of security events, as a streaming RPC could
@@ -261,6 +406,7 @@ func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 			overallRuntimeNs  waf.AtomicU64
 			internalRuntimeNs waf.AtomicU64
 			nbTimeouts        waf.AtomicU64
+			ruleTriggered     uint32 // whether a rule was triggered, reported in the WAF telemetry metrics
 
 			events []json.RawMessage
 			mu     sync.Mutex // events mutex
@@ -271,23 +417,28 @@ func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 			// The WAF event listener got concurrently released
 			return
 		}
+		// Rate limits of the rate_limit_request actions returned by the WAF for this request
+		requestLimits := newRequestRateLimits(clientLimiter, rateLimits, handlerArgs.ClientIP)
 
 		// OnUserIDOperationStart happens when appsec.SetUser() is called. We run the WAF and apply actions to
 		// see if the associated user should be blocked. Since we don't control the execution flow in this case
-		// (SetUser is SDK), we delegate the responsibility of interrupting the handler to the user.
+		// (SetUser is SDK), we delegate the responsibility of interrupting the handler to the user. The request
+		// also gets blocked when the user exceeds the rate limit of a previously returned rate_limit_request action.
 		op.On(sharedsec.OnUserIDOperationStart(func(operation *sharedsec.UserIDOperation, args sharedsec.UserIDOperationArgs) {
 			values := map[string]interface{}{}
 			for _, addr := range addresses {
-				if addr == userIDAddr {
-					values[userIDAddr] = args.UserID
+				if addr == UserIDAddr {
+					values[UserIDAddr] = args.UserID
 				}
 			}
+			rateLimited := requestLimits.setUserID(args.UserID)
 			matches, actionIds := runWAF(wafCtx, values, timeout)
+			for _, id := range append(rateLimited, requestLimits.filter(actionIds)...) {
+				actionHandler.Apply(id, op)
+			}
+			operation.Error = op.Error
 			if len(matches) > 0 {
-				for _, id := range actionIds {
-					actionHandler.Apply(id, op)
-				}
-				operation.Error = op.Error
+				atomic.StoreUint32(&ruleTriggered, 1)
 				op.AddSecurityEvents(matches)
 				log.Debug("appsec: WAF detected an authenticated user attack: %s", args.UserID)
 			}
@@ -296,23 +447,25 @@ func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 		// The same address is used for gRPC and http when it comes to client ip
 		values := map[string]interface{}{}
 		for _, addr := range addresses {
-			if addr == httpClientIPAddr && handlerArgs.ClientIP.IsValid() {
-				values[httpClientIPAddr] = handlerArgs.ClientIP.String()
+			if addr == HTTPClientIPAddr && handlerArgs.ClientIP.IsValid() {
+				values[HTTPClientIPAddr] = handlerArgs.ClientIP.String()
 			}
 		}
 
 		matches, actionIds := runWAF(wafCtx, values, timeout)
 		if len(matches) > 0 {
 			interrupt := false
-			for _, id := range actionIds {
+			for _, id := range requestLimits.filter(actionIds) {
 				interrupt = actionHandler.Apply(id, op) || interrupt
 			}
 			op.AddSecurityEvents(matches)
 			log.Debug("appsec: WAF detected an attack before executing the request")
 			if interrupt {
+				reportWAFRequest(handle.RulesetInfo().Version, true, true, wafCtx.TotalTimeouts() > 0)
 				wafCtx.Close()
 				return
 			}
+			ruleTriggered = 1
 		}
 
 		op.On(grpcsec.OnReceiveOperationFinish(func(_ grpcsec.ReceiveOperation, res grpcsec.ReceiveOperationRes) {
@@ -370,6 +523,8 @@ func newGRPCWAFEventListener(handle *waf.Handle, addresses []string, timeout tim
 			defer wafCtx.Close()
 			rInfo := handle.RulesetInfo()
 			addWAFMonitoringTags(op, rInfo.Version, overallRuntimeNs.Load(), internalRuntimeNs.Load(), nbTimeouts.Load())
+			triggered := atomic.LoadUint32(&ruleTriggered) == 1 || atomic.LoadUint32(&nbEvents) > 0
+			reportWAFRequest(rInfo.Version, triggered, op.Error != nil, nbTimeouts.Load() > 0)
 
 			// Log the following metrics once per instantiation of a WAF handle
 			monitorRulesOnce.Do(func() {
@@ -398,30 +553,23 @@ func runWAF(wafCtx *waf.Context, values map[string]interface{}, timeout time.Dur
 	return matches, actions
 }
 
-// HTTP rule addresses currently supported by the WAF
-const (
-	serverRequestRawURIAddr           = "server.request.uri.raw"
-	serverRequestHeadersNoCookiesAddr = "server.request.headers.no_cookies"
-	serverRequestCookiesAddr          = "server.request.cookies"
-	serverRequestQueryAddr            = "server.request.query"
-	serverRequestPathParamsAddr       = "server.request.path_params"
-	serverRequestBodyAddr             = "server.request.body"
-	serverResponseStatusAddr          = "server.response.status"
-	httpClientIPAddr                  = "http.client_ip"
-	userIDAddr                        = "usr.id"
-)
-
 // List of HTTP rule addresses currently supported by the WAF
 var httpAddresses = []string{
-	serverRequestRawURIAddr,
-	serverRequestHeadersNoCookiesAddr,
-	serverRequestCookiesAddr,
-	serverRequestQueryAddr,
-	serverRequestPathParamsAddr,
-	serverRequestBodyAddr,
-	serverResponseStatusAddr,
-	httpClientIPAddr,
-	userIDAddr,
+	ServerRequestRawURIAddr,
+	ServerRequestHeadersNoCookiesAddr,
+	ServerRequestCookiesAddr,
+	ServerRequestQueryAddr,
+	ServerRequestPathParamsAddr,
+	ServerRequestBodyAddr,
+	ServerResponseStatusAddr,
+	ServerResponseHeadersNoCookiesAddr,
+	ServerResponseBodyAddr,
+	HTTPClientIPAddr,
+	UserIDAddr,
+	GraphQLServerAllResolversAddr,
+	ServerIONetURLAddr,
+	ServerDBStatementAddr,
+	ServerDBSystemAddr,
 }
 
 // gRPC rule addresses currently supported by the WAF
@@ -434,8 +582,8 @@ const (
 var grpcAddresses = []string{
 	grpcServerRequestMessage,
 	grpcServerRequestMetadata,
-	httpClientIPAddr,
-	userIDAddr,
+	HTTPClientIPAddr,
+	UserIDAddr,
 }
 
 func init() {
@@ -465,6 +613,16 @@ func supportedAddresses(ruleAddresses []string) (supportedHTTP, supportedGRPC, n
 	return
 }
 
+// hasAddress returns whether addr is in the given list of addresses.
+func hasAddress(addresses []string, addr string) bool {
+	for _, a := range addresses {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
 type tagsHolder interface {
 	AddTag(string, interface{})
 }
@@ -9,10 +9,15 @@
 package appsec_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -20,6 +25,9 @@ import (
 	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/graphqlsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sharedsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/dyngo/instrumentation/sqlsec"
 
 	"github.com/stretchr/testify/require"
 )
@@ -183,8 +191,13 @@ func TestBlocking(t *testing.T) {
 	}
 
 	const (
-		ipBlockingRule   = "blk-001-001"
-		userBlockingRule = "blk-001-002"
+		ipBlockingRule       = "blk-001-001"
+		userBlockingRule     = "blk-001-002"
+		bodyBlockingRule     = "blk-001-003"
+		responseBlockingRule = "blk-001-004"
+		redirectRule         = "blk-001-005"
+		customBlockingRule   = "blk-001-006"
+		graphQLBlockingRule  = "blk-001-007"
 	)
 
 	// Start and trace an HTTP server
@@ -198,14 +211,42 @@ func TestBlocking(t *testing.T) {
 		}
 		w.Write([]byte("Hello World!\n"))
 	})
+	mux.HandleFunc("/body", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello World!\n"))
+	})
+	mux.HandleFunc("/response", func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get("test-response-header"); v != "" {
+			w.Header().Set("x-test-response", v)
+		}
+		if v := r.Header.Get("test-response-body"); v != "" {
+			w.Header().Set("content-type", "application/json")
+			w.Write([]byte(`{"key":"` + v + `"}`))
+			return
+		}
+		w.Write([]byte("Hello World!\n"))
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		args := map[string]interface{}{"id": r.Header.Get("test-resolver-arg")}
+		if err := graphqlsec.MonitorResolver(r.Context(), "Query", "user", args); err != nil {
+			return
+		}
+		w.Write([]byte("Hello World!\n"))
+	})
 	srv := httptest.NewServer(mux)
 	defer srv.Close()
+	client := srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
 
 	for _, tc := range []struct {
 		name      string
 		headers   map[string]string
 		endpoint  string
+		body      string
 		status    int
+		location  string // expected redirection location
+		response  string // expected response body
 		ruleMatch string
 	}{
 		{
@@ -253,23 +294,108 @@ func TestBlocking(t *testing.T) {
 			status:    403,
 			ruleMatch: ipBlockingRule,
 		},
+		{
+			name:     "body/no-block",
+			headers:  map[string]string{"content-type": "application/json"},
+			endpoint: "/body",
+			body:     `{"key":"legit-body"}`,
+			status:   200,
+		},
+		{
+			name:      "body/block/json",
+			headers:   map[string]string{"content-type": "application/json"},
+			endpoint:  "/body",
+			body:      `{"key":"blocked-body"}`,
+			status:    403,
+			ruleMatch: bodyBlockingRule,
+		},
+		{
+			name:      "body/block/form",
+			headers:   map[string]string{"content-type": "application/x-www-form-urlencoded"},
+			endpoint:  "/body",
+			body:      "key=blocked-body",
+			status:    403,
+			ruleMatch: bodyBlockingRule,
+		},
+		{
+			name:      "body/block/xml",
+			headers:   map[string]string{"content-type": "application/xml"},
+			endpoint:  "/body",
+			body:      "<key>blocked-body</key>",
+			status:    403,
+			ruleMatch: bodyBlockingRule,
+		},
+		{
+			name:      "action/redirect",
+			headers:   map[string]string{"x-test-action": "redirect"},
+			endpoint:  "/ip",
+			status:    302,
+			location:  "/home",
+			ruleMatch: redirectRule,
+		},
+		{
+			name:      "action/custom",
+			headers:   map[string]string{"x-test-action": "custom"},
+			endpoint:  "/ip",
+			status:    418,
+			response:  "custom blocked",
+			ruleMatch: customBlockingRule,
+		},
+		{
+			name:     "response/no-block",
+			headers:  map[string]string{"test-response-header": "legit-response"},
+			endpoint: "/response",
+			status:   200,
+		},
+		{
+			name:      "response/block/headers",
+			headers:   map[string]string{"test-response-header": "blocked-response"},
+			endpoint:  "/response",
+			status:    403,
+			ruleMatch: responseBlockingRule,
+		},
+		{
+			name:      "response/block/body",
+			headers:   map[string]string{"test-response-body": "blocked-response"},
+			endpoint:  "/response",
+			status:    403,
+			ruleMatch: responseBlockingRule,
+		},
+		{
+			name:     "graphql/no-block",
+			headers:  map[string]string{"test-resolver-arg": "legit-resolver"},
+			endpoint: "/graphql",
+			status:   200,
+		},
+		{
+			name:      "graphql/block",
+			headers:   map[string]string{"test-resolver-arg": "blocked-resolver"},
+			endpoint:  "/graphql",
+			status:    403,
+			ruleMatch: graphQLBlockingRule,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			mt := mocktracer.Start()
 			defer mt.Stop()
-			req, err := http.NewRequest("POST", srv.URL+tc.endpoint, nil)
+			req, err := http.NewRequest("POST", srv.URL+tc.endpoint, strings.NewReader(tc.body))
 			if err != nil {
 				panic(err)
 			}
 			for k, v := range tc.headers {
 				req.Header.Set(k, v)
 			}
-			res, err := srv.Client().Do(req)
+			res, err := client.Do(req)
 			require.NoError(t, err)
 			require.Equal(t, tc.status, res.StatusCode)
 			b, err := io.ReadAll(res.Body)
 			require.NoError(t, err)
-			if tc.status == 200 {
+			if tc.location != "" {
+				require.Equal(t, tc.location, res.Header.Get("Location"))
+			}
+			if tc.response != "" {
+				require.Equal(t, tc.response, string(b))
+			} else if tc.status == 200 {
 				require.Equal(t, "Hello World!\n", string(b))
 			} else {
 				require.NotEqual(t, "Hello World!\n", string(b))
@@ -283,3 +409,214 @@ func TestBlocking(t *testing.T) {
 		})
 	}
 }
+
+// TestExploitPrevention checks that the outgoing HTTP requests and SQL queries exploiting the request inputs are
+// blocked before being performed.
+func TestExploitPrevention(t *testing.T) {
+	t.Setenv("DD_APPSEC_RULES", "testdata/blocking.json")
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	const (
+		ssrfRule = "blk-001-008"
+		sqliRule = "blk-001-009"
+	)
+
+	// The outgoing requests are not sent over the network but only recorded
+	var sent []string
+	client := httptrace.WrapClient(&http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			sent = append(sent, r.URL.String())
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		}),
+	})
+	var blockErr error
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/ssrf", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", r.URL.Query().Get("url"), nil)
+		res, err := client.Do(req)
+		if err != nil {
+			blockErr = err
+			return
+		}
+		res.Body.Close()
+		w.Write([]byte("Hello World!\n"))
+	})
+	mux.HandleFunc("/sqli", func(w http.ResponseWriter, r *http.Request) {
+		query := "SELECT * FROM users WHERE name = '" + r.URL.Query().Get("name") + "'"
+		if err := sqlsec.ProtectSQLOperation(r.Context(), query, "postgresql"); err != nil {
+			blockErr = err
+			return
+		}
+		w.Write([]byte("Hello World!\n"))
+	})
+	// The handlers attempting every exploit twice, each attempt must be blocked
+	var blockErrs []error
+	mux.HandleFunc("/ssrf/twice", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequestWithContext(r.Context(), "GET", r.URL.Query().Get("url")+strconv.Itoa(i), nil)
+			res, err := client.Do(req)
+			if err != nil {
+				blockErrs = append(blockErrs, err)
+				continue
+			}
+			res.Body.Close()
+		}
+	})
+	mux.HandleFunc("/sqli/twice", func(w http.ResponseWriter, r *http.Request) {
+		for _, table := range []string{"users", "admins"} {
+			query := "SELECT * FROM " + table + " WHERE name = '" + r.URL.Query().Get("name") + "'"
+			if err := sqlsec.ProtectSQLOperation(r.Context(), query, "postgresql"); err != nil {
+				blockErrs = append(blockErrs, err)
+			}
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name      string
+		query     string
+		status    int
+		ruleMatch string
+	}{
+		{
+			name:   "ssrf/no-block",
+			query:  "/ssrf?url=" + url.QueryEscape("http://example.com/"),
+			status: 200,
+		},
+		{
+			name:      "ssrf/block",
+			query:     "/ssrf?url=" + url.QueryEscape("http://169.254.169.254/latest/meta-data/"),
+			status:    403,
+			ruleMatch: ssrfRule,
+		},
+		{
+			name:   "sqli/no-block",
+			query:  "/sqli?name=" + url.QueryEscape("john"),
+			status: 200,
+		},
+		{
+			name:      "sqli/block",
+			query:     "/sqli?name=" + url.QueryEscape("' OR 1=1 --"),
+			status:    403,
+			ruleMatch: sqliRule,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+			sent, blockErr = nil, nil
+
+			res, err := srv.Client().Get(srv.URL + tc.query)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, tc.status, res.StatusCode)
+
+			var serverSpan mocktracer.Span
+			for _, s := range mt.FinishedSpans() {
+				if s.Tag("span.kind") == "server" {
+					serverSpan = s
+				}
+			}
+			require.NotNil(t, serverSpan)
+			if tc.ruleMatch == "" {
+				require.NoError(t, blockErr)
+				require.NotContains(t, serverSpan.Tags(), "_dd.appsec.exploit.stack")
+				return
+			}
+			var exploitErr *sharedsec.ExploitPreventionError
+			require.True(t, errors.As(blockErr, &exploitErr))
+			require.Empty(t, sent)
+			require.Contains(t, serverSpan.Tag("_dd.appsec.json"), tc.ruleMatch)
+			require.Contains(t, serverSpan.Tag("_dd.appsec.exploit.stack"), "TestExploitPrevention")
+		})
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+	}{
+		{
+			name:  "ssrf/twice",
+			query: "/ssrf/twice?url=" + url.QueryEscape("http://169.254.169.254/latest/meta-data/"),
+		},
+		{
+			name:  "sqli/twice",
+			query: "/sqli/twice?name=" + url.QueryEscape("' OR 1=1 --"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+			sent, blockErrs = nil, nil
+
+			res, err := srv.Client().Get(srv.URL + tc.query)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusForbidden, res.StatusCode)
+			require.Len(t, blockErrs, 2)
+			for _, err := range blockErrs {
+				var exploitErr *sharedsec.ExploitPreventionError
+				require.True(t, errors.As(err, &exploitErr))
+			}
+			require.Empty(t, sent)
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// TestAPISecurity checks that the schemas of the sampled requests and responses are reported in the span tags.
+func TestAPISecurity(t *testing.T) {
+	t.Setenv("DD_EXPERIMENTAL_API_SECURITY_ENABLED", "true")
+	t.Setenv("DD_API_SECURITY_REQUEST_SAMPLE_RATE", "1")
+	appsec.Start()
+	defer appsec.Stop()
+	if !appsec.Enabled() {
+		t.Skip("AppSec needs to be enabled for this test")
+	}
+
+	mux := httptrace.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"email":"john.doe@example.com"}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	req, err := http.NewRequest("POST", srv.URL+"/?q=search", strings.NewReader(`{"name":"john","tags":["a","b"]}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	decode := func(tag string) string {
+		encoded, ok := spans[0].Tag(tag).(string)
+		require.True(t, ok, tag)
+		b, err := base64.StdEncoding.DecodeString(encoded)
+		require.NoError(t, err)
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		require.NoError(t, err)
+		schema, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		return string(schema)
+	}
+	require.JSONEq(t, `[{"q":[[[8]],{"len":1}]}]`, decode("_dd.appsec.s.req.query"))
+	require.JSONEq(t, `[{"name":[8],"tags":[[[8]],{"len":2}]}]`, decode("_dd.appsec.s.req.body"))
+	require.JSONEq(t, `[{"id":[16],"email":[8,{"category":"pii","type":"email"}]}]`, decode("_dd.appsec.s.res.body"))
+	require.Contains(t, decode("_dd.appsec.s.req.headers"), `"content-type":[[[8]],{"len":1}]`)
+	require.Contains(t, decode("_dd.appsec.s.res.headers"), `"content-type":[[[8]],{"len":1}]`)
+}
@@ -23,6 +23,8 @@ type config struct {
 	mu            sync.RWMutex
 	analyticsRate float64
 	serviceName   string
+	env           string
+	version       string
 	runtimeID     string
 }
 
@@ -56,6 +58,34 @@ func SetServiceName(name string) {
 	cfg.serviceName = name
 }
 
+// Env returns the environment of the application, as configured on the tracer.
+func Env() string {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.env
+}
+
+// SetEnv sets the environment of the application.
+func SetEnv(env string) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.env = env
+}
+
+// Version returns the version of the application, as configured on the tracer.
+func Version() string {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.version
+}
+
+// SetVersion sets the version of the application.
+func SetVersion(version string) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.version = version
+}
+
 // RuntimeID returns this process's unique runtime id.
 func RuntimeID() string {
 	cfg.mu.RLock()
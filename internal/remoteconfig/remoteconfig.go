@@ -15,6 +15,7 @@ import (
 	"math/big"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
@@ -48,6 +49,10 @@ const (
 	ASMResponseBlocking
 	// ASMUserBlocking represents the capability for ASM to block requests based on user ID
 	ASMUserBlocking
+	// ASMCustomRules represents the capability for ASM to receive and use user-defined security rules
+	ASMCustomRules
+	// ASMCustomBlockingResponse represents the capability for ASM to receive and use user-defined blocking responses
+	ASMCustomBlockingResponse
 )
 
 // ProductUpdate represents an update for a specific product.
@@ -67,6 +72,9 @@ type Client struct {
 	callbacks []Callback
 
 	lastError error
+
+	// mu guards the products and capabilities, which can be updated while polling
+	mu sync.RWMutex
 }
 
 // NewClient creates a new remoteconfig Client
@@ -169,28 +177,37 @@ func (c *Client) RegisterCallback(f Callback) {
 
 // RegisterProduct adds a product to the list of products listened by the client
 func (c *Client) RegisterProduct(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Products[p] = struct{}{}
 }
 
 // UnregisterProduct removes a product from the list of products listened by the client
 func (c *Client) UnregisterProduct(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	delete(c.Products, p)
 }
 
 // RegisterCapability adds a capability to the list of capabilities exposed by the client when requesting
 // configuration updates
 func (c *Client) RegisterCapability(cap Capability) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Capabilities[cap] = struct{}{}
 }
 
 // UnregisterCapability removes a capability from the list of capabilities exposed by the client when requesting
 // configuration updates
 func (c *Client) UnregisterCapability(cap Capability) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	delete(c.Capabilities, cap)
 }
 
 func (c *Client) applyUpdate(pbUpdate *clientGetConfigsResponse) error {
 	fileMap := make(map[string][]byte, len(pbUpdate.TargetFiles))
+	c.mu.RLock()
 	productUpdates := make(map[string]ProductUpdate, len(c.Products))
 	for _, f := range pbUpdate.TargetFiles {
 		fileMap[f.Path] = f.Raw
@@ -202,6 +219,7 @@ func (c *Client) applyUpdate(pbUpdate *clientGetConfigsResponse) error {
 			}
 		}
 	}
+	c.mu.RUnlock()
 
 	mapify := func(s *rc.RepositoryState) map[string]string {
 		m := make(map[string]string)
@@ -326,6 +344,7 @@ func (c *Client) newUpdateRequest() (bytes.Buffer, error) {
 		}
 	}
 
+	c.mu.RLock()
 	capa := big.NewInt(0)
 	for i := range c.Capabilities {
 		capa.SetBit(capa, int(i), 1)
@@ -334,6 +353,7 @@ func (c *Client) newUpdateRequest() (bytes.Buffer, error) {
 	for p := range c.Products {
 		products = append(products, p)
 	}
+	c.mu.RUnlock()
 	req := clientGetConfigsRequest{
 		Client: &clientData{
 			State: &clientState{
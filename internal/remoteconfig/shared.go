@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package remoteconfig
+
+import (
+	"sync"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+// The remote configuration client of the tracer is shared by the products
+// subscribing to it with Subscribe. It is created by Start, when the tracer
+// starts, and only polls the agent once a product is subscribed.
+var (
+	sharedMu sync.Mutex
+	// sharedClient is the client of the tracer, nil when it is not started
+	sharedClient *Client
+	// polling is true once the shared client polls the agent
+	polling bool
+	// subscriptions are the products subscribed to the shared client, and
+	// their callback
+	subscriptions = make(map[string]subscription)
+)
+
+type subscription struct {
+	callback     Callback
+	capabilities []Capability
+}
+
+// Start starts the remote configuration client of the tracer with the given
+// configuration, replacing the previous one if any. The products subscribed
+// with Subscribe get the configuration updates of this client, including the
+// ones subscribed before Start.
+func Start(config ClientConfig) error {
+	// The products and capabilities of the configuration are the ones of the
+	// subscriptions, and must not be shared with other clients
+	config.Products = make(map[string]struct{})
+	config.Capabilities = make(map[Capability]struct{})
+	c, err := NewClient(config)
+	if err != nil {
+		return err
+	}
+	c.RegisterCallback(dispatch)
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	stopShared()
+	sharedClient = c
+	for product, s := range subscriptions {
+		register(product, s)
+	}
+	if len(subscriptions) > 0 {
+		c.Start()
+		polling = true
+	}
+	return nil
+}
+
+// Stop stops the remote configuration client of the tracer. The products stay
+// subscribed to the client of the next call to Start.
+func Stop() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	stopShared()
+}
+
+func stopShared() {
+	if sharedClient == nil {
+		return
+	}
+	if polling {
+		sharedClient.Stop()
+		polling = false
+	}
+	sharedClient = nil
+}
+
+// Subscribe subscribes the given product to the remote configuration client of
+// the tracer, with its capabilities. The callback is given the updates of this
+// product only, and replaces the one of a previous subscription of the same
+// product.
+func Subscribe(product string, callback Callback, capabilities ...Capability) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	s := subscription{callback: callback, capabilities: capabilities}
+	subscriptions[product] = s
+	if sharedClient == nil {
+		return
+	}
+	register(product, s)
+	if !polling {
+		sharedClient.Start()
+		polling = true
+	}
+}
+
+// Unsubscribe unsubscribes the given product from the remote configuration
+// client of the tracer.
+func Unsubscribe(product string) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	s, ok := subscriptions[product]
+	if !ok {
+		return
+	}
+	delete(subscriptions, product)
+	if sharedClient == nil {
+		return
+	}
+	sharedClient.UnregisterProduct(product)
+	for _, c := range s.capabilities {
+		sharedClient.UnregisterCapability(c)
+	}
+}
+
+// register registers the product and capabilities of s on the shared client.
+// Must be called with sharedMu locked.
+func register(product string, s subscription) {
+	sharedClient.RegisterProduct(product)
+	for _, c := range s.capabilities {
+		sharedClient.RegisterCapability(c)
+	}
+}
+
+// dispatch is the callback of the shared client, calling the callbacks of the
+// subscribed products with their updates.
+func dispatch(updates map[string]ProductUpdate) map[string]rc.ApplyStatus {
+	sharedMu.Lock()
+	callbacks := make(map[string]Callback, len(subscriptions))
+	for product, s := range subscriptions {
+		callbacks[product] = s.callback
+	}
+	sharedMu.Unlock()
+
+	statuses := make(map[string]rc.ApplyStatus)
+	for product, u := range updates {
+		fn, ok := callbacks[product]
+		if !ok {
+			continue
+		}
+		for path, status := range fn(map[string]ProductUpdate{product: u}) {
+			statuses[path] = status
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package remoteconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedClient(t *testing.T) {
+	// The agent records the products of the polling requests
+	var (
+		mu       sync.Mutex
+		requests [][]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req clientGetConfigsRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var products []string
+		for _, p := range req.Client.Products {
+			if p != "" {
+				products = append(products, p)
+			}
+		}
+		mu.Lock()
+		requests = append(requests, products)
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	lastRequest := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requests) == 0 {
+			return nil
+		}
+		return requests[len(requests)-1]
+	}
+
+	cfg := DefaultClientConfig()
+	cfg.AgentURL = srv.URL
+	cfg.PollInterval = time.Millisecond
+	cfg.Products[rc.ProductASMDD] = struct{}{}
+
+	// A product can subscribe before the client is started
+	var updates []map[string]ProductUpdate
+	Subscribe(rc.ProductAPMTracing, func(u map[string]ProductUpdate) map[string]rc.ApplyStatus {
+		updates = append(updates, u)
+		return nil
+	})
+	defer Unsubscribe(rc.ProductAPMTracing)
+
+	require.NoError(t, Start(cfg))
+	defer Stop()
+	assert.Eventually(t, func() bool { return len(lastRequest()) > 0 }, time.Second, time.Millisecond)
+	// The products of the given configuration are not polled
+	assert.Equal(t, []string{rc.ProductAPMTracing}, lastRequest())
+	assert.NotContains(t, sharedClient.Products, rc.ProductASMDD)
+
+	t.Run("dispatch", func(t *testing.T) {
+		updates = nil
+		Subscribe(rc.ProductASMFeatures, func(map[string]ProductUpdate) map[string]rc.ApplyStatus {
+			return map[string]rc.ApplyStatus{"asm": {State: rc.ApplyStateAcknowledged}}
+		})
+		defer Unsubscribe(rc.ProductASMFeatures)
+		statuses := dispatch(map[string]ProductUpdate{
+			rc.ProductAPMTracing:  {"apm": []byte("{}")},
+			rc.ProductASMFeatures: {"asm": []byte("{}")},
+			rc.ProductASMDD:       {"dd": []byte("{}")},
+		})
+		// Each callback only gets the updates of its product
+		require.Len(t, updates, 1)
+		assert.Equal(t, map[string]ProductUpdate{rc.ProductAPMTracing: {"apm": []byte("{}")}}, updates[0])
+		assert.Equal(t, map[string]rc.ApplyStatus{"asm": {State: rc.ApplyStateAcknowledged}}, statuses)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		Subscribe(rc.ProductASMFeatures, func(map[string]ProductUpdate) map[string]rc.ApplyStatus { return nil }, ASMActivation)
+		assert.Eventually(t, func() bool { return len(lastRequest()) == 2 }, time.Second, time.Millisecond)
+		assert.Contains(t, sharedClient.Capabilities, ASMActivation)
+		Unsubscribe(rc.ProductASMFeatures)
+		assert.Eventually(t, func() bool { return len(lastRequest()) == 1 }, time.Second, time.Millisecond)
+		assert.NotContains(t, sharedClient.Capabilities, ASMActivation)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		Stop()
+		assert.Nil(t, sharedClient)
+		assert.False(t, polling)
+		// The products stay subscribed to the next client
+		assert.Contains(t, subscriptions, rc.ProductAPMTracing)
+		require.NoError(t, Start(cfg))
+		assert.Contains(t, sharedClient.Products, rc.ProductAPMTracing)
+		assert.True(t, polling)
+	})
+}
+
+func TestSharedClientNoSubscription(t *testing.T) {
+	var polled int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		atomic.StoreInt32(&polled, 1)
+	}))
+	defer srv.Close()
+	cfg := DefaultClientConfig()
+	cfg.AgentURL = srv.URL
+	cfg.PollInterval = time.Millisecond
+	require.NoError(t, Start(cfg))
+	defer Stop()
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(&polled), "the agent must not be polled without subscriptions")
+}
@@ -7,7 +7,25 @@
 // Datadog regarding usage of an APM library such as tracing or profiling.
 package telemetry
 
-import "gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+import "sync/atomic"
+
+// appsecEnabled reports whether AppSec is enabled. The appsec package reports
+// its own telemetry metrics through this package and cannot be imported here,
+// so that it registers it with RegisterAppSecEnabledFunc instead.
+var appsecEnabled atomic.Value // func() bool
+
+// RegisterAppSecEnabledFunc registers the function reporting whether AppSec
+// is enabled. AppSec is considered disabled until it is registered.
+func RegisterAppSecEnabledFunc(enabled func() bool) {
+	appsecEnabled.Store(enabled)
+}
+
+// isAppSecEnabled returns whether AppSec is enabled according to the
+// registered function.
+func isAppSecEnabled() bool {
+	enabled, _ := appsecEnabled.Load().(func() bool)
+	return enabled != nil && enabled()
+}
 
 // ProductStart signals that the product has started with some configuration
 // information. It will start the telemetry client if it is not already started.
@@ -26,7 +44,7 @@ func (c *client) ProductStart(namespace Namespace, configuration []Configuration
 			// Since appsec is integrated with the tracer, we sent an app-product-change
 			// update about appsec when the tracer starts. Any tracer-related configuration
 			// information can be passed along here as well.
-			if appsec.Enabled() {
+			if isAppSecEnabled() {
 				c.productEnabled(NamespaceASM)
 			}
 		case NamespaceASM:
@@ -39,6 +57,18 @@ func (c *client) ProductStart(namespace Namespace, configuration []Configuration
 	}
 }
 
+// ConfigChange enqueues an app-client-configuration-change event signaling
+// that the given configuration changed at runtime, e.g. through remote
+// configuration. It is a no-op if the client is not started.
+func (c *client) ConfigChange(configuration []Configuration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		return
+	}
+	c.configChange(configuration)
+}
+
 // configChange enqueues an app-client-configuration-change event to be flushed.
 // Must be called with c.mu locked.
 func (c *client) configChange(configuration []Configuration) {
@@ -34,6 +34,7 @@ func TestProductEnabled(t *testing.T) {
 }
 
 func TestConfigChange(t *testing.T) {
+	stopped := new(client)
 	client := new(client)
 	client.start(nil, NamespaceTracers)
 	client.configChange([]Configuration{BoolConfig("delta_profiles", true)})
@@ -45,6 +46,10 @@ func TestConfigChange(t *testing.T) {
 	require.Len(t, configPayload.Configuration, 1)
 
 	Check(t, configPayload.Configuration, "delta_profiles", true)
+
+	// ConfigChange is a no-op until the client is started
+	stopped.ConfigChange([]Configuration{BoolConfig("delta_profiles", true)})
+	assert.Empty(t, stopped.requests)
 }
 
 // mockServer initializes a server that expects a strict amount of telemetry events. It saves these
@@ -7,6 +7,8 @@
 package telemetrytest
 
 import (
+	"sort"
+	"strings"
 	"sync"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
@@ -21,6 +23,16 @@ type MockClient struct {
 	Integrations    []string
 	ProfilerEnabled bool
 	AsmEnabled      bool
+	// Metrics holds the metrics reported to the mock client, by namespace
+	Metrics map[telemetry.Namespace][]*Metric
+}
+
+// Metric is a metric reported to the mock client.
+type Metric struct {
+	Kind  string // "count" or "gauge"
+	Name  string
+	Value float64
+	Tags  []string
 }
 
 // ProductStart starts and adds configuration data to the mock client.
@@ -35,6 +47,13 @@ func (c *MockClient) ProductStart(namespace telemetry.Namespace, configuration [
 	}
 }
 
+// ConfigChange adds configuration data to the mock client.
+func (c *MockClient) ConfigChange(configuration []telemetry.Configuration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Configuration = append(c.Configuration, configuration...)
+}
+
 // ProductStop signals a product has stopped and disables that product in the mock client.
 // ProductStop is NOOP for the tracer namespace, since the tracer is not considered a product.
 func (c *MockClient) ProductStop(namespace telemetry.Namespace) {
@@ -60,12 +79,66 @@ func (c *MockClient) productChange(namespace telemetry.Namespace, enabled bool)
 	}
 }
 
-// Gauge is NOOP for the mock client.
-func (c *MockClient) Gauge(_ telemetry.Namespace, _ string, _ float64, _ []string, _ bool) {
+// Gauge sets the value of the gauge with the given name and tags in the mock client.
+func (c *MockClient) Gauge(namespace telemetry.Namespace, name string, value float64, tags []string, _ bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metric(namespace, "gauge", name, tags).Value = value
 }
 
-// Count is NOOP for the mock client.
-func (c *MockClient) Count(_ telemetry.Namespace, _ string, _ float64, _ []string, _ bool) {
+// Count adds the value to the count with the given name and tags in the mock client.
+func (c *MockClient) Count(namespace telemetry.Namespace, name string, value float64, tags []string, _ bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metric(namespace, "count", name, tags).Value += value
+}
+
+// metric returns the metric of the given kind, name and tags, creating it when
+// it doesn't exist yet. Must be called with c.mu locked.
+func (c *MockClient) metric(namespace telemetry.Namespace, kind, name string, tags []string) *Metric {
+	tags = append([]string(nil), tags...)
+	sort.Strings(tags)
+	for _, m := range c.Metrics[namespace] {
+		if m.Kind == kind && m.Name == name && strings.Join(m.Tags, ",") == strings.Join(tags, ",") {
+			return m
+		}
+	}
+	if c.Metrics == nil {
+		c.Metrics = make(map[telemetry.Namespace][]*Metric)
+	}
+	m := &Metric{Kind: kind, Name: name, Tags: tags}
+	c.Metrics[namespace] = append(c.Metrics[namespace], m)
+	return m
+}
+
+// MetricValue returns the sum of the values of the metrics reported with the
+// given namespace and name, and having all the given tags.
+func (c *MockClient) MetricValue(namespace telemetry.Namespace, name string, tags ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var value float64
+	for _, m := range c.Metrics[namespace] {
+		if m.Name == name && hasTags(m.Tags, tags) {
+			value += m.Value
+		}
+	}
+	return value
+}
+
+func hasTags(tags, expected []string) bool {
+	for _, e := range expected {
+		found := false
+		for _, t := range tags {
+			if t == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 // Stop is NOOP for the mock client.
@@ -0,0 +1,38 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package traceprof
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// globalEndpointCPUTime holds the map[string]time.Duration of the CPU time per
+// request of each endpoint, shared between the profiler and the tracer.
+var globalEndpointCPUTime atomic.Value
+
+func init() {
+	globalEndpointCPUTime.Store(map[string]time.Duration(nil))
+}
+
+// SetEndpointCPUTime replaces the CPU time per request of the endpoints, as
+// computed by the profiler from the TraceEndpoint label of its CPU profiles.
+// The tracer adds it to the local root spans of the endpoints. m must not be
+// modified afterwards. A nil m clears the CPU times.
+func SetEndpointCPUTime(m map[string]time.Duration) {
+	globalEndpointCPUTime.Store(m)
+}
+
+// EndpointCPUTime returns the CPU time per request of endpoint, given to
+// SetEndpointCPUTime, and whether it's known.
+func EndpointCPUTime(endpoint string) (time.Duration, bool) {
+	m := globalEndpointCPUTime.Load().(map[string]time.Duration)
+	if m == nil {
+		return 0, false
+	}
+	d, ok := m[endpoint]
+	return d, ok
+}
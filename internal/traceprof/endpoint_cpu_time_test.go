@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package traceprof
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointCPUTime(t *testing.T) {
+	_, ok := EndpointCPUTime("GET /users")
+	assert.False(t, ok)
+
+	SetEndpointCPUTime(map[string]time.Duration{"GET /users": time.Millisecond})
+	d, ok := EndpointCPUTime("GET /users")
+	assert.True(t, ok)
+	assert.Equal(t, time.Millisecond, d)
+	_, ok = EndpointCPUTime("POST /users")
+	assert.False(t, ok)
+
+	SetEndpointCPUTime(nil)
+	_, ok = EndpointCPUTime("GET /users")
+	assert.False(t, ok)
+}
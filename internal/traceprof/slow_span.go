@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package traceprof
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SlowSpan describes a finished span which lasted longer than the threshold
+// given to SetSlowSpanHandler. Its fields hold the values of the pprof labels
+// applied by the tracer to the span, and are empty if the label wasn't applied.
+type SlowSpan struct {
+	SpanID          string // SpanID is the value of the SpanID label
+	LocalRootSpanID string // LocalRootSpanID is the value of the LocalRootSpanID label
+	Endpoint        string // Endpoint is the value of the TraceEndpoint label
+	Duration        time.Duration
+}
+
+type slowSpanHandler struct {
+	threshold time.Duration
+	fn        func(SlowSpan)
+}
+
+// globalSlowSpanHandler holds the *slowSpanHandler shared between the profiler
+// and the tracer.
+var globalSlowSpanHandler atomic.Value
+
+func init() {
+	globalSlowSpanHandler.Store(&slowSpanHandler{})
+}
+
+// SetSlowSpanHandler makes the tracer call fn with every finished span carrying
+// pprof labels which lasted at least threshold. fn is called synchronously when
+// the span finishes, so it must not block. A threshold <= 0 or a nil fn disables
+// the handler.
+func SetSlowSpanHandler(threshold time.Duration, fn func(SlowSpan)) {
+	if threshold <= 0 || fn == nil {
+		threshold, fn = 0, nil
+	}
+	globalSlowSpanHandler.Store(&slowSpanHandler{threshold: threshold, fn: fn})
+}
+
+// SlowSpanThreshold returns the threshold given to SetSlowSpanHandler, or 0 if
+// there is no handler.
+func SlowSpanThreshold() time.Duration {
+	return globalSlowSpanHandler.Load().(*slowSpanHandler).threshold
+}
+
+// ReportSlowSpan calls the handler set with SetSlowSpanHandler with s, if
+// s.Duration reaches its threshold.
+func ReportSlowSpan(s SlowSpan) {
+	h := globalSlowSpanHandler.Load().(*slowSpanHandler)
+	if h.fn != nil && s.Duration >= h.threshold {
+		h.fn(s)
+	}
+}
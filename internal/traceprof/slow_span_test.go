@@ -0,0 +1,30 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package traceprof
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowSpanHandler(t *testing.T) {
+	assert.Zero(t, SlowSpanThreshold())
+	ReportSlowSpan(SlowSpan{Duration: time.Hour}) // no handler, no panic
+
+	var got []SlowSpan
+	SetSlowSpanHandler(time.Second, func(s SlowSpan) { got = append(got, s) })
+	assert.Equal(t, time.Second, SlowSpanThreshold())
+	ReportSlowSpan(SlowSpan{SpanID: "1", Duration: time.Millisecond})
+	ReportSlowSpan(SlowSpan{SpanID: "2", Duration: time.Second})
+	assert.Equal(t, []SlowSpan{{SpanID: "2", Duration: time.Second}}, got)
+
+	SetSlowSpanHandler(time.Second, nil)
+	assert.Zero(t, SlowSpanThreshold())
+	ReportSlowSpan(SlowSpan{Duration: time.Hour})
+	assert.Len(t, got, 1)
+}
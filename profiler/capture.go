@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+// DefaultCaptureDuration is the default length of the CPU profile and execution
+// trace of an on-demand capture.
+const DefaultCaptureDuration = 10 * time.Second
+
+var (
+	// ErrProfilerNotRunning is returned by Capture when the profiler hasn't been started.
+	ErrProfilerNotRunning = errors.New("profiler: not running")
+
+	// ErrCaptureInProgress is returned by Capture when another capture is running.
+	ErrCaptureInProgress = errors.New("profiler: a capture is already in progress")
+)
+
+// defaultCaptureTypes are the profile types collected by a capture when none are given.
+var defaultCaptureTypes = []ProfileType{CPUProfile, HeapProfile, GoroutineProfile}
+
+// CaptureOptions configures an on-demand capture, see Capture.
+type CaptureOptions struct {
+	// Duration is the length of the CPU profile and of the execution trace.
+	// It defaults to DefaultCaptureDuration.
+	Duration time.Duration
+
+	// ProfileTypes are the profiles to collect. CPUProfile, HeapProfile,
+	// MutexProfile, BlockProfile and GoroutineProfile are supported. The
+	// profiles other than CPUProfile are collected at the end of the capture.
+	// It defaults to CPUProfile, HeapProfile and GoroutineProfile.
+	ProfileTypes []ProfileType
+
+	// ExecutionTrace enables recording an execution trace during the capture.
+	ExecutionTrace bool
+
+	// CPUProfileRate is the CPU profiling rate used during the capture, in hz.
+	// It defaults to the rate of the profiler, see the CPUProfileRate option.
+	CPUProfileRate int
+
+	// Reason is the reason of the capture, which is uploaded along with the
+	// profiles as the "trigger:<reason>" tag. It defaults to "manual".
+	Reason string
+}
+
+// Capture collects the given profiles out of the regular profiling period and
+// uploads them right away, tagged with "trigger:<reason>". It blocks until the
+// profiles are uploaded, ctx is done or the profiler is stopped. Ending the
+// capture early with ctx still uploads the profiles collected so far.
+//
+// The regular profiles are not affected, except for the CPU profile: as a
+// single CPU profile can run at a time, the regular one is cut short when a
+// capture starts, and resumes at the next profiling period. The profiles of a
+// capture are never delta profiles.
+func Capture(ctx context.Context, opts CaptureOptions) error {
+	mu.Lock()
+	p := activeProfiler
+	mu.Unlock()
+	if p == nil {
+		return ErrProfilerNotRunning
+	}
+	return p.capture(ctx, opts)
+}
+
+// capture implements Capture.
+func (p *profiler) capture(ctx context.Context, opts CaptureOptions) error {
+	if !p.captureMu.TryLock() {
+		return ErrCaptureInProgress
+	}
+	defer p.captureMu.Unlock()
+
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultCaptureDuration
+	}
+	if len(opts.ProfileTypes) == 0 {
+		opts.ProfileTypes = defaultCaptureTypes
+	}
+	if opts.Reason == "" {
+		opts.Reason = "manual"
+	}
+	var cpu bool
+	for _, pt := range opts.ProfileTypes {
+		switch pt {
+		case CPUProfile:
+			cpu = true
+		case HeapProfile, MutexProfile, BlockProfile, GoroutineProfile:
+		default:
+			return fmt.Errorf("profiler: profile type %s can't be captured", pt)
+		}
+	}
+
+	if cpu {
+		// The regular CPU profile may take a while to stop, the capture
+		// only lasts opts.Duration once it holds the CPU profiler.
+		p.lockCPUProfile()
+		defer p.cpuMu.Unlock()
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.exit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	bat := batch{
+		host:    p.cfg.hostname,
+		start:   now(),
+		trigger: opts.Reason,
+	}
+	var (
+		wg       sync.WaitGroup
+		cpuData  []byte
+		cpuErr   error
+		traceBuf bytes.Buffer
+		traceErr error
+	)
+	if cpu {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cpuData, cpuErr = p.captureCPUProfile(ctx, opts.CPUProfileRate)
+		}()
+	}
+	if opts.ExecutionTrace {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			traceErr = captureExecutionTrace(ctx, &traceBuf, p.cfg.traceConfig.Limit)
+		}()
+	}
+	<-ctx.Done()
+	wg.Wait()
+
+	var errs []string
+	if cpu {
+		if cpuErr != nil {
+			errs = append(errs, fmt.Sprintf("cpu: %v", cpuErr))
+		} else {
+			bat.addProfile(&profile{name: CPUProfile.Filename(), pt: CPUProfile, data: cpuData})
+		}
+	}
+	if opts.ExecutionTrace {
+		if traceErr != nil {
+			errs = append(errs, fmt.Sprintf("execution trace: %v", traceErr))
+		} else {
+			bat.addProfile(&profile{name: executionTrace.Filename(), pt: executionTrace, data: traceBuf.Bytes()})
+		}
+	}
+	for _, pt := range opts.ProfileTypes {
+		if pt == CPUProfile {
+			continue
+		}
+		// the raw profiles are uploaded, so that the baselines of the regular
+		// delta profiles are left untouched
+		var buf bytes.Buffer
+		if err := p.lookupProfile(pt.String(), &buf, 0); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", pt, err))
+			continue
+		}
+		bat.addProfile(&profile{name: pt.Filename(), pt: pt, data: buf.Bytes()})
+	}
+	bat.end = now()
+
+	tags := append(p.cfg.tags.Slice(), "trigger:"+opts.Reason)
+	p.cfg.statsd.Count("datadog.profiling.go.capture", 1, tags, 1)
+	p.redactBatch(&bat)
+	if len(bat.profiles) > 0 {
+		if err := p.export(bat); err != nil {
+			errs = append(errs, fmt.Sprintf("export: %v", err))
+		}
+		if p.cfg.uploadEnabled {
+			if err := p.uploadFunc(bat); err != nil {
+				errs = append(errs, fmt.Sprintf("upload: %v", err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("profiler: capture failed: %v", errs)
+	}
+	return nil
+}
+
+// lockCPUProfile locks p.cpuMu for a capture. It preempts the regular CPU
+// profile if it is running.
+func (p *profiler) lockCPUProfile() {
+	select {
+	case p.cpuPreempt <- struct{}{}:
+	default:
+	}
+	p.cpuMu.Lock()
+	// the regular CPU profile wasn't running, don't let it be preempted later
+	select {
+	case <-p.cpuPreempt:
+	default:
+	}
+}
+
+// captureCPUProfile records a CPU profile until ctx is done. It must be called
+// with p.cpuMu locked, see lockCPUProfile.
+func (p *profiler) captureCPUProfile(ctx context.Context, rate int) ([]byte, error) {
+	if rate == 0 {
+		rate = p.cpuProfileRate()
+	}
+	if rate != 0 {
+		runtime.SetCPUProfileRate(rate)
+	}
+	var buf bytes.Buffer
+	if err := p.startCPUProfile(&buf); err != nil {
+		return nil, err
+	}
+	<-ctx.Done()
+	p.stopCPUProfile()
+	return buf.Bytes(), nil
+}
+
+// captureExecutionTrace records an execution trace into buf until ctx is done
+// or the trace reaches limit bytes.
+func captureExecutionTrace(ctx context.Context, buf *bytes.Buffer, limit int) error {
+	if limit <= 0 {
+		limit = defaultExecutionTraceSizeLimit
+	}
+	lt := newLimitedTraceCollector(buf, int64(limit))
+	if err := trace.Start(lt); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+	case <-lt.done:
+	}
+	trace.Stop()
+	return nil
+}
@@ -0,0 +1,205 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	t.Run("not-running", func(t *testing.T) {
+		assert.Equal(t, ErrProfilerNotRunning, Capture(context.Background(), CaptureOptions{}))
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		got := make(chan profileMeta)
+		server := httptest.NewServer(&mockBackend{t: t, profiles: got})
+		defer server.Close()
+
+		// The regular CPU profile runs for the whole period, the capture
+		// must preempt it.
+		err := Start(
+			WithAgentAddr(server.Listener.Addr().String()),
+			WithProfileTypes(CPUProfile),
+			WithPeriod(time.Hour),
+		)
+		require.NoError(t, err)
+		defer Stop()
+
+		errc := make(chan error, 1)
+		go func() {
+			errc <- Capture(context.Background(), CaptureOptions{
+				Duration:     50 * time.Millisecond,
+				ProfileTypes: []ProfileType{CPUProfile, GoroutineProfile},
+			})
+		}()
+		m := <-got
+		require.NoError(t, <-errc)
+		assert.Contains(t, m.tags, "trigger:manual")
+		for _, tag := range m.tags {
+			assert.NotContains(t, tag, "profile_seq:")
+		}
+		assert.ElementsMatch(t, []string{"cpu.pprof", "goroutines.pprof"}, m.event.Attachments)
+	})
+
+	t.Run("in-progress", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		p.captureMu.Lock()
+		defer p.captureMu.Unlock()
+		assert.Equal(t, ErrCaptureInProgress, p.capture(context.Background(), CaptureOptions{}))
+	})
+
+	t.Run("unsupported-type", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		err = p.capture(context.Background(), CaptureOptions{ProfileTypes: []ProfileType{MetricsProfile}})
+		assert.EqualError(t, err, "profiler: profile type metrics can't be captured")
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		var uploaded batch
+		p.uploadFunc = func(bat batch) error {
+			uploaded = bat
+			return nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = p.capture(ctx, CaptureOptions{Duration: time.Hour, Reason: "test"})
+		require.NoError(t, err)
+		assert.Equal(t, "test", uploaded.trigger)
+		assert.Len(t, uploaded.profiles, 3)
+	})
+
+	t.Run("cpu-busy", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		var uploaded batch
+		p.uploadFunc = func(bat batch) error {
+			uploaded = bat
+			return nil
+		}
+		// The regular CPU profile holds the CPU profiler for a while, the
+		// capture duration only starts once it is released
+		p.cpuMu.Lock()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			p.cpuMu.Unlock()
+		}()
+		err = p.capture(context.Background(), CaptureOptions{
+			Duration:     50 * time.Millisecond,
+			ProfileTypes: []ProfileType{CPUProfile},
+		})
+		require.NoError(t, err)
+		require.Len(t, uploaded.profiles, 1)
+		prof, err := pprofile.ParseData(uploaded.profiles[0].data)
+		require.NoError(t, err)
+		assert.Greater(t, time.Duration(prof.DurationNanos), 40*time.Millisecond)
+	})
+}
+
+func TestCaptureTriggers(t *testing.T) {
+	for name, tt := range map[string]struct {
+		triggers CaptureTriggers
+		prev     triggerSample
+		cur      triggerSample
+		heapBase uint64
+		want     string
+	}{
+		"none": {
+			triggers: CaptureTriggers{},
+			prev:     triggerSample{cpuTotal: 10, heap: 100, goroutines: 10},
+			cur:      triggerSample{cpuTotal: 20, heap: 1000, goroutines: 1000},
+			heapBase: 100,
+		},
+		"cpu": {
+			triggers: CaptureTriggers{CPUUtilization: 0.8},
+			prev:     triggerSample{cpuTotal: 10, cpuIdle: 5},
+			cur:      triggerSample{cpuTotal: 20, cpuIdle: 6},
+			want:     "cpu",
+		},
+		"cpu-below": {
+			triggers: CaptureTriggers{CPUUtilization: 0.8},
+			prev:     triggerSample{cpuTotal: 10, cpuIdle: 5},
+			cur:      triggerSample{cpuTotal: 20, cpuIdle: 10},
+		},
+		"cpu-unsupported": {
+			triggers: CaptureTriggers{CPUUtilization: 0.8},
+		},
+		"heap": {
+			triggers: CaptureTriggers{HeapGrowth: 0.5},
+			cur:      triggerSample{heap: 151},
+			heapBase: 100,
+			want:     "heap",
+		},
+		"heap-below": {
+			triggers: CaptureTriggers{HeapGrowth: 0.5},
+			cur:      triggerSample{heap: 150},
+			heapBase: 100,
+		},
+		"goroutines": {
+			triggers: CaptureTriggers{Goroutines: 100},
+			cur:      triggerSample{goroutines: 101},
+			want:     "goroutines",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.triggers.check(tt.prev, tt.cur, tt.heapBase))
+		})
+	}
+}
+
+func TestCaptureTriggered(t *testing.T) {
+	p, err := unstartedProfiler(
+		WithProfileTypes(),
+		WithPeriod(time.Hour),
+		WithCaptureTriggers(CaptureTriggers{
+			Goroutines:    100,
+			CheckInterval: time.Millisecond,
+			Cooldown:      time.Hour,
+			Capture: CaptureOptions{
+				Duration:     time.Millisecond,
+				ProfileTypes: []ProfileType{GoroutineProfile},
+			},
+		}),
+	)
+	require.NoError(t, err)
+	var goroutines int32 = 10
+	p.testHooks.readTriggerSample = func() triggerSample {
+		return triggerSample{goroutines: int(atomic.LoadInt32(&goroutines))}
+	}
+	uploads := make(chan batch, 2)
+	p.uploadFunc = func(bat batch) error {
+		uploads <- bat
+		return nil
+	}
+	p.run()
+	defer p.stop()
+
+	atomic.StoreInt32(&goroutines, 1000)
+	select {
+	case bat := <-uploads:
+		assert.Equal(t, "goroutines", bat.trigger)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no capture was triggered")
+	}
+	// the cooldown prevents any other capture
+	select {
+	case bat := <-uploads:
+		t.Fatalf("unexpected capture: %v", bat.trigger)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,321 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Command profile-tool processes pprof profiles offline, such as the profiles
+// written by the directory exporter of the profiler (profiler.NewDirExporter),
+// e.g. to compare the profiles of two builds in CI performance regression
+// checks.
+//
+// Usage:
+//
+//	profile-tool merge [-o out.pprof] a.pprof b.pprof...
+//	profile-tool delta [-o out.pprof] [-sample-types alloc_space/bytes,...] before.pprof after.pprof
+//	profile-tool diff [-o out.pprof] base.pprof new.pprof
+//	profile-tool filter [-o out.pprof] -label 'trace endpoint=GET /users' in.pprof
+//	profile-tool convert [-o out] -to text|pprof in
+//
+// The profiles are read in the pprof format, gzipped or not, except by the
+// convert command which also reads the text format of the profiler tests, e.g.:
+//
+//	samples/count cpu/nanoseconds
+//	main;foo 5 50000000
+//	main;foo;bar 3 30000000
+//
+// The profiles are written to the standard output, unless -o is given.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/fastdelta"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "profile-tool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// commands maps the name of every command to its implementation.
+var commands = map[string]func(args []string, stdout io.Writer) error{
+	"merge":   merge,
+	"delta":   delta,
+	"diff":    diff,
+	"filter":  filter,
+	"convert": convert,
+}
+
+// errUsage is returned when the command line is invalid, once the usage was
+// printed.
+var errUsage = errors.New("invalid usage")
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || commands[args[0]] == nil {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "usage: profile-tool <%s> [flags] profiles...\n", strings.Join(names, "|"))
+		return errUsage
+	}
+	return commands[args[0]](args[1:], stdout)
+}
+
+// newFlagSet returns the flag set of the named command, with its -o flag.
+func newFlagSet(name, usage string) (fs *flag.FlagSet, output *string) {
+	fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: profile-tool %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	output = fs.String("o", "", "output file, defaults to the standard output")
+	return fs, output
+}
+
+// parseArgs parses the flags of fs from args, and checks that at least minArgs and
+// at most maxArgs profiles are given, or any number if maxArgs is < 0.
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if n := fs.NArg(); n < minArgs || (maxArgs >= 0 && n > maxArgs) {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func merge(args []string, stdout io.Writer) error {
+	fs, output := newFlagSet("merge", "[-o out.pprof] profiles...")
+	files, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	var profs []*profile.Profile
+	for _, f := range files {
+		p, err := readProfile(f)
+		if err != nil {
+			return err
+		}
+		profs = append(profs, p)
+	}
+	merged, err := profile.Merge(profs)
+	if err != nil {
+		return err
+	}
+	return writeProfile(merged, *output, stdout)
+}
+
+func delta(args []string, stdout io.Writer) error {
+	fs, output := newFlagSet("delta", "[-o out.pprof] [-sample-types type/unit,...] before.pprof after.pprof")
+	sampleTypes := fs.String("sample-types", "", "comma-separated type/unit list of the sample values to subtract, e.g. alloc_objects/count,alloc_space/bytes for heap profiles; defaults to every sample value")
+	files, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	before, err := readFile(files[0])
+	if err != nil {
+		return err
+	}
+	after, err := readFile(files[1])
+	if err != nil {
+		return err
+	}
+	var types []pprofutils.ValueType
+	if *sampleTypes != "" {
+		for _, st := range strings.Split(*sampleTypes, ",") {
+			typ, unit, ok := strings.Cut(st, "/")
+			if !ok {
+				return fmt.Errorf("invalid sample type %q, expected type/unit", st)
+			}
+			types = append(types, pprofutils.ValueType{Type: typ, Unit: unit})
+		}
+	} else {
+		p, err := profile.ParseData(after)
+		if err != nil {
+			return fmt.Errorf("%s: %v", files[1], err)
+		}
+		for _, st := range p.SampleType {
+			types = append(types, pprofutils.ValueType{Type: st.Type, Unit: st.Unit})
+		}
+	}
+	dc := fastdelta.NewDeltaComputer(types...)
+	if err := dc.Delta(before, io.Discard); err != nil {
+		return fmt.Errorf("%s: %v", files[0], err)
+	}
+	var buf bytes.Buffer
+	if err := dc.Delta(after, &buf); err != nil {
+		return fmt.Errorf("%s: %v", files[1], err)
+	}
+	p, err := profile.ParseData(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return writeProfile(p, *output, stdout)
+}
+
+func diff(args []string, stdout io.Writer) error {
+	fs, output := newFlagSet("diff", "[-o out.pprof] base.pprof new.pprof")
+	files, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	base, err := readProfile(files[0])
+	if err != nil {
+		return err
+	}
+	p, err := readProfile(files[1])
+	if err != nil {
+		return err
+	}
+	d, err := profiler.PprofDiff(base, p)
+	if err != nil {
+		return err
+	}
+	return writeProfile(d, *output, stdout)
+}
+
+func filter(args []string, stdout io.Writer) error {
+	fs, output := newFlagSet("filter", "[-o out.pprof] -label key[=value] in.pprof")
+	label := fs.String("label", "", "pprof label of the samples to keep, e.g. 'trace endpoint=GET /users', or 'trace endpoint' to keep the samples with the label")
+	files, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *label == "" {
+		fs.Usage()
+		return errUsage
+	}
+	key, value, hasValue := strings.Cut(*label, "=")
+	p, err := readProfile(files[0])
+	if err != nil {
+		return err
+	}
+	kept := p.Sample[:0]
+	for _, s := range p.Sample {
+		values, ok := s.Label[key]
+		if !ok {
+			continue
+		}
+		if !hasValue || contains(values, value) {
+			kept = append(kept, s)
+		}
+	}
+	p.Sample = kept
+	// drop the locations, functions and mappings which are no longer used
+	p, err = profile.Merge([]*profile.Profile{p})
+	if err != nil {
+		return err
+	}
+	return writeProfile(p, *output, stdout)
+}
+
+func contains(values []string, v string) bool {
+	for _, val := range values {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
+
+func convert(args []string, stdout io.Writer) error {
+	fs, output := newFlagSet("convert", "[-o out] -to text|pprof in")
+	to := fs.String("to", "text", "output format, text or pprof")
+	files, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	data, err := readFile(files[0])
+	if err != nil {
+		return err
+	}
+	var p *profile.Profile
+	if p, err = profile.ParseData(data); err != nil {
+		// not a pprof profile, try the text format
+		if p, err = (pprofutils.Text{}).Convert(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: neither a pprof nor a text profile: %v", files[0], err)
+		}
+	}
+	switch *to {
+	case "pprof":
+		return writeProfile(p, *output, stdout)
+	case "text":
+		var buf bytes.Buffer
+		if err := (pprofutils.Protobuf{SampleTypes: true}).Convert(p, &buf); err != nil {
+			return err
+		}
+		return writeOutput(buf.Bytes(), *output, stdout)
+	default:
+		return fmt.Errorf("unknown output format %q", *to)
+	}
+}
+
+// readFile reads the file with the given name, and decompresses it if it's
+// gzipped.
+func readFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, nil
+	}
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	data, err = io.ReadAll(gzr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: decompressing profile: %v", name, err)
+	}
+	return data, nil
+}
+
+// readProfile reads the pprof profile with the given file name.
+func readProfile(name string) (*profile.Profile, error) {
+	data, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return p, nil
+}
+
+// writeProfile writes p, gzipped, to the output file, or to stdout if it's
+// empty.
+func writeProfile(p *profile.Profile, output string, stdout io.Writer) error {
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		return err
+	}
+	return writeOutput(buf.Bytes(), output, stdout)
+}
+
+func writeOutput(data []byte, output string, stdout io.Writer) error {
+	if output == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	// 0644 is what touch does, should be reasonable for the use cases here.
+	return os.WriteFile(output, data, 0644)
+}
@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+// writeTextProfile writes the text profile to a pprof file in dir, and returns
+// its name. The samples get the pprof labels given in the same order, if any.
+func writeTextProfile(t *testing.T, dir, name, text string, labels ...map[string][]string) string {
+	p, err := pprofutils.Text{}.Convert(strings.NewReader(text))
+	require.NoError(t, err)
+	for i, l := range labels {
+		p.Sample[i].Label = l
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, p.Write(f))
+	return path
+}
+
+// runText runs the command and returns its output profile in the text format.
+func runText(t *testing.T, args ...string) string {
+	var out bytes.Buffer
+	require.NoError(t, run(args, &out))
+	p, err := profile.ParseData(out.Bytes())
+	require.NoError(t, err)
+	var text bytes.Buffer
+	require.NoError(t, pprofutils.Protobuf{SampleTypes: true}.Convert(p, &text))
+	return text.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTextProfile(t, dir, "a.pprof", "alloc_objects/count inuse_objects/count\nmain;foo 5 1\nmain;bar 2 1\n")
+	b := writeTextProfile(t, dir, "b.pprof", "alloc_objects/count inuse_objects/count\nmain;foo 8 3\nmain;bar 2 1\nmain;baz 1 1\n")
+
+	t.Run("merge", func(t *testing.T) {
+		assert.Equal(t, "alloc_objects/count inuse_objects/count\nmain;foo 13 4\nmain;bar 4 2\nmain;baz 1 1\n", runText(t, "merge", a, b))
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		assert.Equal(t, "alloc_objects/count inuse_objects/count\nmain;foo 3 3\nmain;baz 1 1\nmain;bar 0 1\n", runText(t, "delta", "-sample-types", "alloc_objects/count", a, b))
+		assert.Equal(t, "alloc_objects/count inuse_objects/count\nmain;foo 3 2\nmain;baz 1 1\n", runText(t, "delta", a, b))
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		assert.Equal(t, "alloc_objects/count inuse_objects/count\nmain;foo 3 2\nmain;baz 1 1\n", runText(t, "diff", a, b))
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		labeled := writeTextProfile(t, dir, "labeled.pprof", "samples/count\nmain;foo 5\nmain;bar 2\nmain;baz 1\n",
+			map[string][]string{"trace endpoint": {"GET /users"}},
+			map[string][]string{"trace endpoint": {"POST /users"}},
+		)
+		assert.Equal(t, "samples/count\nmain;foo 5\n", runText(t, "filter", "-label", "trace endpoint=GET /users", labeled))
+		assert.Equal(t, "samples/count\nmain;foo 5\nmain;bar 2\n", runText(t, "filter", "-label", "trace endpoint", labeled))
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, run([]string{"convert", a}, &out))
+		assert.Equal(t, "alloc_objects/count inuse_objects/count\nmain;foo 5 1\nmain;bar 2 1\n", out.String())
+
+		text := filepath.Join(dir, "c.txt")
+		require.NoError(t, os.WriteFile(text, []byte("samples/count\nmain;foo 5\n"), 0644))
+		assert.Equal(t, "samples/count\nmain;foo 5\n", runText(t, "convert", "-to", "pprof", text))
+	})
+
+	t.Run("output", func(t *testing.T) {
+		output := filepath.Join(dir, "out.pprof")
+		var out bytes.Buffer
+		require.NoError(t, run([]string{"merge", "-o", output, a}, &out))
+		assert.Empty(t, out.Bytes())
+		_, err := readProfile(output)
+		require.NoError(t, err)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Equal(t, errUsage, run(nil, &out))
+		assert.Equal(t, errUsage, run([]string{"nope"}, &out))
+		assert.Equal(t, errUsage, run([]string{"diff", a}, &out))
+		assert.Equal(t, errUsage, run([]string{"filter", a}, &out))
+		assert.Error(t, run([]string{"merge", filepath.Join(dir, "missing.pprof")}, &out))
+	})
+}
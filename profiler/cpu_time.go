@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+)
+
+// endpointCPUTime returns the CPU time spent by each endpoint in the CPU
+// profile data, according to the TraceEndpoint label applied by the tracer,
+// along with the duration of the profile.
+func endpointCPUTime(data []byte) (map[string]time.Duration, time.Duration, error) {
+	prof, err := pprofile.ParseData(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	idx := -1
+	for i, st := range prof.SampleType {
+		if st.Type == "cpu" && st.Unit == "nanoseconds" {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, 0, nil
+	}
+	cpu := make(map[string]time.Duration)
+	for _, s := range prof.Sample {
+		if ep := s.Label[traceprof.TraceEndpoint]; len(ep) > 0 {
+			cpu[ep[0]] += time.Duration(s.Value[idx])
+		}
+	}
+	return cpu, time.Duration(prof.DurationNanos), nil
+}
+
+// reportCPUTimePerRequest computes the CPU time per request of each endpoint
+// from the CPU profile and the endpoint counts of bat. It reports it with the
+// datadog.profiling.go.cpu_time_per_request metric, and shares it with the
+// tracer which adds it to the local root spans of the endpoints.
+func (p *profiler) reportCPUTimePerRequest(bat batch) {
+	var cpuProf *profile
+	for _, prof := range bat.profiles {
+		if prof.pt == CPUProfile {
+			cpuProf = prof
+		}
+	}
+	if cpuProf == nil || len(bat.endpointCounts) == 0 {
+		return
+	}
+	cpu, duration, err := endpointCPUTime(cpuProf.data)
+	if err != nil {
+		log.Error("Failed to compute the CPU time per request: %v", err)
+		return
+	}
+	// The endpoints are counted during the whole batch, while the CPU profile
+	// may only cover the last CPU duration of the period, or less when it was
+	// cut short by a capture.
+	if duration <= 0 {
+		duration = p.cfg.cpuDuration
+	}
+	scale := float64(1)
+	if d := bat.end.Sub(bat.start); duration > 0 && duration < d {
+		scale = float64(d) / float64(duration)
+	}
+	perRequest := make(map[string]time.Duration, len(cpu))
+	for ep, d := range cpu {
+		hits := bat.endpointCounts[ep]
+		if hits == 0 {
+			continue
+		}
+		perRequest[ep] = time.Duration(float64(d) * scale / float64(hits))
+		tags := append(p.cfg.tags.Slice(), labelTag(traceprof.TraceEndpoint, ep))
+		p.cfg.statsd.Timing("datadog.profiling.go.cpu_time_per_request", perRequest[ep], tags, 1)
+	}
+	traceprof.SetEndpointCPUTime(perRequest)
+}
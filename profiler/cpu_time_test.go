@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+// timingStatsd is a StatsdClient recording the reported timings.
+type timingStatsd struct {
+	mu      sync.Mutex
+	timings map[string]time.Duration
+}
+
+func (s *timingStatsd) Count(string, int64, []string, float64) error { return nil }
+
+func (s *timingStatsd) Timing(event string, d time.Duration, tags []string, _ float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timings == nil {
+		s.timings = make(map[string]time.Duration)
+	}
+	s.timings[event+" "+strings.Join(tags, ",")] = d
+	return nil
+}
+
+// labeledCPUProfile returns a CPU profile of the given duration whose samples
+// get the given trace endpoint labels in the same order.
+func labeledCPUProfile(t *testing.T, duration time.Duration, text string, endpoints ...string) []byte {
+	prof, err := pprofutils.Text{}.Convert(strings.NewReader(text))
+	require.NoError(t, err)
+	prof.DurationNanos = duration.Nanoseconds()
+	for i, ep := range endpoints {
+		prof.Sample[i].Label = map[string][]string{traceprof.TraceEndpoint: {ep}}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, prof.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestReportCPUTimePerRequest(t *testing.T) {
+	defer traceprof.SetEndpointCPUTime(nil)
+	stats := &timingStatsd{}
+	p, err := unstartedProfiler(WithCPUTimePerRequest(true), WithStatsd(stats), CPUDuration(30*time.Second))
+	require.NoError(t, err)
+	start := time.Now()
+	bat := batch{
+		start: start,
+		end:   start.Add(time.Minute),
+		profiles: []*profile{{
+			name: "cpu.pprof",
+			pt:   CPUProfile,
+			data: labeledCPUProfile(t, 30*time.Second,
+				"samples/count cpu/nanoseconds\nmain;users 2 20000000\nmain;orders 1 10000000\nmain;gc 5 50000000\n",
+				"GET /users", "POST /orders"),
+		}},
+		endpointCounts: map[string]uint64{"GET /users": 4, "GET /unsampled": 3},
+	}
+	p.reportCPUTimePerRequest(bat)
+
+	// 20ms in 30s of CPU profile, scaled to the minute of the batch, for 4
+	// requests; POST /orders has no counted request.
+	d, ok := traceprof.EndpointCPUTime("GET /users")
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, d)
+	_, ok = traceprof.EndpointCPUTime("POST /orders")
+	assert.False(t, ok)
+	_, ok = traceprof.EndpointCPUTime("GET /unsampled")
+	assert.False(t, ok)
+	assert.Len(t, stats.timings, 1)
+	for name, d := range stats.timings {
+		assert.Contains(t, name, "datadog.profiling.go.cpu_time_per_request")
+		assert.Contains(t, name, "trace_endpoint:GET /users")
+		assert.Equal(t, 10*time.Millisecond, d)
+	}
+
+	t.Run("cut-short", func(t *testing.T) {
+		// a capture stopped the CPU profile after 15s
+		bat := bat
+		bat.profiles = []*profile{{
+			name: "cpu.pprof",
+			pt:   CPUProfile,
+			data: labeledCPUProfile(t, 15*time.Second,
+				"samples/count cpu/nanoseconds\nmain;users 2 20000000\n",
+				"GET /users"),
+		}}
+		p.reportCPUTimePerRequest(bat)
+		d, ok := traceprof.EndpointCPUTime("GET /users")
+		assert.True(t, ok)
+		assert.Equal(t, 20*time.Millisecond, d)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		bat.profiles[0].data = []byte("broken")
+		p.reportCPUTimePerRequest(bat)
+		d, ok := traceprof.EndpointCPUTime("GET /users")
+		assert.True(t, ok, "the previous CPU time should be kept")
+		assert.Equal(t, 20*time.Millisecond, d)
+	})
+}
@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+// ValueType identifies a sample value of a pprof profile by its type and unit,
+// e.g. {Type: "alloc_space", Unit: "bytes"}.
+type ValueType struct {
+	Type string
+	Unit string
+}
+
+// customProfile is a user-defined profile registered with WithCustomProfile.
+type customProfile struct {
+	name        string
+	collect     func() ([]byte, error)
+	deltaValues []ValueType
+}
+
+// customProfileBase is the ProfileType of the first custom profile. The following
+// custom profiles get the next values, in the order in which they were registered.
+const customProfileBase ProfileType = 1 << 16
+
+// customProfileName restricts the names of custom profiles to the characters
+// which can safely be used in upload filenames and tags.
+var customProfileName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// customProfileTypes validates the given custom profiles and returns the profile
+// types implementing them.
+func customProfileTypes(cps []customProfile) (map[ProfileType]profileType, error) {
+	names := make(map[string]bool)
+	for _, t := range profileTypes {
+		names[t.Name] = true
+	}
+	types := make(map[ProfileType]profileType, len(cps))
+	for i, cp := range cps {
+		if !customProfileName.MatchString(cp.name) {
+			return nil, fmt.Errorf("invalid custom profile name %q: only letters, digits, '_' and '-' are allowed", cp.name)
+		}
+		if names[cp.name] {
+			return nil, fmt.Errorf("duplicate profile name %q", cp.name)
+		}
+		if cp.collect == nil {
+			return nil, fmt.Errorf("custom profile %q has no collect function", cp.name)
+		}
+		names[cp.name] = true
+		pt := customProfileBase + ProfileType(i)
+		types[pt] = newCustomProfileType(pt, cp)
+	}
+	return types, nil
+}
+
+// newCustomProfileType returns the implementation of the custom profile cp.
+func newCustomProfileType(pt ProfileType, cp customProfile) profileType {
+	t := profileType{
+		Type:     pt,
+		Name:     cp.name,
+		Filename: cp.name + ".pprof",
+		Collect: func(p *profiler) (data []byte, err error) {
+			p.interruptibleSleep(p.cfg.period)
+			sw := internal.NewStopwatch()
+			defer func() { p.overhead.record(pt, sw.Tick()) }()
+			// don't let a faulty collect function crash the application
+			defer func() {
+				if r := recover(); r != nil {
+					data, err = nil, fmt.Errorf("custom profile %q panicked: %v", cp.name, r)
+				}
+			}()
+			data, err = cp.collect()
+			if err != nil {
+				return nil, err
+			}
+			return p.deltaProfile(cp.name, pt, data)
+		},
+	}
+	for _, v := range cp.deltaValues {
+		t.DeltaValues = append(t.DeltaValues, pprofutils.ValueType{Type: v.Type, Unit: v.Unit})
+	}
+	return t
+}
+
+// lookup returns the implementation of pt, which may be a custom profile.
+func (p *profiler) lookup(pt ProfileType) profileType {
+	if t, ok := p.custom[pt]; ok {
+		return t
+	}
+	return pt.lookup()
+}
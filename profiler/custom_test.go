@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomProfile(t *testing.T) {
+	var (
+		timeA = time.Now().Truncate(time.Minute)
+		timeB = timeA.Add(DefaultPeriod)
+		prof1 = textProfile{Time: timeA, Text: `
+connections/count wait/nanoseconds
+main;db 3 10
+main;cache 2 5
+`}
+		prof2 = textProfile{Time: timeB, Text: `
+connections/count wait/nanoseconds
+main;db 4 10
+main;cache 2 7
+`}
+	)
+
+	t.Run("delta", func(t *testing.T) {
+		profs := [][]byte{prof1.Protobuf(), prof2.Protobuf()}
+		p, err := unstartedProfiler(
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithCustomProfile("connections", func() ([]byte, error) {
+				data := profs[0]
+				profs = profs[1:]
+				return data, nil
+			}, ValueType{Type: "wait", Unit: "nanoseconds"}),
+		)
+		require.NoError(t, err)
+
+		pt := customProfileBase
+		assert.Contains(t, p.enabledProfileTypes(), pt)
+		_, err = p.runProfile(pt)
+		require.NoError(t, err)
+		res, err := p.runProfile(pt)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, "delta-connections.pprof", res[0].name)
+		assert.Equal(t, pt, res[0].pt)
+		assert.Equal(t, textProfile{Text: `
+connections/count wait/nanoseconds
+main;db 4 0
+main;cache 2 2
+`}.String(), protobufToText(res[0].data))
+	})
+
+	t.Run("no-delta", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithDeltaProfiles(false),
+			WithCustomProfile("connections", func() ([]byte, error) {
+				return prof1.Protobuf(), nil
+			}, ValueType{Type: "wait", Unit: "nanoseconds"}),
+		)
+		require.NoError(t, err)
+		res, err := p.runProfile(customProfileBase)
+		require.NoError(t, err)
+		assert.Equal(t, "connections.pprof", res[0].name)
+		assert.Equal(t, prof1.String(), protobufToText(res[0].data))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithCustomProfile("failing", func() ([]byte, error) {
+				return nil, errors.New("oops")
+			}),
+			WithCustomProfile("panicking", func() ([]byte, error) {
+				panic("boom")
+			}),
+		)
+		require.NoError(t, err)
+		_, err = p.runProfile(customProfileBase)
+		assert.EqualError(t, err, "oops")
+		_, err = p.runProfile(customProfileBase + 1)
+		assert.EqualError(t, err, `custom profile "panicking" panicked: boom`)
+	})
+}
+
+func TestCustomProfileValidation(t *testing.T) {
+	collect := func() ([]byte, error) { return nil, nil }
+	for name, tt := range map[string]struct {
+		opts []Option
+		err  string
+	}{
+		"invalid-name": {
+			opts: []Option{WithCustomProfile("my/profile", collect)},
+			err:  `invalid custom profile name "my/profile": only letters, digits, '_' and '-' are allowed`,
+		},
+		"builtin-name": {
+			opts: []Option{WithCustomProfile("heap", collect)},
+			err:  `duplicate profile name "heap"`,
+		},
+		"duplicate-name": {
+			opts: []Option{WithCustomProfile("files", collect), WithCustomProfile("files", collect)},
+			err:  `duplicate profile name "files"`,
+		},
+		"no-collect": {
+			opts: []Option{WithCustomProfile("files", nil)},
+			err:  `custom profile "files" has no collect function`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := unstartedProfiler(tt.opts...)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestCustomProfileUploaded(t *testing.T) {
+	got := make(chan profileMeta)
+	server := httptest.NewServer(&mockBackend{t: t, profiles: got})
+	defer server.Close()
+
+	prof := textProfile{Text: `
+files/count
+main;open 3
+`}
+	err := Start(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithProfileTypes(),
+		WithPeriod(10*time.Millisecond),
+		WithCustomProfile("files", func() ([]byte, error) {
+			return prof.Protobuf(), nil
+		}),
+	)
+	require.NoError(t, err)
+	defer Stop()
+
+	m := <-got
+	assert.Contains(t, m.event.Attachments, "files.pprof")
+	assert.Equal(t, prof.String(), protobufToText(m.attachments["files.pprof"]))
+}
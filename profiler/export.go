@@ -0,0 +1,248 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Batch is a set of profiles collected over the same period, along with the
+// metadata uploaded with them.
+type Batch struct {
+	// Start and End delimit the period covered by the profiles.
+	Start, End time.Time
+
+	// Tags are the tags of the profiles, in the "key:value" format.
+	Tags []string
+
+	// Profiles are the profiles of the batch.
+	Profiles []Profile
+
+	// EndpointCounts counts the hits of each endpoint during the period,
+	// see WithEndpointCounts.
+	EndpointCounts map[string]uint64
+}
+
+// Profile is a profile of a Batch.
+type Profile struct {
+	// Name is the file name of the profile, e.g. "cpu.pprof" or
+	// "delta-heap.pprof".
+	Name string
+
+	// Data is the content of the profile.
+	Data []byte
+}
+
+// Exporter receives the batches of profiles collected by the profiler, see
+// WithExporter.
+type Exporter interface {
+	// Export exports the batch b. It must return once ctx is done. It may be
+	// called concurrently, and b must not be retained after it returns.
+	Export(ctx context.Context, b Batch) error
+}
+
+// exportedBatch returns the public representation of bat.
+func (p *profiler) exportedBatch(bat batch) Batch {
+	b := Batch{
+		Start:          bat.start,
+		End:            bat.end,
+		Tags:           p.tags(bat),
+		EndpointCounts: bat.endpointCounts,
+	}
+	for _, prof := range bat.profiles {
+		b.Profiles = append(b.Profiles, Profile{Name: prof.name, Data: prof.data})
+	}
+	return b
+}
+
+// export exports bat with every configured exporter. It returns the first
+// error encountered.
+func (p *profiler) export(bat batch) error {
+	if len(p.cfg.exporters) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.uploadTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.exit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	b := p.exportedBatch(bat)
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(p.cfg.exporters))
+	)
+	for i, e := range p.cfg.exporters {
+		wg.Add(1)
+		go func(i int, e Exporter) {
+			defer wg.Done()
+			errs[i] = e.Export(ctx, b)
+		}(i, e)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeExporters closes the exporters implementing io.Closer.
+func (p *profiler) closeExporters() error {
+	var first error
+	for _, e := range p.cfg.exporters {
+		if c, ok := e.(io.Closer); ok {
+			if err := c.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// batchDir returns the name of the directory holding the profiles of b in the
+// built-in exporters: the end of the batch in the basic ISO 8601 format, in UTC.
+func batchDir(b Batch) string {
+	return b.End.UTC().Format("20060102T150405Z")
+}
+
+// batchFiles returns the files written by the built-in exporters for b: its
+// profiles and the event.json file holding its metadata.
+func batchFiles(b Batch) ([]Profile, error) {
+	event, err := json.Marshal(newUploadEvent(b))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]Profile, 0, len(b.Profiles)+1)
+	files = append(files, b.Profiles...)
+	return append(files, Profile{Name: "event.json", Data: event}), nil
+}
+
+// NewDirExporter returns an Exporter writing each batch of profiles to its
+// own subdirectory of dir, named after the end of the batch, along with an
+// event.json file holding the metadata of the batch. No cleanup is performed,
+// so the directory keeps growing.
+func NewDirExporter(dir string) Exporter {
+	return &dirExporter{dir: dir}
+}
+
+type dirExporter struct {
+	dir string
+}
+
+// Export implements Exporter.
+func (e *dirExporter) Export(_ context.Context, b Batch) error {
+	dirPath := filepath.Join(e.dir, batchDir(b))
+	// 0755 is what mkdir does, should be reasonable for the use cases here.
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return err
+	}
+	files, err := batchFiles(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		// 0644 is what touch does, should be reasonable for the use cases here.
+		if err := os.WriteFile(filepath.Join(dirPath, f.Name), f.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveExporter is an Exporter writing batches of profiles to an archive,
+// see NewTarExporter and NewZipExporter. Each batch is written to its own
+// directory of the archive, named after the end of the batch, along with an
+// event.json file holding the metadata of the batch.
+//
+// The archive is complete once the exporter is closed, which the profiler does
+// when it is stopped.
+type ArchiveExporter struct {
+	mu     sync.Mutex
+	create func(name string, size int64, modTime time.Time) (io.Writer, error)
+	close  func() error
+	closed bool
+}
+
+// NewTarExporter returns an ArchiveExporter writing a tar archive to w.
+func NewTarExporter(w io.Writer) *ArchiveExporter {
+	tw := tar.NewWriter(w)
+	return &ArchiveExporter{
+		create: func(name string, size int64, modTime time.Time) (io.Writer, error) {
+			err := tw.WriteHeader(&tar.Header{
+				Name:    name,
+				Mode:    0644,
+				Size:    size,
+				ModTime: modTime,
+			})
+			return tw, err
+		},
+		close: tw.Close,
+	}
+}
+
+// NewZipExporter returns an ArchiveExporter writing a zip archive to w.
+func NewZipExporter(w io.Writer) *ArchiveExporter {
+	zw := zip.NewWriter(w)
+	return &ArchiveExporter{
+		create: func(name string, _ int64, modTime time.Time) (io.Writer, error) {
+			return zw.CreateHeader(&zip.FileHeader{
+				Name:     name,
+				Method:   zip.Deflate,
+				Modified: modTime,
+			})
+		},
+		close: zw.Close,
+	}
+}
+
+// Export implements Exporter.
+func (e *ArchiveExporter) Export(_ context.Context, b Batch) error {
+	files, err := batchFiles(b)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errors.New("profiler: archive exporter is closed")
+	}
+	for _, f := range files {
+		w, err := e.create(path.Join(batchDir(b), f.Name), int64(len(f.Data)), b.End)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close completes the archive. It doesn't close the underlying writer.
+func (e *ArchiveExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.close()
+}
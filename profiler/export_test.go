@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exporterFunc implements Exporter.
+type exporterFunc func(ctx context.Context, b Batch) error
+
+func (f exporterFunc) Export(ctx context.Context, b Batch) error { return f(ctx, b) }
+
+func exportBatch() batch {
+	return batch{
+		seq:   3,
+		host:  "my-host",
+		start: time.Date(2023, 1, 2, 3, 4, 0, 0, time.UTC),
+		end:   time.Date(2023, 1, 2, 3, 5, 0, 0, time.UTC),
+		profiles: []*profile{
+			{name: "cpu.pprof", data: []byte("cpu")},
+			{name: "delta-heap.pprof", data: []byte("heap")},
+		},
+	}
+}
+
+func TestExportedBatch(t *testing.T) {
+	p, err := unstartedProfiler(WithService("my-service"), WithEnv("my-env"))
+	require.NoError(t, err)
+	b := p.exportedBatch(exportBatch())
+	assert.Equal(t, []Profile{
+		{Name: "cpu.pprof", Data: []byte("cpu")},
+		{Name: "delta-heap.pprof", Data: []byte("heap")},
+	}, b.Profiles)
+	assert.Subset(t, b.Tags, []string{
+		"service:my-service",
+		"env:my-env",
+		"profile_seq:3",
+		"host:my-host",
+		"runtime:go",
+	})
+}
+
+func TestDirExporter(t *testing.T) {
+	dir := t.TempDir()
+	p, err := unstartedProfiler(WithExporter(NewDirExporter(dir)))
+	require.NoError(t, err)
+	require.NoError(t, p.export(exportBatch()))
+
+	batchDir := filepath.Join(dir, "20230102T030500Z")
+	data, err := os.ReadFile(filepath.Join(batchDir, "cpu.pprof"))
+	require.NoError(t, err)
+	assert.Equal(t, "cpu", string(data))
+	data, err = os.ReadFile(filepath.Join(batchDir, "event.json"))
+	require.NoError(t, err)
+	var event uploadEvent
+	require.NoError(t, json.Unmarshal(data, &event))
+	assert.Equal(t, []string{"cpu.pprof", "delta-heap.pprof"}, event.Attachments)
+	assert.Equal(t, "2023-01-02T03:04:00Z", event.Start)
+}
+
+func TestArchiveExporter(t *testing.T) {
+	want := map[string]string{
+		"20230102T030500Z/cpu.pprof":        "cpu",
+		"20230102T030500Z/delta-heap.pprof": "heap",
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewTarExporter(&buf)
+		p, err := unstartedProfiler(WithExporter(e))
+		require.NoError(t, err)
+		require.NoError(t, p.export(exportBatch()))
+		require.NoError(t, e.Close())
+
+		got := map[string]string{}
+		tr := tar.NewReader(&buf)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			got[h.Name] = string(data)
+		}
+		assert.Contains(t, got, "20230102T030500Z/event.json")
+		delete(got, "20230102T030500Z/event.json")
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewZipExporter(&buf)
+		p, err := unstartedProfiler(WithExporter(e))
+		require.NoError(t, err)
+		require.NoError(t, p.export(exportBatch()))
+		require.NoError(t, e.Close())
+		assert.EqualError(t, p.export(exportBatch()), "profiler: archive exporter is closed")
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		got := map[string]string{}
+		for _, f := range zr.File {
+			r, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			got[f.Name] = string(data)
+		}
+		assert.Contains(t, got, "20230102T030500Z/event.json")
+		delete(got, "20230102T030500Z/event.json")
+		assert.Equal(t, want, got)
+	})
+}
+
+func TestExporters(t *testing.T) {
+	t.Run("several", func(t *testing.T) {
+		got := make(chan Batch, 10)
+		e := exporterFunc(func(_ context.Context, b Batch) error {
+			got <- b
+			return nil
+		})
+		p, err := unstartedProfiler(
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithCustomProfile("files", func() ([]byte, error) {
+				return textProfile{Text: "files/count\nmain 1\n"}.Protobuf(), nil
+			}),
+			WithExporter(e),
+			WithExporter(e),
+			WithUpload(false),
+		)
+		require.NoError(t, err)
+		uploaded := make(chan batch, 10)
+		p.uploadFunc = func(bat batch) error {
+			uploaded <- bat
+			return nil
+		}
+		p.run()
+		b1, b2 := <-got, <-got
+		p.stop()
+		assert.Equal(t, b1, b2)
+		assert.Equal(t, "files.pprof", b1.Profiles[0].Name)
+		assert.Empty(t, uploaded)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			WithExporter(exporterFunc(func(context.Context, Batch) error { return nil })),
+			WithExporter(exporterFunc(func(context.Context, Batch) error { return errors.New("oops") })),
+		)
+		require.NoError(t, err)
+		assert.EqualError(t, p.export(exportBatch()), "oops")
+	})
+}
@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+)
+
+// flightRecorder keeps a rolling window of the execution trace.
+type flightRecorder interface {
+	Start() error
+	Stop()
+	WriteTo(w io.Writer) (int64, error)
+}
+
+// flightRecorderConfig configures a flightRecorder.
+type flightRecorderConfig struct {
+	MinAge   time.Duration // MinAge is the duration covered by the window
+	MaxBytes uint64        // MaxBytes bounds the size of the window, it takes precedence over MinAge
+}
+
+// recordSlowSpans runs the flight recorder mode of the execution traces: it
+// records the execution trace with a flight recorder, and uploads its window
+// when the tracer reports a span lasting longer than the threshold. At most
+// one window is uploaded per profiling period. It returns when the profiler
+// is stopped.
+func (p *profiler) recordSlowSpans() {
+	newFR := newFlightRecorder
+	if p.testHooks.newFlightRecorder != nil {
+		newFR = p.testHooks.newFlightRecorder
+	}
+	cfg := flightRecorderConfig{
+		MinAge:   p.cfg.period,
+		MaxBytes: uint64(p.cfg.traceConfig.Limit),
+	}
+	fr, err := newFR(cfg)
+	if err != nil {
+		log.Warn("Execution trace flight recorder unavailable: %v", err)
+		return
+	}
+	if err := fr.Start(); err != nil {
+		log.Error("Failed to start the execution trace flight recorder: %v", err)
+		return
+	}
+	defer fr.Stop()
+
+	slow := make(chan traceprof.SlowSpan, 1)
+	traceprof.SetSlowSpanHandler(p.cfg.traceConfig.SlowSpanThreshold, func(s traceprof.SlowSpan) {
+		select {
+		case slow <- s:
+		default:
+			// a flush is pending already
+		}
+	})
+	defer traceprof.SetSlowSpanHandler(0, nil)
+
+	var last time.Time
+	for {
+		select {
+		case <-p.exit:
+			return
+		case s := <-slow:
+			if !last.IsZero() && now().Sub(last) < p.period() {
+				continue
+			}
+			last = now()
+			p.flushFlightRecorder(fr, cfg, s)
+		}
+	}
+}
+
+// flushFlightRecorder uploads the window of fr, tagged with the pprof labels of
+// the slow span s so that the trace can be found from it.
+func (p *profiler) flushFlightRecorder(fr flightRecorder, cfg flightRecorderConfig, s traceprof.SlowSpan) {
+	var buf bytes.Buffer
+	if _, err := fr.WriteTo(&buf); err != nil {
+		log.Error("Failed to flush the execution trace flight recorder: %v", err)
+		return
+	}
+	end := now()
+	bat := batch{
+		host:    p.cfg.hostname,
+		start:   end.Add(-cfg.MinAge),
+		end:     end,
+		trigger: "slow_span",
+	}
+	for _, l := range []struct{ label, value string }{
+		{traceprof.SpanID, s.SpanID},
+		{traceprof.LocalRootSpanID, s.LocalRootSpanID},
+		{traceprof.TraceEndpoint, s.Endpoint},
+	} {
+		if l.value != "" {
+			bat.tags = append(bat.tags, labelTag(l.label, l.value))
+		}
+	}
+	bat.addProfile(&profile{name: executionTrace.Filename(), pt: executionTrace, data: buf.Bytes()})
+	p.cfg.statsd.Count("datadog.profiling.go.slow_span_trace", 1, p.cfg.tags.Slice(), 1)
+	p.redactBatch(&bat)
+	if len(bat.profiles) == 0 {
+		return
+	}
+
+	if err := p.export(bat); err != nil {
+		log.Error("Failed to export profile: %v", err)
+	}
+	if !p.cfg.uploadEnabled {
+		return
+	}
+	if err := p.uploadFunc(bat); err != nil {
+		log.Error("Failed to upload profile: %v", err)
+	}
+}
+
+// labelTag returns the tag holding the pprof label with the given key and
+// value, e.g. "trace_endpoint:GET /users" for the TraceEndpoint label.
+func labelTag(key, value string) string {
+	key = strings.ReplaceAll(key, " ", "_")
+	// the tags are uploaded as a comma-separated list
+	return key + ":" + strings.ReplaceAll(value, ",", "_")
+}
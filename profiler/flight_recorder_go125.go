@@ -0,0 +1,19 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build go1.25
+// +build go1.25
+
+package profiler
+
+import "runtime/trace"
+
+// newFlightRecorder returns a flight recorder using runtime/trace.
+func newFlightRecorder(cfg flightRecorderConfig) (flightRecorder, error) {
+	return trace.NewFlightRecorder(trace.FlightRecorderConfig{
+		MinAge:   cfg.MinAge,
+		MaxBytes: cfg.MaxBytes,
+	}), nil
+}
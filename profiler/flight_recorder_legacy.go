@@ -0,0 +1,17 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:build !go1.25
+// +build !go1.25
+
+package profiler
+
+import "errors"
+
+// newFlightRecorder returns an error, runtime/trace has no flight recorder
+// before Go 1.25.
+func newFlightRecorder(_ flightRecorderConfig) (flightRecorder, error) {
+	return nil, errors.New("the execution trace flight recorder requires Go 1.25 or later")
+}
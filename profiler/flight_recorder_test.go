@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFlightRecorder struct {
+	started, stopped bool
+}
+
+func (m *mockFlightRecorder) Start() error { m.started = true; return nil }
+
+func (m *mockFlightRecorder) Stop() { m.stopped = true }
+
+func (m *mockFlightRecorder) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, "trace data")
+	return int64(n), err
+}
+
+func TestFlightRecorder(t *testing.T) {
+	p, err := unstartedProfiler(WithProfileTypes(), WithPeriod(time.Hour), WithSlowSpanExecutionTraces(100*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, p.shouldTrace())
+
+	fr := &mockFlightRecorder{}
+	var cfg flightRecorderConfig
+	p.testHooks.newFlightRecorder = func(c flightRecorderConfig) (flightRecorder, error) {
+		cfg = c
+		return fr, nil
+	}
+	uploads := make(chan batch, 2)
+	p.uploadFunc = func(bat batch) error {
+		uploads <- bat
+		return nil
+	}
+	p.run()
+	for traceprof.SlowSpanThreshold() == 0 {
+		runtime.Gosched()
+	}
+	assert.Equal(t, 100*time.Millisecond, traceprof.SlowSpanThreshold())
+
+	// too fast
+	traceprof.ReportSlowSpan(traceprof.SlowSpan{SpanID: "1", Duration: time.Millisecond})
+	traceprof.ReportSlowSpan(traceprof.SlowSpan{
+		SpanID:          "2",
+		LocalRootSpanID: "3",
+		Endpoint:        "GET /users",
+		Duration:        time.Second,
+	})
+	bat := <-uploads
+	// rate limited to one flush per profiling period
+	traceprof.ReportSlowSpan(traceprof.SlowSpan{SpanID: "4", Duration: time.Second})
+	p.stop()
+
+	assert.Equal(t, "slow_span", bat.trigger)
+	assert.Equal(t, []string{
+		"span_id:2",
+		"local_root_span_id:3",
+		"trace_endpoint:GET /users",
+	}, bat.tags)
+	require.Len(t, bat.profiles, 1)
+	assert.Equal(t, "go.trace", bat.profiles[0].name)
+	assert.Equal(t, "trace data", string(bat.profiles[0].data))
+	assert.Equal(t, time.Hour, cfg.MinAge)
+	assert.Equal(t, uint64(defaultExecutionTraceSizeLimit), cfg.MaxBytes)
+	assert.True(t, fr.started)
+	assert.True(t, fr.stopped)
+	assert.Empty(t, uploads)
+	assert.Zero(t, traceprof.SlowSpanThreshold())
+}
@@ -0,0 +1,291 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+/*
+Package redact rewrites pprof profiles to hide the function names, file names,
+mapping paths and label values matching a set of patterns, and to drop the pprof
+labels which aren't allow-listed.
+
+The profiles are rewritten in two passes over their protobuf encoding, using
+pproflite, without building an in-memory representation of the profile:
+
+Pass 1
+* Read the string table, keeping references to the strings of the input
+* Find the strings used as function names, file names and mapping paths
+* Find the strings used by the labels, as label values or only by the labels to
+drop
+
+Pass 2
+* Write out every field of the profile, except for the labels whose key isn't
+allow-listed
+* Write out the redacted value of the strings found in pass 1 which match one of
+the patterns, and an empty string in place of the strings only used by the
+dropped labels, so that they aren't uploaded
+
+Only the string table is rewritten, so the function, location and mapping
+records stay the same, and redacted functions can still be told apart by their
+line numbers and addresses.
+*/
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pproflite"
+)
+
+// Redacted replaces the redacted strings, unless Config.Hash is set.
+const Redacted = "<redacted>"
+
+// Config configures a Redactor.
+type Config struct {
+	// Patterns are matched against the function names, file names, mapping
+	// paths and label values of the profile. The matching strings are
+	// redacted.
+	Patterns []*regexp.Regexp
+	// Hash replaces the redacted strings with "<redacted:HASH>" instead of
+	// Redacted, HASH being the beginning of their hex-encoded SHA-256 hash, so
+	// that distinct strings remain distinct.
+	Hash bool
+	// AllowedLabels are the keys of the pprof labels to keep. Every label is
+	// kept if it's nil.
+	AllowedLabels []string
+}
+
+// Redactor rewrites pprof profiles according to its Config. It is not safe
+// for concurrent use, but it can be reused to avoid allocations.
+type Redactor struct {
+	cfg     Config
+	allowed map[string]struct{}
+
+	decoder pproflite.Decoder
+	encoder pproflite.Encoder
+	// strings references the string table of the current profile
+	strings [][]byte
+	// uses holds how each string of the string table is used, see useSymbol
+	uses []uint8
+	buf  []byte
+}
+
+// The uses of a string, combined in Redactor.uses.
+const (
+	// useSymbol is a function name, file name or mapping path
+	useSymbol uint8 = 1 << iota
+	// useLabel is the value of a kept label
+	useLabel
+	// useDropped is the key, value or unit of a dropped label
+	useDropped
+	// useOther is any other use of the string, e.g. a sample type
+	useOther
+)
+
+// New returns a Redactor configured by cfg.
+func New(cfg Config) *Redactor {
+	r := &Redactor{cfg: cfg}
+	if cfg.AllowedLabels != nil {
+		r.allowed = make(map[string]struct{}, len(cfg.AllowedLabels))
+		for _, k := range cfg.AllowedLabels {
+			r.allowed[k] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Redact writes the uncompressed pprof profile p to out, redacted.
+func (r *Redactor) Redact(p []byte, out io.Writer) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("internal panic during redaction: %v", e)
+		}
+	}()
+	r.decoder.Reset(p)
+	r.encoder.Reset(out)
+	r.strings = r.strings[:0]
+	r.uses = r.uses[:0]
+
+	if err := r.pass1Index(); err != nil {
+		return fmt.Errorf("pass1Index: %w", err)
+	} else if err := r.pass2Write(); err != nil {
+		return fmt.Errorf("pass2Write: %w", err)
+	}
+	return nil
+}
+
+func (r *Redactor) pass1Index() error {
+	err := r.decoder.FieldEach(
+		func(f pproflite.Field) error {
+			switch t := f.(type) {
+			case *pproflite.SampleType:
+				r.use(t.Type, useOther)
+				r.use(t.Unit, useOther)
+			case *pproflite.Mapping:
+				r.use(t.Filename, useSymbol)
+				r.use(t.BuildID, useOther)
+			case *pproflite.Function:
+				r.use(t.Name, useSymbol)
+				r.use(t.SystemName, useSymbol)
+				r.use(t.FileName, useSymbol)
+			case *pproflite.StringTable:
+				r.strings = append(r.strings, t.Value)
+			case *pproflite.DropFrames:
+				r.use(t.Value, useOther)
+			case *pproflite.KeepFrames:
+				r.use(t.Value, useOther)
+			case *pproflite.PeriodType:
+				r.use(t.Type, useOther)
+				r.use(t.Unit, useOther)
+			case *pproflite.Comment:
+				r.use(t.Value, useOther)
+			case *pproflite.DefaultSampleType:
+				r.use(t.Value, useOther)
+			default:
+				return fmt.Errorf("unexpected field: %T", f)
+			}
+			return nil
+		},
+		pproflite.SampleTypeDecoder,
+		pproflite.MappingDecoder,
+		pproflite.FunctionDecoder,
+		pproflite.StringTableDecoder,
+		pproflite.DropFramesDecoder,
+		pproflite.KeepFramesDecoder,
+		pproflite.PeriodTypeDecoder,
+		pproflite.CommentDecoder,
+		pproflite.DefaultSampleTypeDecoder,
+	)
+	if err != nil || (r.allowed == nil && len(r.cfg.Patterns) == 0) {
+		return err
+	}
+	// The samples usually come before the string table, so that their labels
+	// are indexed once the label keys are known
+	return r.decoder.FieldEach(
+		func(f pproflite.Field) error {
+			t, ok := f.(*pproflite.Sample)
+			if !ok {
+				return fmt.Errorf("unexpected field: %T", f)
+			}
+			for _, l := range t.Label {
+				if !r.allowedLabel(l) {
+					r.use(l.Key, useDropped)
+					r.use(l.Str, useDropped)
+					r.use(l.NumUnit, useDropped)
+					continue
+				}
+				r.use(l.Key, useOther)
+				r.use(l.Str, useLabel)
+				r.use(l.NumUnit, useOther)
+			}
+			return nil
+		},
+		pproflite.SampleDecoder,
+	)
+}
+
+// use records that the string of index i is used as u.
+func (r *Redactor) use(i int64, u uint8) {
+	if i <= 0 {
+		// the zero-index empty string is never redacted
+		return
+	}
+	for int64(len(r.uses)) <= i {
+		r.uses = append(r.uses, 0)
+	}
+	r.uses[i] |= u
+}
+
+func (r *Redactor) pass2Write() error {
+	strIdx := 0
+	return r.decoder.FieldEach(
+		func(f pproflite.Field) error {
+			switch t := f.(type) {
+			case *pproflite.Sample:
+				if r.allowed != nil {
+					r.filterLabels(t)
+				}
+			case *pproflite.StringTable:
+				var u uint8
+				if strIdx < len(r.uses) {
+					u = r.uses[strIdx]
+				}
+				if u == useDropped {
+					// only used by dropped labels
+					t.Value = nil
+				} else if u&(useSymbol|useLabel) != 0 && r.match(t.Value) {
+					t.Value = r.redacted(t.Value)
+				}
+				strIdx++
+			}
+			return r.encoder.Encode(f)
+		},
+		pproflite.SampleTypeDecoder,
+		pproflite.SampleDecoder,
+		pproflite.MappingDecoder,
+		pproflite.LocationFastDecoder,
+		pproflite.FunctionDecoder,
+		pproflite.StringTableDecoder,
+		pproflite.DropFramesDecoder,
+		pproflite.KeepFramesDecoder,
+		pproflite.TimeNanosDecoder,
+		pproflite.DurationNanosDecoder,
+		pproflite.PeriodTypeDecoder,
+		pproflite.PeriodDecoder,
+		pproflite.CommentDecoder,
+		pproflite.DefaultSampleTypeDecoder,
+	)
+}
+
+// filterLabels removes the labels of s whose key isn't allowed.
+func (r *Redactor) filterLabels(s *pproflite.Sample) {
+	kept := s.Label[:0]
+	for _, l := range s.Label {
+		if r.allowedLabel(l) {
+			kept = append(kept, l)
+		}
+	}
+	s.Label = kept
+}
+
+// allowedLabel returns true if the key of l is allowed.
+func (r *Redactor) allowedLabel(l pproflite.Label) bool {
+	if r.allowed == nil {
+		return true
+	}
+	if l.Key < 0 || l.Key >= int64(len(r.strings)) {
+		return false
+	}
+	_, ok := r.allowed[string(r.strings[l.Key])]
+	return ok
+}
+
+func (r *Redactor) match(s []byte) bool {
+	for _, p := range r.cfg.Patterns {
+		if p.Match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted returns the redacted value of s. It is only valid until the next
+// call.
+func (r *Redactor) redacted(s []byte) []byte {
+	if !r.cfg.Hash {
+		r.buf = append(r.buf[:0], Redacted...)
+		return r.buf
+	}
+	var (
+		sum = sha256.Sum256(s)
+		h   [16]byte
+	)
+	hex.Encode(h[:], sum[:8])
+	r.buf = append(r.buf[:0], "<redacted:"...)
+	r.buf = append(r.buf, h[:]...)
+	r.buf = append(r.buf, '>')
+	return r.buf
+}
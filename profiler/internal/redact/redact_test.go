@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package redact
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pproflite"
+)
+
+func testProfile(t testing.TB) []byte {
+	secret := &profile.Function{ID: 1, Name: "github.com/acme/secret.Handle", SystemName: "github.com/acme/secret.Handle", Filename: "/src/acme/secret/handle.go"}
+	other := &profile.Function{ID: 2, Name: "github.com/acme/secret2.Serve", SystemName: "github.com/acme/secret2.Serve", Filename: "/src/acme/secret2/serve.go"}
+	main := &profile.Function{ID: 3, Name: "main.main", SystemName: "main.main", Filename: "/src/main.go"}
+	mapping := &profile.Mapping{ID: 1, File: "/opt/acme/secret-server", HasFunctions: true}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		Mapping:    []*profile.Mapping{mapping},
+		Function:   []*profile.Function{secret, other, main},
+		Location: []*profile.Location{
+			{ID: 1, Mapping: mapping, Address: 0x10, Line: []profile.Line{{Function: secret, Line: 12}}},
+			{ID: 2, Mapping: mapping, Address: 0x20, Line: []profile.Line{{Function: other, Line: 34}}},
+			{ID: 3, Mapping: mapping, Address: 0x30, Line: []profile.Line{{Function: main, Line: 56}}},
+		},
+	}
+	p.Sample = []*profile.Sample{
+		{
+			Location: []*profile.Location{p.Location[0], p.Location[2]},
+			Value:    []int64{1},
+			Label:    map[string][]string{"span id": {"123"}, "customer": {"initech"}},
+		},
+		{
+			Location: []*profile.Location{p.Location[1], p.Location[2]},
+			Value:    []int64{2},
+			NumLabel: map[string][]int64{"bytes": {64}},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.WriteUncompressed(&buf))
+	return buf.Bytes()
+}
+
+func redact(t *testing.T, cfg Config, data []byte) *profile.Profile {
+	prof, err := profile.ParseData(redactRaw(t, cfg, data))
+	require.NoError(t, err)
+	return prof
+}
+
+// redactRaw returns the encoded redacted profile.
+func redactRaw(t *testing.T, cfg Config, data []byte) []byte {
+	var out bytes.Buffer
+	require.NoError(t, New(cfg).Redact(data, &out))
+	return out.Bytes()
+}
+
+func TestRedact(t *testing.T) {
+	data := testProfile(t)
+
+	t.Run("symbols", func(t *testing.T) {
+		prof := redact(t, Config{Patterns: []*regexp.Regexp{regexp.MustCompile(`acme/secret`)}}, data)
+		for _, f := range prof.Function[:2] {
+			assert.Equal(t, Redacted, f.Name)
+			assert.Equal(t, Redacted, f.SystemName)
+			assert.Equal(t, Redacted, f.Filename)
+		}
+		assert.Equal(t, "main.main", prof.Function[2].Name)
+		assert.Equal(t, "/src/main.go", prof.Function[2].Filename)
+		assert.Equal(t, Redacted, prof.Mapping[0].File)
+		// the rest of the profile is left untouched
+		assert.Equal(t, int64(34), prof.Location[1].Line[0].Line)
+		assert.Equal(t, "initech", prof.Sample[0].Label["customer"][0])
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		prof := redact(t, Config{Patterns: []*regexp.Regexp{regexp.MustCompile(`^github.com/acme/`)}, Hash: true}, data)
+		assert.Regexp(t, `^<redacted:[0-9a-f]{16}>$`, prof.Function[0].Name)
+		assert.Regexp(t, `^<redacted:[0-9a-f]{16}>$`, prof.Function[1].Name)
+		assert.NotEqual(t, prof.Function[0].Name, prof.Function[1].Name)
+		assert.Equal(t, "/src/acme/secret/handle.go", prof.Function[0].Filename)
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		prof := redact(t, Config{AllowedLabels: []string{"span id"}}, data)
+		assert.Equal(t, map[string][]string{"span id": {"123"}}, prof.Sample[0].Label)
+		assert.Empty(t, prof.Sample[1].NumLabel)
+		assert.Equal(t, "github.com/acme/secret.Handle", prof.Function[0].Name)
+		assert.Equal(t, "samples", prof.SampleType[0].Type)
+
+		// the strings of the dropped labels are not uploaded either
+		raw := redactRaw(t, Config{AllowedLabels: []string{"span id"}}, data)
+		for _, dropped := range []string{"customer", "initech", "bytes"} {
+			assert.NotContains(t, string(raw), dropped)
+		}
+		assert.Contains(t, string(raw), "span id")
+	})
+
+	t.Run("label-values", func(t *testing.T) {
+		cfg := Config{Patterns: []*regexp.Regexp{regexp.MustCompile(`^initech$`)}, AllowedLabels: []string{"span id", "customer"}}
+		prof := redact(t, cfg, data)
+		assert.Equal(t, map[string][]string{"span id": {"123"}, "customer": {Redacted}}, prof.Sample[0].Label)
+		assert.Equal(t, "github.com/acme/secret.Handle", prof.Function[0].Name)
+		assert.NotContains(t, string(redactRaw(t, cfg, data)), "initech")
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "heap.pprof"))
+		require.NoError(t, err)
+		want, err := profile.ParseData(data)
+		require.NoError(t, err)
+		assert.Equal(t, want.String(), redact(t, Config{}, data).String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, New(Config{}).Redact([]byte("not a profile"), io.Discard))
+	})
+}
+
+func BenchmarkRedact(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("testdata", "heap.pprof"))
+	require.NoError(b, err)
+
+	// baseline is the cost of decoding and encoding the profile once
+	b.Run("baseline", func(b *testing.B) {
+		d := pproflite.NewDecoder(data)
+		e := pproflite.NewEncoder(io.Discard)
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if err := d.FieldEach(e.Encode); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, bc := range []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "passthrough"},
+		{name: "redact", cfg: Config{Patterns: []*regexp.Regexp{regexp.MustCompile(`^runtime\.`)}}},
+		{name: "hash", cfg: Config{Patterns: []*regexp.Regexp{regexp.MustCompile(`^runtime\.`)}, Hash: true}},
+		{name: "labels", cfg: Config{AllowedLabels: []string{"span id", "local root span id"}}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := New(bc.cfg)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := r.Redact(data, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+
+	pprofile "github.com/google/pprof/profile"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// Reasons for a stack to be a leak candidate, see leakReasonLabel.
+const (
+	leakReasonCount = "count"
+	leakReasonWait  = "wait"
+)
+
+// Labels added to the samples of the leak candidate profiles.
+const (
+	// leakReasonLabel tells why the stack is a leak candidate: "count" if its
+	// number of goroutines keeps growing, "wait" if their wait duration does.
+	leakReasonLabel = "leak reason"
+	// leakGrowthLabel is the number of snapshots in which the stack grew.
+	leakGrowthLabel = "leak growth"
+)
+
+// leakDetector compares the successive snapshots of the goroutine profile, or
+// of the goroutine wait profile, to find the stacks of leaking goroutines. A
+// stack is a leak candidate once its value grew in the given number of
+// snapshots without ever decreasing in between: the number of goroutines for
+// the goroutine profile, and the longest wait of its goroutines for the
+// goroutine wait profile. The goroutines of long-lived workers wait for as long
+// as they are idle, so a stack of the goroutine wait profile is only flagged if
+// its number of goroutines grew as well, and never decreased in between.
+type leakDetector struct {
+	snapshots int    // snapshots is the growth required to flag a stack
+	reason    string // reason is the value of the leakReasonLabel label
+
+	mu     sync.Mutex
+	stacks map[string]*stackGrowth
+}
+
+// stackGrowth tracks the growth of a stack across snapshots.
+type stackGrowth struct {
+	value  int64 // value is the value of the stack in the last snapshot
+	growth int   // growth is the number of snapshots in which value grew
+
+	count       int // count is the number of goroutines in the last snapshot
+	countGrowth int // countGrowth is the number of snapshots in which count grew
+}
+
+func newLeakDetector(snapshots int, reason string) *leakDetector {
+	return &leakDetector{
+		snapshots: snapshots,
+		reason:    reason,
+		stacks:    make(map[string]*stackGrowth),
+	}
+}
+
+// observe records the snapshot of the goroutine profile in data. It returns
+// the leak candidate profile, holding the samples of the flagged stacks, and
+// their number. It returns a nil profile if there is no leak candidate.
+func (d *leakDetector) observe(data []byte) (leaks []byte, n int, err error) {
+	prof, err := pprofile.ParseData(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make(map[string]int64)
+	counts := make(map[string]int)
+	keys := make([]string, len(prof.Sample))
+	for i, s := range prof.Sample {
+		keys[i] = stackKey(s)
+		counts[keys[i]]++
+		if len(s.Value) == 0 {
+			continue
+		}
+		if d.reason == leakReasonCount {
+			values[keys[i]] += s.Value[0]
+		} else if v := s.Value[0]; v > values[keys[i]] {
+			values[keys[i]] = v
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k := range d.stacks {
+		if _, ok := values[k]; !ok {
+			// the goroutines are gone
+			delete(d.stacks, k)
+		}
+	}
+	flagged := make(map[string]int)
+	for k, v := range values {
+		sg, ok := d.stacks[k]
+		if !ok {
+			d.stacks[k] = &stackGrowth{value: v, count: counts[k]}
+			continue
+		}
+		switch {
+		case v > sg.value:
+			sg.growth++
+		case v < sg.value:
+			sg.growth = 0
+		}
+		sg.value = v
+		switch c := counts[k]; {
+		case c > sg.count:
+			sg.countGrowth++
+		case c < sg.count:
+			sg.countGrowth = 0
+		}
+		sg.count = counts[k]
+		if d.reason == leakReasonWait && sg.countGrowth == 0 {
+			// the goroutines of the stack are idle, not leaking
+			continue
+		}
+		if sg.growth >= d.snapshots {
+			flagged[k] = sg.growth
+		}
+	}
+	if len(flagged) == 0 {
+		return nil, 0, nil
+	}
+
+	kept := prof.Sample[:0]
+	for i, s := range prof.Sample {
+		growth, ok := flagged[keys[i]]
+		if !ok {
+			continue
+		}
+		if s.Label == nil {
+			s.Label = make(map[string][]string)
+		}
+		if s.NumLabel == nil {
+			s.NumLabel = make(map[string][]int64)
+		}
+		s.Label[leakReasonLabel] = []string{d.reason}
+		s.NumLabel[leakGrowthLabel] = []int64{int64(growth)}
+		kept = append(kept, s)
+	}
+	prof.Sample = kept
+	var buf bytes.Buffer
+	if err := prof.Write(&buf); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(flagged), nil
+}
+
+// stackKey returns a string identifying the stack of s.
+func stackKey(s *pprofile.Sample) string {
+	var b strings.Builder
+	for _, loc := range s.Location {
+		for _, l := range loc.Line {
+			if l.Function != nil {
+				b.WriteString(l.Function.Name)
+			}
+			b.WriteByte(':')
+			b.WriteString(strconv.FormatInt(l.Line, 10))
+			b.WriteByte(';')
+		}
+		if len(loc.Line) == 0 {
+			b.WriteString(strconv.FormatUint(loc.Address, 16))
+			b.WriteByte(';')
+		}
+	}
+	return b.String()
+}
+
+// detectLeaks feeds the snapshot of the goroutine profile t in data to its
+// leak detector, if any, and returns the leak candidate profile, if any.
+func (p *profiler) detectLeaks(t profileType, data []byte) *profile {
+	d, ok := p.leaks[t.Type]
+	if !ok {
+		return nil
+	}
+	leaks, n, err := d.observe(data)
+	if err != nil {
+		log.Error("Failed to detect goroutine leaks in the %s profile: %v", t.Name, err)
+		p.cfg.statsd.Count("datadog.profiling.go.goroutine_leak_error", 1, append(p.cfg.tags.Slice(), t.tag()), 1)
+		return nil
+	}
+	if leaks == nil {
+		return nil
+	}
+	tags := append(p.cfg.tags.Slice(), t.tag(), "reason:"+d.reason)
+	p.cfg.statsd.Count("datadog.profiling.go.goroutine_leak_candidates", int64(n), tags, 1)
+	return &profile{name: "leak-" + t.Filename, pt: t.Type, data: leaks}
+}
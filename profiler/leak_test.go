@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakDetector(t *testing.T) {
+	t.Run("count", func(t *testing.T) {
+		d := newLeakDetector(2, leakReasonCount)
+		snapshots := []string{
+			"goroutine/count\nmain;leak 1\nmain;ok 5\n",
+			"goroutine/count\nmain;leak 2\nmain;ok 3\n",
+			"goroutine/count\nmain;leak 1\nmain;leak 1\nmain;ok 4\n",
+			"goroutine/count\nmain;leak 3\nmain;ok 3\nmain;new 10\n",
+		}
+		var (
+			leaks []byte
+			n     int
+			err   error
+		)
+		for i, s := range snapshots {
+			leaks, n, err = d.observe(textProfile{Text: s}.Protobuf())
+			require.NoError(t, err)
+			if i < len(snapshots)-1 {
+				assert.Nil(t, leaks, "snapshot %d", i)
+			}
+		}
+		assert.Equal(t, 1, n)
+		assert.Equal(t, "goroutine/count\nmain;leak 3\n", protobufToText(leaks))
+		prof, err := pprofile.ParseData(leaks)
+		require.NoError(t, err)
+		assert.Equal(t, []string{leakReasonCount}, prof.Sample[0].Label[leakReasonLabel])
+		assert.Equal(t, []int64{2}, prof.Sample[0].NumLabel[leakGrowthLabel])
+
+		// a decrease resets the growth
+		leaks, _, err = d.observe(textProfile{Text: "goroutine/count\nmain;leak 2\n"}.Protobuf())
+		require.NoError(t, err)
+		assert.Nil(t, leaks)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		d := newLeakDetector(2, leakReasonWait)
+		for _, s := range []string{
+			"waitduration/nanoseconds\nmain;stuck 60000000000\nmain;stuck 0\n",
+			"waitduration/nanoseconds\nmain;stuck 120000000000\n",
+		} {
+			leaks, _, err := d.observe(textProfile{Text: s}.Protobuf())
+			require.NoError(t, err)
+			assert.Nil(t, leaks)
+		}
+		leaks, n, err := d.observe(textProfile{Text: "waitduration/nanoseconds\nmain;stuck 180000000000\nmain;stuck 0\n"}.Protobuf())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		prof, err := pprofile.ParseData(leaks)
+		require.NoError(t, err)
+		// every goroutine of the stack is included
+		assert.Len(t, prof.Sample, 2)
+		assert.Equal(t, []string{leakReasonWait}, prof.Sample[0].Label[leakReasonLabel])
+	})
+
+	t.Run("wait-idle", func(t *testing.T) {
+		// a long-lived worker waits for as long as it is idle, but the number
+		// of goroutines of its stack stays flat
+		d := newLeakDetector(2, leakReasonWait)
+		for i := 1; i <= 5; i++ {
+			wait := strconv.Itoa(i * 60000000000)
+			leaks, _, err := d.observe(textProfile{Text: "waitduration/nanoseconds\nmain;worker " + wait + "\nmain;worker " + wait + "\n"}.Protobuf())
+			require.NoError(t, err)
+			assert.Nil(t, leaks, "snapshot %d", i)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := newLeakDetector(2, leakReasonCount).observe([]byte("not a profile"))
+		assert.Error(t, err)
+	})
+}
+
+func TestGoroutineLeakDetection(t *testing.T) {
+	stats := &countingStatsd{}
+	p, err := unstartedProfiler(
+		WithProfileTypes(GoroutineProfile),
+		WithPeriod(10*time.Millisecond),
+		WithStatsd(stats),
+		WithGoroutineLeakDetection(2),
+	)
+	require.NoError(t, err)
+	var (
+		mu    sync.Mutex
+		leaks = 1
+	)
+	p.testHooks.lookupProfile = func(_ string, w io.Writer, _ int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := w.Write(textProfile{Text: "goroutine/count\nmain;leak " + strconv.Itoa(leaks) + "\n"}.Protobuf())
+		leaks++
+		return err
+	}
+	batches := make(chan batch, 10)
+	p.uploadFunc = func(bat batch) error {
+		batches <- bat
+		return nil
+	}
+	p.run()
+	defer p.stop()
+
+	var names [][]string
+	for i := 0; i < 3; i++ {
+		var batchNames []string
+		for _, prof := range (<-batches).profiles {
+			batchNames = append(batchNames, prof.name)
+		}
+		names = append(names, batchNames)
+	}
+	assert.Equal(t, [][]string{
+		{"goroutines.pprof"},
+		{"goroutines.pprof"},
+		{"goroutines.pprof", "leak-goroutines.pprof"},
+	}, names)
+	require.NotEmpty(t, stats.events("datadog.profiling.go.goroutine_leak_candidates"))
+	assert.Subset(t, stats.events("datadog.profiling.go.goroutine_leak_candidates")[0], []string{
+		"profile_type:goroutine",
+		"reason:count",
+	})
+}
@@ -104,13 +104,21 @@ type config struct {
 	maxGoroutinesWait    int
 	mutexFraction        int
 	blockRate            int
-	outputDir            string
+	exporters            []Exporter
+	uploadEnabled        bool
+	remoteConfigEnabled  bool
 	deltaProfiles        bool
 	deltaMethod          string
 	logStartup           bool
 	traceEnabled         bool
 	traceConfig          executionTraceConfig
 	endpointCountEnabled bool
+	customProfiles       []customProfile
+	triggers             *CaptureTriggers
+	maxOverhead          float64
+	redaction            *RedactionConfig
+	leakSnapshots        int
+	cpuTimePerRequest    bool
 }
 
 // logStartup records the configuration to the configured logger in JSON format
@@ -141,7 +149,12 @@ func logStartup(c *config) {
 		TraceEnabled         bool     `json:"execution_trace_enabled"`
 		TracePeriod          string   `json:"execution_trace_period"`
 		TraceSizeLimit       int      `json:"execution_trace_size_limit"`
+		TraceSlowSpan        string   `json:"execution_trace_slow_span_threshold"`
 		EndpointCountEnabled bool     `json:"endpoint_count_enabled"`
+		MaxOverhead          float64  `json:"max_overhead"`
+		RedactionEnabled     bool     `json:"redaction_enabled"`
+		LeakSnapshots        int      `json:"goroutine_leak_snapshots"`
+		CPUTimePerRequest    bool     `json:"cpu_time_per_request_enabled"`
 	}{
 		Date:                 time.Now().Format(time.RFC3339),
 		OSName:               osinfo.OSName(),
@@ -167,11 +180,19 @@ func logStartup(c *config) {
 		TraceEnabled:         c.traceEnabled,
 		TracePeriod:          c.traceConfig.Period.String(),
 		TraceSizeLimit:       c.traceConfig.Limit,
+		TraceSlowSpan:        c.traceConfig.SlowSpanThreshold.String(),
 		EndpointCountEnabled: c.endpointCountEnabled,
+		MaxOverhead:          c.maxOverhead,
+		RedactionEnabled:     c.redaction != nil,
+		LeakSnapshots:        c.leakSnapshots,
+		CPUTimePerRequest:    c.cpuTimePerRequest,
 	}
 	for t := range c.types {
 		info.EnabledProfiles = append(info.EnabledProfiles, t.String())
 	}
+	for _, cp := range c.customProfiles {
+		info.EnabledProfiles = append(info.EnabledProfiles, cp.name)
+	}
 	b, err := json.Marshal(info)
 	if err != nil {
 		log.Error("Marshaling profiler configuration: %s", err)
@@ -221,7 +242,10 @@ func defaultConfig() (*config, error) {
 		deltaProfiles:        internal.BoolEnv("DD_PROFILING_DELTA", true),
 		deltaMethod:          os.Getenv("DD_PROFILING_DELTA_METHOD"),
 		logStartup:           internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true),
+		uploadEnabled:        true,
+		remoteConfigEnabled:  internal.BoolEnv("DD_PROFILING_REMOTE_CONFIGURATION_ENABLED", false),
 		endpointCountEnabled: internal.BoolEnv(traceprof.EndpointCountEnvVar, false),
+		cpuTimePerRequest:    internal.BoolEnv("DD_PROFILING_CPU_TIME_PER_REQUEST_ENABLED", false),
 	}
 	c.tags = c.tags.Append(fmt.Sprintf("process_id:%d", os.Getpid()))
 	for _, t := range defaultProfileTypes {
@@ -313,6 +337,7 @@ func defaultConfig() (*config, error) {
 	c.traceEnabled = internal.BoolEnv("DD_PROFILING_EXECUTION_TRACE_ENABLED", false)
 	c.traceConfig.Period = internal.DurationEnv("DD_PROFILING_EXECUTION_TRACE_PERIOD", 5000*time.Second)
 	c.traceConfig.Limit = internal.IntEnv("DD_PROFILING_EXECUTION_TRACE_LIMIT_BYTES", defaultExecutionTraceSizeLimit)
+	c.traceConfig.SlowSpanThreshold = internal.DurationEnv("DD_PROFILING_EXECUTION_TRACE_SLOW_SPAN_THRESHOLD", 0)
 	if c.traceEnabled && (c.traceConfig.Period == 0 || c.traceConfig.Limit == 0) {
 		log.Warn("Invalid execution trace config, enabled is true but size limit or frequency is 0. Disabling execution trace.")
 		c.traceEnabled = false
@@ -436,6 +461,124 @@ func WithProfileTypes(types ...ProfileType) Option {
 	}
 }
 
+// WithCustomProfile adds a user-defined profile, such as one created with
+// runtime/pprof.NewProfile, to the profiles collected at the end of each
+// profiling period. collect must return the profile in pprof format, gzipped or
+// not. The profile is uploaded as <name>.pprof, where name may only contain
+// letters, digits, '_' and '-' and must differ from the names of the other
+// profiles, or the profiler will fail to start.
+//
+// If deltaValues are given and delta profiles are enabled (see WithDeltaProfiles),
+// these sample values are reported as the difference with the previous profile,
+// like the allocation values of the heap profile, and the profile is uploaded as
+// delta-<name>.pprof. This is useful for values which only ever grow.
+func WithCustomProfile(name string, collect func() ([]byte, error), deltaValues ...ValueType) Option {
+	return func(cfg *config) {
+		cfg.customProfiles = append(cfg.customProfiles, customProfile{
+			name:        name,
+			collect:     collect,
+			deltaValues: deltaValues,
+		})
+	}
+}
+
+// WithCaptureTriggers enables capturing profiles on demand, see Capture, when
+// the CPU utilization, the heap growth or the number of goroutines of the
+// process exceed the thresholds given by t. The uploaded profiles are tagged
+// with "trigger:cpu", "trigger:heap" or "trigger:goroutines".
+func WithCaptureTriggers(t CaptureTriggers) Option {
+	return func(cfg *config) {
+		if t.CheckInterval <= 0 {
+			t.CheckInterval = defaultTriggerCheckInterval
+		}
+		if t.Cooldown <= 0 {
+			t.Cooldown = defaultTriggerCooldown
+		}
+		cfg.triggers = &t
+	}
+}
+
+// WithMaxOverhead limits the time spent collecting profiles and computing their
+// deltas to the given percentage of the profiling period. When a profiling
+// period exceeds it, the most expensive profile type is made cheaper: the CPU
+// profile rate is lowered, the mutex profile fraction is raised, the goroutine
+// wait profile limit is lowered, or the profile type is skipped for the next
+// period. Each action is reported with the
+// datadog.profiling.go.overhead_action metric. A percentage <= 0 disables the
+// overhead control, which is the default.
+//
+// The cost of the CPU profile is an approximation: only the time spent
+// building the profile when it stops is measured, as the cost of sampling the
+// CPU is spread over the whole profile duration, in signal handlers, and can't
+// be told apart from the CPU time of the application.
+func WithMaxOverhead(percent float64) Option {
+	return func(cfg *config) {
+		cfg.maxOverhead = percent
+	}
+}
+
+// WithRedaction rewrites the pprof profiles before they are exported and
+// uploaded, to redact the function names, file names and mapping paths matching
+// the patterns of r, and to drop the pprof labels it doesn't allow. Execution
+// traces can't be redacted, so they are disabled.
+func WithRedaction(r RedactionConfig) Option {
+	return func(cfg *config) {
+		cfg.redaction = &r
+	}
+}
+
+// WithGoroutineLeakDetection compares the successive goroutine profiles to
+// detect leaking goroutines. A stack is flagged once its number of goroutines
+// grew in the given number of profiles without ever decreasing in between. If
+// the experimental goroutine wait profile is enabled, a stack is also flagged
+// once the longest wait of its goroutines grew that way. The samples of the
+// flagged stacks are uploaded in a leak candidate profile along with the
+// regular profile, e.g. leak-goroutines.pprof, and counted with the
+// datadog.profiling.go.goroutine_leak_candidates metric. The goroutine profile
+// must be enabled, see WithProfileTypes. A number <= 0 disables the detection,
+// which is the default.
+func WithGoroutineLeakDetection(snapshots int) Option {
+	return func(cfg *config) {
+		cfg.leakSnapshots = snapshots
+	}
+}
+
+// WithCPUTimePerRequest enables reporting the average CPU time of the requests
+// to each endpoint, computed from the CPU profile and the "trace endpoint" pprof
+// label applied by the tracer, see tracer.WithProfilerEndpoints. It is reported
+// with the datadog.profiling.go.cpu_time_per_request metric, tagged with the
+// endpoint, and added to the local root spans of the endpoint as the
+// _dd.profiling.cpu_time_per_request metric. Enabling it also enables counting
+// the requests to each endpoint, but the counts are only uploaded along with
+// the profiles when endpoint counting is enabled as well, see the
+// DD_PROFILING_ENDPOINT_COUNT_ENABLED environment variable. It can also be
+// enabled with the
+// DD_PROFILING_CPU_TIME_PER_REQUEST_ENABLED environment variable.
+func WithCPUTimePerRequest(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.cpuTimePerRequest = enabled
+	}
+}
+
+// WithSlowSpanExecutionTraces enables recording the execution trace with a
+// flight recorder, and uploading its last moments when a span lasts longer
+// than threshold, at most once per profiling period. It enables the execution
+// traces, in place of the ones collected at a regular interval, and requires Go
+// 1.25 or later. A threshold <= 0 disables the flight recorder, which is the
+// default. It can also be set with the
+// DD_PROFILING_EXECUTION_TRACE_SLOW_SPAN_THRESHOLD environment variable, along
+// with DD_PROFILING_EXECUTION_TRACE_ENABLED.
+func WithSlowSpanExecutionTraces(threshold time.Duration) Option {
+	return func(cfg *config) {
+		if threshold <= 0 {
+			cfg.traceConfig.SlowSpanThreshold = 0
+			return
+		}
+		cfg.traceEnabled = true
+		cfg.traceConfig.SlowSpanThreshold = threshold
+	}
+}
+
 // WithService specifies the service name to attach to a profile.
 func WithService(name string) Option {
 	return func(cfg *config) {
@@ -518,8 +661,28 @@ func WithUDS(socketPath string) Option {
 // directory. This is intended for local development or debugging uploading
 // issues. The directory will keep growing, no cleanup is performed.
 func withOutputDir(dir string) Option {
+	return WithExporter(NewDirExporter(dir))
+}
+
+// WithExporter adds an exporter receiving every batch of profiles, e.g. one
+// returned by NewDirExporter, NewTarExporter or NewZipExporter. It can be used
+// several times to add several exporters. Exporters implementing io.Closer are
+// closed when the profiler is stopped.
+//
+// The profiles are still uploaded to Datadog, unless WithUpload(false) is used.
+func WithExporter(e Exporter) Option {
+	return func(cfg *config) {
+		cfg.exporters = append(cfg.exporters, e)
+	}
+}
+
+// WithUpload toggles uploading the profiles to the Datadog agent, or to the
+// Datadog intake with WithAgentlessUpload. Disabling it is useful when the
+// profiles are only exported, see WithExporter. This option is enabled by
+// default.
+func WithUpload(enabled bool) Option {
 	return func(cfg *config) {
-		cfg.outputDir = dir
+		cfg.uploadEnabled = enabled
 	}
 }
 
@@ -555,4 +718,9 @@ type executionTraceConfig struct {
 	// of events recorded) than duration, so we use that to decide when to
 	// stop tracing.
 	Limit int
+	// SlowSpanThreshold enables the flight recorder mode when > 0: instead of
+	// collecting a trace every Period, the profiler keeps recording the last
+	// moments of the execution trace, and uploads them when a span lasts
+	// longer than SlowSpanThreshold. It requires Go 1.25 or later.
+	SlowSpanThreshold time.Duration
 }
@@ -247,6 +247,15 @@ func TestOptions(t *testing.T) {
 		WithHostname("example")(&cfg)
 		assert.Equal(t, "example", cfg.hostname)
 	})
+
+	t.Run("WithSlowSpanExecutionTraces", func(t *testing.T) {
+		var cfg config
+		WithSlowSpanExecutionTraces(100 * time.Millisecond)(&cfg)
+		assert.True(t, cfg.traceEnabled)
+		assert.Equal(t, 100*time.Millisecond, cfg.traceConfig.SlowSpanThreshold)
+		WithSlowSpanExecutionTraces(0)(&cfg)
+		assert.Zero(t, cfg.traceConfig.SlowSpanThreshold)
+	})
 }
 
 func TestEnvVars(t *testing.T) {
@@ -271,6 +280,15 @@ func TestEnvVars(t *testing.T) {
 		assert.Equal(t, 3*time.Second, cfg.uploadTimeout)
 	})
 
+	t.Run("DD_PROFILING_EXECUTION_TRACE_SLOW_SPAN_THRESHOLD", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_ENABLED", "true")
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_SLOW_SPAN_THRESHOLD", "100ms")
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.True(t, cfg.traceEnabled)
+		assert.Equal(t, 100*time.Millisecond, cfg.traceConfig.SlowSpanThreshold)
+	})
+
 	t.Run("DD_AGENT_HOST+DD_TRACE_AGENT_PORT", func(t *testing.T) {
 		t.Setenv("DD_AGENT_HOST", "agent_host_1")
 		t.Setenv("DD_TRACE_AGENT_PORT", "6218")
@@ -411,7 +429,7 @@ func TestWith_outputDir(t *testing.T) {
 			{name: "bar.pprof", data: []byte("bar")},
 		},
 	}
-	require.NoError(t, p.outputDir(bat))
+	require.NoError(t, p.export(bat))
 	files, err := filepath.Glob(filepath.Join(tmpDir, "*", "*.pprof"))
 	require.NoError(t, err)
 
@@ -0,0 +1,283 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// defaultCPUProfileRate is the rate set by runtime/pprof.StartCPUProfile.
+	defaultCPUProfileRate = 100
+	// minCPUProfileRate is the lowest CPU profile rate set by the overhead
+	// control. Lower rates make CPU profiles too imprecise to be useful.
+	minCPUProfileRate = 10
+	// maxMutexFraction is the highest mutex profile fraction set by the
+	// overhead control.
+	maxMutexFraction = 1 << 16
+)
+
+// Actions taken by the overhead control, reported in the action tag of the
+// datadog.profiling.go.overhead_action metric.
+const (
+	actionLowerCPUProfileRate    = "lower_cpu_profile_rate"
+	actionRaiseMutexFraction     = "raise_mutex_profile_fraction"
+	actionLowerMaxGoroutinesWait = "lower_max_goroutines_wait"
+	actionSkip                   = "skip"
+	// The restore actions undo the above actions, one step at a time.
+	actionRaiseCPUProfileRate    = "raise_cpu_profile_rate"
+	actionLowerMutexFraction     = "lower_mutex_profile_fraction"
+	actionRaiseMaxGoroutinesWait = "raise_max_goroutines_wait"
+)
+
+// overheadControl keeps the cost of the profiler within the budget given to
+// WithMaxOverhead. The profiler records the time spent collecting each profile
+// type, and computing its delta, during a collection cycle. When a cycle costs
+// more than the budget, the most expensive profile type is made cheaper for the
+// following cycles: the CPU profile rate and the goroutine wait profile limit
+// are lowered, the mutex profile fraction is raised, and the other profile
+// types are skipped for one cycle. The delta profile following a skipped cycle
+// covers the skipped period as well. When a cycle costs less than half of the
+// budget, one of these settings is brought one step back towards its
+// configured value, so that the profiles regain their precision once the
+// overhead drops. The cost of the CPU profile is approximated by the time
+// spent stopping it, see WithMaxOverhead.
+//
+// A nil *overheadControl doesn't control anything.
+type overheadControl struct {
+	budget float64 // budget is the maximum cost of a cycle, as a fraction of the period
+
+	mu                sync.Mutex
+	costs             map[ProfileType]time.Duration // costs of the ongoing cycle
+	skipped           map[ProfileType]bool          // types skipped during the ongoing cycle
+	cpuProfileRate    int
+	mutexFraction     int
+	maxGoroutinesWait int
+
+	// configured holds the configured settings, restored once the overhead
+	// drops back under the budget
+	configured struct {
+		cpuProfileRate    int
+		mutexFraction     int
+		maxGoroutinesWait int
+	}
+}
+
+// newOverheadControl returns the overhead control configured by cfg, or nil if
+// WithMaxOverhead wasn't given.
+func newOverheadControl(cfg *config) *overheadControl {
+	if cfg.maxOverhead <= 0 {
+		return nil
+	}
+	o := &overheadControl{
+		budget:            cfg.maxOverhead / 100,
+		costs:             make(map[ProfileType]time.Duration),
+		cpuProfileRate:    cfg.cpuProfileRate,
+		mutexFraction:     cfg.mutexFraction,
+		maxGoroutinesWait: cfg.maxGoroutinesWait,
+	}
+	o.configured.cpuProfileRate = cfg.cpuProfileRate
+	o.configured.mutexFraction = cfg.mutexFraction
+	o.configured.maxGoroutinesWait = cfg.maxGoroutinesWait
+	return o
+}
+
+// record adds d to the cost of pt during the ongoing cycle.
+func (o *overheadControl) record(pt ProfileType, d time.Duration) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.costs[pt] += d
+}
+
+// filter returns the profile types of types which aren't skipped during the
+// ongoing cycle.
+func (o *overheadControl) filter(types []ProfileType) []ProfileType {
+	if o == nil {
+		return types
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.skipped) == 0 {
+		return types
+	}
+	kept := make([]ProfileType, 0, len(types))
+	for _, t := range types {
+		if !o.skipped[t] {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// adjust ends the ongoing cycle, which lasted period. If its cost exceeds the
+// budget, it makes the most expensive of the given profile types cheaper, and
+// returns it along with the action taken. If its cost is less than half of the
+// budget, it restores the setting of a profile type made cheaper before, see
+// restore. It returns an empty action if nothing was done.
+func (o *overheadControl) adjust(period time.Duration, types []ProfileType) (ProfileType, string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	costs := o.costs
+	o.costs = make(map[ProfileType]time.Duration)
+	o.skipped = nil
+
+	var total time.Duration
+	for _, d := range costs {
+		total += d
+	}
+	if period <= 0 {
+		return 0, ""
+	}
+	if float64(total) <= o.budget*float64(period)/2 {
+		// Restoring a setting at most doubles the cost of its profile type,
+		// which stays within the budget
+		return o.restore()
+	}
+	if float64(total) <= o.budget*float64(period) {
+		return 0, ""
+	}
+	// types is in a deterministic order, keep it for equal costs
+	candidates := make([]ProfileType, len(types))
+	copy(candidates, types)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return costs[candidates[i]] > costs[candidates[j]]
+	})
+	for _, t := range candidates {
+		if costs[t] == 0 {
+			break
+		}
+		switch t {
+		case CPUProfile:
+			rate := o.cpuProfileRate
+			if rate == 0 {
+				rate = defaultCPUProfileRate
+			}
+			if rate <= minCPUProfileRate {
+				continue
+			}
+			if rate /= 2; rate < minCPUProfileRate {
+				rate = minCPUProfileRate
+			}
+			o.cpuProfileRate = rate
+			return t, actionLowerCPUProfileRate
+		case MutexProfile:
+			if o.mutexFraction <= 0 || o.mutexFraction >= maxMutexFraction {
+				continue
+			}
+			o.mutexFraction *= 2
+			return t, actionRaiseMutexFraction
+		case expGoroutineWaitProfile:
+			// the cost of the profile grows with the number of goroutines,
+			// which must go below the limit for it to be collected again
+			n := o.maxGoroutinesWait
+			if g := runtime.NumGoroutine(); g < n {
+				n = g
+			}
+			if n /= 2; n >= o.maxGoroutinesWait {
+				continue
+			}
+			o.maxGoroutinesWait = n
+			return t, actionLowerMaxGoroutinesWait
+		case MetricsProfile, executionTrace:
+			continue
+		default:
+			o.skipped = map[ProfileType]bool{t: true}
+			return t, actionSkip
+		}
+	}
+	return 0, ""
+}
+
+// restore brings one of the settings changed by adjust one step back towards
+// its configured value, and returns the profile type of the setting along with
+// the action taken. It returns an empty action if the settings are the
+// configured ones. Must be called with o.mu locked.
+func (o *overheadControl) restore() (ProfileType, string) {
+	if o.cpuProfileRate != o.configured.cpuProfileRate {
+		max := o.configured.cpuProfileRate
+		if max == 0 {
+			max = defaultCPUProfileRate
+		}
+		if o.cpuProfileRate *= 2; o.cpuProfileRate >= max {
+			o.cpuProfileRate = o.configured.cpuProfileRate
+		}
+		return CPUProfile, actionRaiseCPUProfileRate
+	}
+	if o.mutexFraction != o.configured.mutexFraction {
+		if o.mutexFraction /= 2; o.mutexFraction <= o.configured.mutexFraction {
+			o.mutexFraction = o.configured.mutexFraction
+		}
+		return MutexProfile, actionLowerMutexFraction
+	}
+	if o.maxGoroutinesWait != o.configured.maxGoroutinesWait {
+		n := o.maxGoroutinesWait * 2
+		if n == 0 {
+			n = 1
+		}
+		if n >= o.configured.maxGoroutinesWait {
+			n = o.configured.maxGoroutinesWait
+		}
+		o.maxGoroutinesWait = n
+		return expGoroutineWaitProfile, actionRaiseMaxGoroutinesWait
+	}
+	return 0, ""
+}
+
+// cpuProfileRate returns the CPU profile rate to use, see CPUProfileRate.
+func (p *profiler) cpuProfileRate() int {
+	if p.overhead == nil {
+		return p.cfg.cpuProfileRate
+	}
+	p.overhead.mu.Lock()
+	defer p.overhead.mu.Unlock()
+	return p.overhead.cpuProfileRate
+}
+
+// maxGoroutinesWait returns the maximum number of goroutines for which the
+// goroutine wait profile is collected.
+func (p *profiler) maxGoroutinesWait() int {
+	if p.overhead == nil {
+		return p.cfg.maxGoroutinesWait
+	}
+	p.overhead.mu.Lock()
+	defer p.overhead.mu.Unlock()
+	return p.overhead.maxGoroutinesWait
+}
+
+// controlOverhead ends the collection cycle of the given profile types, and
+// applies and reports the action taken by the overhead control, if any.
+func (p *profiler) controlOverhead(types []ProfileType) {
+	if p.overhead == nil {
+		return
+	}
+	pt, action := p.overhead.adjust(p.cfg.period, types)
+	if action == "" {
+		return
+	}
+	t := p.lookup(pt)
+	switch action {
+	case actionRaiseMutexFraction, actionLowerMutexFraction:
+		p.overhead.mu.Lock()
+		runtime.SetMutexProfileFraction(p.overhead.mutexFraction)
+		p.overhead.mu.Unlock()
+	}
+	switch action {
+	case actionRaiseCPUProfileRate, actionLowerMutexFraction, actionRaiseMaxGoroutinesWait:
+		log.Debug("Profiler overhead is below %v%%, action %s taken on the %s profile.", p.cfg.maxOverhead/2, action, t.Name)
+	default:
+		log.Debug("Profiler overhead exceeds %v%%, action %s taken on the %s profile.", p.cfg.maxOverhead, action, t.Name)
+	}
+	tags := append(p.cfg.tags.Slice(), t.tag(), "action:"+action)
+	p.cfg.statsd.Count("datadog.profiling.go.overhead_action", 1, tags, 1)
+}
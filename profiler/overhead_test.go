@@ -0,0 +1,211 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStatsd is a StatsdClient recording the tags of the counted events.
+type countingStatsd struct {
+	mu     sync.Mutex
+	counts map[string][][]string
+}
+
+func (s *countingStatsd) Count(event string, _ int64, tags []string, _ float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[string][][]string)
+	}
+	s.counts[event] = append(s.counts[event], tags)
+	return nil
+}
+
+func (s *countingStatsd) Timing(string, time.Duration, []string, float64) error { return nil }
+
+func (s *countingStatsd) events(event string) [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[event]
+}
+
+func TestOverheadControlAdjust(t *testing.T) {
+	newControl := func() *overheadControl {
+		return newOverheadControl(&config{
+			maxOverhead:       1,
+			mutexFraction:     10,
+			maxGoroutinesWait: 1000,
+		})
+	}
+	types := []ProfileType{CPUProfile, HeapProfile, MutexProfile, expGoroutineWaitProfile}
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, newOverheadControl(&config{}))
+		var o *overheadControl
+		o.record(HeapProfile, time.Second)
+		assert.Equal(t, types, o.filter(types))
+	})
+
+	t.Run("within-budget", func(t *testing.T) {
+		o := newControl()
+		o.record(HeapProfile, 5*time.Millisecond)
+		o.record(CPUProfile, 4*time.Millisecond)
+		_, action := o.adjust(time.Second, types)
+		assert.Empty(t, action)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		o := newControl()
+		o.record(HeapProfile, 20*time.Millisecond)
+		o.record(CPUProfile, 5*time.Millisecond)
+		pt, action := o.adjust(time.Second, types)
+		assert.Equal(t, HeapProfile, pt)
+		assert.Equal(t, actionSkip, action)
+		assert.Equal(t, []ProfileType{CPUProfile, MutexProfile, expGoroutineWaitProfile}, o.filter(types))
+
+		// the profile type is only skipped for one cycle
+		_, action = o.adjust(time.Second, o.filter(types))
+		assert.Empty(t, action)
+		assert.Equal(t, types, o.filter(types))
+	})
+
+	t.Run("cpu", func(t *testing.T) {
+		o := newControl()
+		for _, want := range []int{50, 25, 12, 10} {
+			o.record(CPUProfile, 20*time.Millisecond)
+			pt, action := o.adjust(time.Second, types)
+			assert.Equal(t, CPUProfile, pt)
+			assert.Equal(t, actionLowerCPUProfileRate, action)
+			assert.Equal(t, want, o.cpuProfileRate)
+		}
+		// the rate can't go any lower, the next most expensive type is used
+		o.record(CPUProfile, 20*time.Millisecond)
+		o.record(HeapProfile, 10*time.Millisecond)
+		pt, action := o.adjust(time.Second, types)
+		assert.Equal(t, HeapProfile, pt)
+		assert.Equal(t, actionSkip, action)
+	})
+
+	t.Run("mutex", func(t *testing.T) {
+		o := newControl()
+		o.record(MutexProfile, 20*time.Millisecond)
+		pt, action := o.adjust(time.Second, types)
+		assert.Equal(t, MutexProfile, pt)
+		assert.Equal(t, actionRaiseMutexFraction, action)
+		assert.Equal(t, 20, o.mutexFraction)
+	})
+
+	t.Run("goroutinewait", func(t *testing.T) {
+		o := newControl()
+		o.record(expGoroutineWaitProfile, 20*time.Millisecond)
+		pt, action := o.adjust(time.Second, types)
+		assert.Equal(t, expGoroutineWaitProfile, pt)
+		assert.Equal(t, actionLowerMaxGoroutinesWait, action)
+		assert.Less(t, o.maxGoroutinesWait, 1000)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		o := newControl()
+		for _, pt := range []ProfileType{CPUProfile, MutexProfile, expGoroutineWaitProfile} {
+			o.record(pt, 20*time.Millisecond)
+			_, action := o.adjust(time.Second, types)
+			require.NotEqual(t, actionSkip, action)
+		}
+		require.Equal(t, 50, o.cpuProfileRate)
+		require.Equal(t, 20, o.mutexFraction)
+		require.Less(t, o.maxGoroutinesWait, 1000)
+
+		// nothing is restored while the cost exceeds half of the budget
+		o.record(HeapProfile, 6*time.Millisecond)
+		_, action := o.adjust(time.Second, types)
+		assert.Empty(t, action)
+
+		// the settings are restored one at a time
+		o.record(HeapProfile, 4*time.Millisecond)
+		pt, action := o.adjust(time.Second, types)
+		assert.Equal(t, CPUProfile, pt)
+		assert.Equal(t, actionRaiseCPUProfileRate, action)
+		assert.Equal(t, 0, o.cpuProfileRate)
+		pt, action = o.adjust(time.Second, types)
+		assert.Equal(t, MutexProfile, pt)
+		assert.Equal(t, actionLowerMutexFraction, action)
+		assert.Equal(t, 10, o.mutexFraction)
+		// and one step at a time
+		for o.maxGoroutinesWait < 1000 {
+			wait := o.maxGoroutinesWait
+			pt, action = o.adjust(time.Second, types)
+			assert.Equal(t, expGoroutineWaitProfile, pt)
+			assert.Equal(t, actionRaiseMaxGoroutinesWait, action)
+			require.Greater(t, o.maxGoroutinesWait, wait)
+		}
+		assert.Equal(t, 1000, o.maxGoroutinesWait)
+		_, action = o.adjust(time.Second, types)
+		assert.Empty(t, action)
+	})
+
+	t.Run("restore-cpu", func(t *testing.T) {
+		o := newControl()
+		for i := 0; i < 3; i++ {
+			o.record(CPUProfile, 20*time.Millisecond)
+			o.adjust(time.Second, types)
+		}
+		require.Equal(t, 12, o.cpuProfileRate)
+		for _, want := range []int{24, 48, 96, 0} {
+			_, action := o.adjust(time.Second, types)
+			assert.Equal(t, actionRaiseCPUProfileRate, action)
+			assert.Equal(t, want, o.cpuProfileRate)
+		}
+	})
+}
+
+func TestMaxOverhead(t *testing.T) {
+	stats := &countingStatsd{}
+	var (
+		mu        sync.Mutex
+		collected int
+	)
+	p, err := unstartedProfiler(
+		WithProfileTypes(),
+		WithPeriod(10*time.Millisecond),
+		WithStatsd(stats),
+		WithMaxOverhead(10),
+		WithCustomProfile("slow", func() ([]byte, error) {
+			mu.Lock()
+			collected++
+			mu.Unlock()
+			// costs 50% of the period
+			time.Sleep(5 * time.Millisecond)
+			return textProfile{Text: "files/count\nmain 1\n"}.Protobuf(), nil
+		}),
+	)
+	require.NoError(t, err)
+	batches := make(chan batch, 10)
+	p.uploadFunc = func(bat batch) error {
+		batches <- bat
+		return nil
+	}
+	p.run()
+	defer p.stop()
+
+	// the slow profile is collected every other period
+	var sizes []int
+	for len(sizes) < 4 {
+		sizes = append(sizes, len((<-batches).profiles))
+	}
+	assert.Contains(t, sizes, 0)
+	assert.Contains(t, sizes, 1)
+	require.NotEmpty(t, stats.events("datadog.profiling.go.overhead_action"))
+	assert.Subset(t, stats.events("datadog.profiling.go.overhead_action")[0], []string{
+		"profile_type:slow",
+		"action:skip",
+	})
+}
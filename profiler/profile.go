@@ -93,24 +93,42 @@ var profileTypes = map[ProfileType]profileType{
 			// period so that we're sure to capture the CPU usage of
 			// this library, which mostly happens at the end
 			p.interruptibleSleep(p.cfg.period - p.cfg.cpuDuration)
-			if p.cfg.cpuProfileRate != 0 {
+			// An on-demand capture may be running, see Capture. Only one CPU
+			// profile can be recorded at a time.
+			p.cpuMu.Lock()
+			defer p.cpuMu.Unlock()
+			if rate := p.cpuProfileRate(); rate != 0 {
 				// The profile has to be set each time before
 				// profiling is started. Otherwise,
 				// runtime/pprof.StartCPUProfile will set the
 				// rate itself.
-				runtime.SetCPUProfileRate(p.cfg.cpuProfileRate)
+				runtime.SetCPUProfileRate(rate)
 			}
 
 			if err := p.startCPUProfile(&buf); err != nil {
 				return nil, err
 			}
-			p.interruptibleSleep(p.cfg.cpuDuration)
+			select {
+			case <-p.exit:
+			case <-time.After(p.cfg.cpuDuration):
+			case <-p.cpuPreempt:
+				// A capture has started, cut the profile short.
+				p.stopCPUProfile()
+				return buf.Bytes(), nil
+			}
 
 			// We want the CPU profiler to finish last so that it can
 			// properly record all of our profile processing work for
 			// the other profile types
 			p.pendingProfiles.Wait()
+			// Stopping the CPU profiler builds the profile, which is
+			// the part of its cost proportional to the profile rate.
+			// The cost of sampling, paid in signal handlers during the
+			// whole profile, is not measured: the recorded cost is an
+			// approximation, see WithMaxOverhead.
+			sw := internal.NewStopwatch()
 			p.stopCPUProfile()
+			p.overhead.record(CPUProfile, sw.Tick())
 			return buf.Bytes(), nil
 		},
 	},
@@ -155,8 +173,8 @@ var profileTypes = map[ProfileType]profileType{
 		Name:     "goroutinewait",
 		Filename: "goroutineswait.pprof",
 		Collect: func(p *profiler) ([]byte, error) {
-			if n := runtime.NumGoroutine(); n > p.cfg.maxGoroutinesWait {
-				return nil, fmt.Errorf("skipping goroutines wait profile: %d goroutines exceeds DD_PROFILING_WAIT_PROFILE_MAX_GOROUTINES limit of %d", n, p.cfg.maxGoroutinesWait)
+			if n, limit := runtime.NumGoroutine(), p.maxGoroutinesWait(); n > limit {
+				return nil, fmt.Errorf("skipping goroutines wait profile: %d goroutines exceeds DD_PROFILING_WAIT_PROFILE_MAX_GOROUTINES limit of %d", n, limit)
 			}
 
 			p.interruptibleSleep(p.cfg.period)
@@ -165,7 +183,9 @@ var profileTypes = map[ProfileType]profileType{
 				now   = now()
 				text  = &bytes.Buffer{}
 				pprof = &bytes.Buffer{}
+				sw    = internal.NewStopwatch()
 			)
+			defer func() { p.overhead.record(expGoroutineWaitProfile, sw.Tick()) }()
 			if err := p.lookupProfile("goroutine", text, 2); err != nil {
 				return nil, err
 			}
@@ -251,22 +271,33 @@ func collectGenericProfile(name string, pt ProfileType) func(p *profiler) ([]byt
 		p.interruptibleSleep(p.cfg.period)
 
 		var buf bytes.Buffer
+		sw := internal.NewStopwatch()
+		defer func() { p.overhead.record(pt, sw.Tick()) }()
 		err := p.lookupProfile(name, &buf, 0)
-		data := buf.Bytes()
-		dp, ok := p.deltas[pt]
-		if !ok || !p.cfg.deltaProfiles {
-			return data, err
-		}
-
-		start := time.Now()
-		delta, err := dp.Delta(data)
-		tags := append(p.cfg.tags.Slice(), fmt.Sprintf("profile_type:%s", name))
-		p.cfg.statsd.Timing("datadog.profiling.go.delta_time", time.Since(start), tags, 1)
 		if err != nil {
-			return nil, fmt.Errorf("delta profile error: %s", err)
+			return buf.Bytes(), err
 		}
-		return delta, err
+		return p.deltaProfile(name, pt, buf.Bytes())
+	}
+}
+
+// deltaProfile returns the delta between data and the previous profile of type
+// pt, if delta profiling is enabled and supported by pt. Otherwise it returns
+// data unchanged.
+func (p *profiler) deltaProfile(name string, pt ProfileType, data []byte) ([]byte, error) {
+	dp, ok := p.deltas[pt]
+	if !ok || !p.cfg.deltaProfiles {
+		return data, nil
+	}
+
+	start := time.Now()
+	delta, err := dp.Delta(data)
+	tags := append(p.cfg.tags.Slice(), fmt.Sprintf("profile_type:%s", name))
+	p.cfg.statsd.Timing("datadog.profiling.go.delta_time", time.Since(start), tags, 1)
+	if err != nil {
+		return nil, fmt.Errorf("delta profile error: %s", err)
 	}
+	return delta, err
 }
 
 // lookup returns t's profileType implementation.
@@ -286,6 +317,11 @@ func (t ProfileType) lookup() profileType {
 	}
 }
 
+// tag returns the profile_type tag of t.
+func (t profileType) tag() string {
+	return fmt.Sprintf("profile_type:%s", t.Name)
+}
+
 // String returns the name of the profile.
 func (t ProfileType) String() string {
 	return t.lookup().Name
@@ -312,7 +348,9 @@ type profile struct {
 // batch is a collection of profiles of different types, collected at roughly the same time. It maps
 // to what the Datadog UI calls a profile.
 type batch struct {
-	seq            uint64 // seq is the value of the profile_seq tag
+	seq            uint64   // seq is the value of the profile_seq tag
+	trigger        string   // trigger is the reason of an on-demand capture, see Capture
+	tags           []string // tags are additional tags of the batch
 	start, end     time.Time
 	host           string
 	profiles       []*profile
@@ -325,20 +363,24 @@ func (b *batch) addProfile(p *profile) {
 
 func (p *profiler) runProfile(pt ProfileType) ([]*profile, error) {
 	start := now()
-	t := pt.lookup()
+	t := p.lookup(pt)
 	data, err := t.Collect(p)
 	if err != nil {
 		return nil, err
 	}
 	end := now()
-	tags := append(p.cfg.tags.Slice(), pt.Tag())
+	tags := append(p.cfg.tags.Slice(), t.tag())
 	filename := t.Filename
 	// TODO(fg): Consider making Collect() return the filename.
 	if p.cfg.deltaProfiles && len(t.DeltaValues) > 0 {
 		filename = "delta-" + filename
 	}
 	p.cfg.statsd.Timing("datadog.profiling.go.collect_time", end.Sub(start), tags, 1)
-	return []*profile{{name: filename, pt: pt, data: data}}, nil
+	profs := []*profile{{name: filename, pt: pt, data: data}}
+	if leaks := p.detectLeaks(t, data); leaks != nil {
+		profs = append(profs, leaks)
+	}
+	return profs, nil
 }
 
 type deltaProfiler interface {
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"sync"
@@ -35,12 +34,28 @@ var (
 //
 // It may return an error if an API key is not provided by means of the
 // WithAPIKey option, or if a hostname is not found.
+//
+// When the DD_PROFILING_REMOTE_CONFIGURATION_ENABLED env variable is true and
+// uploads are not agentless, the profiler can be turned on and off, and its
+// profile types, profiling period, CPU duration, mutex profile fraction and
+// block profile rate changed, at runtime through the APM_TRACING remote
+// configuration received by the tracer. The tracer must be started with remote
+// configuration enabled, see the DD_REMOTE_CONFIGURATION_ENABLED env variable.
+// The changes apply from the next profiling period on, without restarting the
+// profiler. This is experimental: the lib_config keys read by the profiler
+// (profiling_enabled, profile_types, mutex_profile_fraction,
+// block_profile_rate, profile_period_seconds and cpu_duration_seconds) are
+// not part of the APM_TRACING schema yet, and may change.
 func Start(opts ...Option) error {
 	mu.Lock()
 	defer mu.Unlock()
 
 	if activeProfiler != nil {
 		activeProfiler.stop()
+		activeProfiler = nil
+	}
+	if p := stopRemoteConfig(); p != nil {
+		p.closeExporters()
 	}
 	p, err := newProfiler(opts...)
 	if err != nil {
@@ -48,6 +63,7 @@ func Start(opts ...Option) error {
 	}
 	activeProfiler = p
 	activeProfiler.run()
+	startRemoteConfig(p.cfg, opts)
 	return nil
 }
 
@@ -55,6 +71,10 @@ func Start(opts ...Option) error {
 // everything has been stopped.
 func Stop() {
 	mu.Lock()
+	if p := stopRemoteConfig(); p != nil {
+		// the profiler was disabled through remote configuration
+		p.closeExporters()
+	}
 	if activeProfiler != nil {
 		activeProfiler.stop()
 		activeProfiler = nil
@@ -73,25 +93,51 @@ type profiler struct {
 	wg              sync.WaitGroup    // wg waits for all goroutines to exit when stopping.
 	met             *metrics          // metric collector state
 	deltas          map[ProfileType]deltaProfiler
-	seq             uint64         // seq is the value of the profile_seq tag
-	pendingProfiles sync.WaitGroup // signal that profile collection is done, for stopping CPU profiling
+	custom          map[ProfileType]profileType   // custom holds the custom profiles, see WithCustomProfile
+	seq             uint64                        // seq is the value of the profile_seq tag
+	pendingProfiles sync.WaitGroup                // signal that profile collection is done, for stopping CPU profiling
+	cpuMu           sync.Mutex                    // cpuMu guards the CPU profiler, which is shared with captures
+	cpuPreempt      chan struct{}                 // cpuPreempt cuts the regular CPU profile short when a capture starts
+	captureMu       sync.Mutex                    // captureMu ensures a single capture runs at a time
+	overhead        *overheadControl              // overhead is nil unless WithMaxOverhead is given
+	redactor        *profileRedactor              // redactor is nil unless WithRedaction is given
+	leaks           map[ProfileType]*leakDetector // leaks holds the leak detectors, see WithGoroutineLeakDetection
 
 	testHooks testHooks
 
+	// restarting is set when the profiler is stopped to be replaced by
+	// another one with the same exporters, see remoteState.apply
+	restarting bool
+
+	// updateMu guards update, the configuration received through remote
+	// configuration and applied at the start of the next profiling period
+	updateMu sync.Mutex
+	update   *config
+
+	// periodNanos is cfg.period, for the goroutines reading it while it may
+	// be updated, see profiler.period
+	periodNanos int64
+
 	// lastTrace is the last time an execution trace was collected
 	lastTrace time.Time
 }
 
 func (p *profiler) shouldTrace() bool {
+	if p.cfg.traceConfig.SlowSpanThreshold > 0 {
+		// the execution traces are recorded by the flight recorder
+		return false
+	}
 	return p.cfg.traceEnabled && time.Since(p.lastTrace) > p.cfg.traceConfig.Period
 }
 
 // testHooks are functions that are replaced during testing which would normally
 // depend on accessing runtime state that is not needed/available for the test
 type testHooks struct {
-	startCPUProfile func(w io.Writer) error
-	stopCPUProfile  func()
-	lookupProfile   func(name string, w io.Writer, debug int) error
+	startCPUProfile   func(w io.Writer) error
+	stopCPUProfile    func()
+	lookupProfile     func(name string, w io.Writer, debug int) error
+	readTriggerSample func() triggerSample
+	newFlightRecorder func(cfg flightRecorderConfig) (flightRecorder, error)
 }
 
 func (p *profiler) startCPUProfile(w io.Writer) error {
@@ -180,25 +226,53 @@ func newProfiler(opts ...Option) (*profiler, error) {
 			return nil, fmt.Errorf("unknown profile type: %d", pt)
 		}
 	}
+	custom, err := customProfileTypes(cfg.customProfiles)
+	if err != nil {
+		return nil, err
+	}
 	if cfg.cpuDuration > cfg.period {
 		cfg.cpuDuration = cfg.period
 	}
+	if cfg.redaction != nil && cfg.traceEnabled {
+		log.Warn("Execution traces can't be redacted, disabling them.")
+		cfg.traceEnabled = false
+	}
 	if cfg.logStartup {
 		logStartup(cfg)
 	}
 
 	p := profiler{
-		cfg:    cfg,
-		out:    make(chan batch, outChannelSize),
-		exit:   make(chan struct{}),
-		met:    newMetrics(),
-		deltas: make(map[ProfileType]deltaProfiler),
+		cfg:        cfg,
+		out:        make(chan batch, outChannelSize),
+		exit:       make(chan struct{}),
+		met:        newMetrics(),
+		deltas:     make(map[ProfileType]deltaProfiler),
+		custom:     custom,
+		cpuPreempt: make(chan struct{}, 1),
+		overhead:   newOverheadControl(cfg),
+		redactor:   newProfileRedactor(cfg.redaction),
+
+		periodNanos: int64(cfg.period),
 	}
 	for pt := range cfg.types {
 		if d := profileTypes[pt].DeltaValues; len(d) > 0 {
 			p.deltas[pt] = newDeltaProfiler(p.cfg, d...)
 		}
 	}
+	for pt, t := range custom {
+		if len(t.DeltaValues) > 0 {
+			p.deltas[pt] = newDeltaProfiler(p.cfg, t.DeltaValues...)
+		}
+	}
+	if cfg.leakSnapshots > 0 {
+		p.leaks = make(map[ProfileType]*leakDetector)
+		if _, ok := cfg.types[GoroutineProfile]; ok {
+			p.leaks[GoroutineProfile] = newLeakDetector(cfg.leakSnapshots, leakReasonCount)
+		}
+		if _, ok := cfg.types[expGoroutineWaitProfile]; ok {
+			p.leaks[expGoroutineWaitProfile] = newLeakDetector(cfg.leakSnapshots, leakReasonWait)
+		}
+	}
 	p.uploadFunc = p.upload
 	return &p, nil
 }
@@ -222,18 +296,32 @@ func (p *profiler) run() {
 		tick := time.NewTicker(p.cfg.period)
 		defer tick.Stop()
 		p.met.reset(now()) // collect baseline metrics at profiler start
-		p.collect(tick.C)
+		p.collect(tick)
 	}()
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
 		p.send()
 	}()
+	if p.cfg.traceEnabled && p.cfg.traceConfig.SlowSpanThreshold > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.recordSlowSpans()
+		}()
+	}
+	if p.cfg.triggers != nil {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.watchTriggers(*p.cfg.triggers)
+		}()
+	}
 }
 
 // collect runs the profile types found in the configuration whenever the ticker receives
 // an item.
-func (p *profiler) collect(ticker <-chan time.Time) {
+func (p *profiler) collect(ticker *time.Ticker) {
 	defer close(p.out)
 	var (
 		// mu guards completed
@@ -245,15 +333,19 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 	// Enable endpoint counting (if configured). This causes some minimal
 	// overhead to the tracer, see BenchmarkEndpointCounter.
 	endpointCounter := traceprof.GlobalEndpointCounter()
-	endpointCounter.SetEnabled(p.cfg.endpointCountEnabled)
+	endpointCounter.SetEnabled(p.cfg.endpointCountEnabled || p.cfg.cpuTimePerRequest)
 	// Disable and reset when func returns (profiler stopped) to remove tracer
 	// overhead, free up the counter map, and avoid it from growing again.
 	defer func() {
 		endpointCounter.SetEnabled(false)
 		endpointCounter.GetAndReset()
+		if p.cfg.cpuTimePerRequest {
+			traceprof.SetEndpointCPUTime(nil)
+		}
 	}()
 
 	for {
+		p.applyUpdate(ticker)
 		bat := batch{
 			seq:   p.seq,
 			host:  p.cfg.hostname,
@@ -268,7 +360,7 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 		// finished (because p.pendingProfiles will have been
 		// incremented to count every non-CPU profile before CPU
 		// profiling starts)
-		profileTypes := p.enabledProfileTypes()
+		profileTypes := p.overhead.filter(p.enabledProfileTypes())
 		if p.shouldTrace() {
 			profileTypes = append(profileTypes, executionTrace)
 		}
@@ -286,8 +378,8 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 				}
 				profs, err := p.runProfile(t)
 				if err != nil {
-					log.Error("Error getting %s profile: %v; skipping.", t, err)
-					tags := append(p.cfg.tags.Slice(), t.Tag())
+					log.Error("Error getting %s profile: %v; skipping.", p.lookup(t).Name, err)
+					tags := append(p.cfg.tags.Slice(), p.lookup(t).tag())
 					p.cfg.statsd.Count("datadog.profiling.go.collect_error", 1, tags, 1)
 				}
 				mu.Lock()
@@ -299,10 +391,11 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 		for _, prof := range completed {
 			bat.addProfile(prof)
 		}
+		p.controlOverhead(profileTypes)
 
 		// Wait until the next profiling period starts or the profiler is stopped.
 		select {
-		case <-ticker:
+		case <-ticker.C:
 			// Usually ticker triggers right away because the non-CPU profiles cause
 			// the wg.Wait above to sleep until the end of the profiling period.
 			// Edge case: If only the CPU profile is enabled, and the cpu duration is
@@ -325,6 +418,13 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 		// The default configuration of the profiler (cpu duration = profiling
 		// period) results in a factor of 1.
 		bat.end = time.Now()
+		if p.cfg.cpuTimePerRequest {
+			p.reportCPUTimePerRequest(bat)
+		}
+		if !p.cfg.endpointCountEnabled {
+			// The endpoints were only counted for the CPU time per request
+			bat.endpointCounts = nil
+		}
 		// Upload profiling data.
 		p.enqueueUpload(bat)
 	}
@@ -335,6 +435,7 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 // interesting events in there and then try to look for the counter-part event
 // in the mutex/heap/block profile. Deterministic ordering is also important
 // for delta profiles, otherwise they'd cover varying profiling periods.
+// Custom profiles come last, in the order in which they were registered.
 func (p *profiler) enabledProfileTypes() []ProfileType {
 	order := []ProfileType{
 		CPUProfile,
@@ -352,6 +453,9 @@ func (p *profiler) enabledProfileTypes() []ProfileType {
 			enabled = append(enabled, t)
 		}
 	}
+	for i := range p.cfg.customProfiles {
+		enabled = append(enabled, customProfileBase+ProfileType(i))
+	}
 	return enabled
 }
 
@@ -384,8 +488,12 @@ func (p *profiler) send() {
 		case <-p.exit:
 			return
 		case bat := <-p.out:
-			if err := p.outputDir(bat); err != nil {
-				log.Error("Failed to output profile to dir: %v", err)
+			p.redactBatch(&bat)
+			if err := p.export(bat); err != nil {
+				log.Error("Failed to export profile: %v", err)
+			}
+			if !p.cfg.uploadEnabled {
+				continue
 			}
 			if err := p.uploadFunc(bat); err != nil {
 				log.Error("Failed to upload profile: %v", err)
@@ -394,28 +502,6 @@ func (p *profiler) send() {
 	}
 }
 
-func (p *profiler) outputDir(bat batch) error {
-	if p.cfg.outputDir == "" {
-		return nil
-	}
-	// Basic ISO 8601 Format in UTC as the name for the directories.
-	dir := bat.end.UTC().Format("20060102T150405Z")
-	dirPath := filepath.Join(p.cfg.outputDir, dir)
-	// 0755 is what mkdir does, should be reasonable for the use cases here.
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return err
-	}
-
-	for _, prof := range bat.profiles {
-		filePath := filepath.Join(dirPath, prof.name)
-		// 0644 is what touch does, should be reasonable for the use cases here.
-		if err := os.WriteFile(filePath, prof.data, 0644); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // interruptibleSleep sleeps for the given duration or until interrupted by the
 // p.exit channel being closed.
 func (p *profiler) interruptibleSleep(d time.Duration) {
@@ -431,6 +517,11 @@ func (p *profiler) stop() {
 		close(p.exit)
 	})
 	p.wg.Wait()
+	if !p.restarting {
+		if err := p.closeExporters(); err != nil {
+			log.Error("Failed to close profile exporter: %v", err)
+		}
+	}
 	if p.cfg.logStartup {
 		log.Info("Profiling stopped")
 	}
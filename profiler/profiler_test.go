@@ -487,8 +487,18 @@ func TestExecutionTrace(t *testing.T) {
 
 // TestEndpointCounts verfies that the unit of work feature works end to end.
 func TestEndpointCounts(t *testing.T) {
-	for _, enabled := range []bool{true, false} {
-		name := fmt.Sprintf("enabled=%v", enabled)
+	for _, tc := range []struct {
+		enabled           bool
+		cpuTimePerRequest bool
+	}{
+		{enabled: true},
+		{enabled: false},
+		// the endpoints are counted for the CPU time per request, but
+		// their counts are not uploaded
+		{enabled: false, cpuTimePerRequest: true},
+	} {
+		enabled := tc.enabled
+		name := fmt.Sprintf("enabled=%v,cpu-time-per-request=%v", enabled, tc.cpuTimePerRequest)
 		t.Run(name, func(t *testing.T) {
 			// Spin up mock backend
 			got := make(chan profileMeta, 1)
@@ -507,6 +517,7 @@ func TestEndpointCounts(t *testing.T) {
 				WithAgentAddr(server.Listener.Addr().String()),
 				WithProfileTypes(CPUProfile),
 				WithPeriod(100*time.Millisecond),
+				WithCPUTimePerRequest(tc.cpuTimePerRequest),
 			)
 			require.NoError(t, err)
 			defer Stop()
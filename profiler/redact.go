@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/redact"
+)
+
+// RedactionConfig configures the redaction of the profiles, see WithRedaction.
+type RedactionConfig struct {
+	// Patterns are matched against the function names, file names and mapping
+	// paths of the profiles. The matching strings are replaced with
+	// "<redacted>".
+	Patterns []*regexp.Regexp
+
+	// Hash replaces the redacted strings with "<redacted:HASH>" instead,
+	// HASH being derived from their SHA-256 hash, so that distinct functions
+	// and files can still be told apart. Short or guessable names can be
+	// recovered from their hash.
+	Hash bool
+
+	// AllowedLabels are the keys of the pprof labels kept in the profiles,
+	// e.g. "span id", "local root span id" and "trace endpoint" for the labels
+	// applied by the tracer. Every label is kept if it's nil.
+	AllowedLabels []string
+}
+
+// profileRedactor rewrites the pprof profiles of the batches before they are
+// exported and uploaded.
+type profileRedactor struct {
+	mu  sync.Mutex // mu guards the fields below, profiles are redacted one at a time
+	r   *redact.Redactor
+	buf bytes.Buffer
+	gzr gzip.Reader
+	gzw *gzip.Writer
+}
+
+func newProfileRedactor(cfg *RedactionConfig) *profileRedactor {
+	if cfg == nil {
+		return nil
+	}
+	pr := &profileRedactor{
+		r: redact.New(redact.Config{
+			Patterns:      cfg.Patterns,
+			Hash:          cfg.Hash,
+			AllowedLabels: cfg.AllowedLabels,
+		}),
+	}
+	pr.gzw = gzip.NewWriter(&pr.buf)
+	return pr
+}
+
+// redact returns the redacted copy of the pprof profile data, gzipped.
+func (pr *profileRedactor) redact(data []byte) ([]byte, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if isGzipData(data) {
+		if err := pr.gzr.Reset(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		var err error
+		data, err = io.ReadAll(&pr.gzr)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %v", err)
+		}
+	}
+	pr.buf.Reset()
+	pr.gzw.Reset(&pr.buf)
+	if err := pr.r.Redact(data, pr.gzw); err != nil {
+		return nil, err
+	}
+	if err := pr.gzw.Close(); err != nil {
+		return nil, fmt.Errorf("error flushing gzip writer: %v", err)
+	}
+	return append([]byte(nil), pr.buf.Bytes()...), nil
+}
+
+// redactBatch redacts the pprof profiles of bat, if WithRedaction is given.
+// Execution traces can't be redacted, so they are dropped, as are the profiles
+// which fail to be redacted.
+func (p *profiler) redactBatch(bat *batch) {
+	if p.redactor == nil {
+		return
+	}
+	profiles := make([]*profile, 0, len(bat.profiles))
+	for _, prof := range bat.profiles {
+		switch {
+		case prof.pt == executionTrace:
+			log.Debug("Dropping the execution trace, which can't be redacted.")
+			continue
+		case strings.HasSuffix(prof.name, ".pprof"):
+			data, err := p.redactor.redact(prof.data)
+			if err != nil {
+				log.Error("Failed to redact %s profile, dropping it: %v", prof.name, err)
+				tags := append(p.cfg.tags.Slice(), p.lookup(prof.pt).tag())
+				p.cfg.statsd.Count("datadog.profiling.go.redact_error", 1, tags, 1)
+				continue
+			}
+			prof = &profile{name: prof.name, pt: prof.pt, data: data}
+		}
+		profiles = append(profiles, prof)
+	}
+	bat.profiles = profiles
+}
@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	redaction := WithRedaction(RedactionConfig{
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`^acme/secret\.`)},
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		p, err := unstartedProfiler(redaction)
+		require.NoError(t, err)
+		bat := batch{profiles: []*profile{
+			{name: "files.pprof", pt: customProfileBase, data: textProfile{Text: "files/count\nmain;acme/secret.Open 3\n"}.Protobuf()},
+			{name: "metrics.json", pt: MetricsProfile, data: []byte("[]")},
+			{name: "go.trace", pt: executionTrace, data: []byte("trace")},
+			{name: "broken.pprof", pt: customProfileBase + 1, data: []byte("broken")},
+		}}
+		p.redactBatch(&bat)
+		require.Len(t, bat.profiles, 2)
+		assert.Equal(t, "files/count\nmain;<redacted> 3\n", protobufToText(bat.profiles[0].data))
+		assert.Equal(t, "metrics.json", bat.profiles[1].name)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			redaction,
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithCustomProfile("files", func() ([]byte, error) {
+				return textProfile{Text: "files/count\nmain;acme/secret.Open 3\nmain;os.Open 2\n"}.Protobuf(), nil
+			}),
+		)
+		require.NoError(t, err)
+		batches := make(chan batch, 10)
+		p.uploadFunc = func(bat batch) error {
+			batches <- bat
+			return nil
+		}
+		p.run()
+		bat := <-batches
+		p.stop()
+		require.Len(t, bat.profiles, 1)
+		assert.Equal(t, "files/count\nmain;<redacted> 3\nmain;os.Open 2\n", protobufToText(bat.profiles[0].data))
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_ENABLED", "true")
+		p, err := unstartedProfiler(redaction)
+		require.NoError(t, err)
+		assert.False(t, p.cfg.traceEnabled)
+	})
+}
@@ -0,0 +1,328 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+// apmTracingConfig is a configuration of the APM_TRACING remote configuration
+// product. The settings of the profiler are part of its library configuration.
+type apmTracingConfig struct {
+	LibConfig remoteConfig `json:"lib_config"`
+}
+
+// remoteConfig is the configuration of the profiler received through remote
+// configuration. Its unset fields leave the local configuration untouched.
+//
+// Experimental: these keys are not part of the APM_TRACING schema of the
+// backend yet, and may change.
+type remoteConfig struct {
+	// Enabled turns profiling on and off.
+	Enabled *bool `json:"profiling_enabled,omitempty"`
+	// ProfileTypes replaces the enabled profile types, see ProfileType.String
+	// for their names.
+	ProfileTypes []string `json:"profile_types,omitempty"`
+	// MutexFraction is the mutex profile fraction, see MutexProfileFraction.
+	MutexFraction *int `json:"mutex_profile_fraction,omitempty"`
+	// BlockRate is the block profile rate, see BlockProfileRate.
+	BlockRate *int `json:"block_profile_rate,omitempty"`
+	// Period is the profiling period, in seconds, see WithPeriod.
+	Period *float64 `json:"profile_period_seconds,omitempty"`
+	// CPUDuration is the CPU profile duration, in seconds, see CPUDuration.
+	CPUDuration *float64 `json:"cpu_duration_seconds,omitempty"`
+}
+
+// options returns the options applying c on top of the local configuration.
+func (c *remoteConfig) options() ([]Option, error) {
+	var opts []Option
+	if c.ProfileTypes != nil {
+		var types []ProfileType
+		for _, name := range c.ProfileTypes {
+			t, ok := profileTypeByName(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown profile type %q", name)
+			}
+			types = append(types, t)
+		}
+		opts = append(opts, WithProfileTypes(types...))
+	}
+	if c.MutexFraction != nil {
+		if *c.MutexFraction < 0 {
+			return nil, fmt.Errorf("invalid mutex profile fraction %d", *c.MutexFraction)
+		}
+		opts = append(opts, MutexProfileFraction(*c.MutexFraction))
+	}
+	if c.BlockRate != nil {
+		if *c.BlockRate < 0 {
+			return nil, fmt.Errorf("invalid block profile rate %d", *c.BlockRate)
+		}
+		opts = append(opts, BlockProfileRate(*c.BlockRate))
+	}
+	if c.Period != nil {
+		if *c.Period <= 0 {
+			return nil, fmt.Errorf("invalid profile period %vs", *c.Period)
+		}
+		opts = append(opts, WithPeriod(seconds(*c.Period)))
+	}
+	if c.CPUDuration != nil {
+		if *c.CPUDuration <= 0 {
+			return nil, fmt.Errorf("invalid CPU duration %vs", *c.CPUDuration)
+		}
+		opts = append(opts, CPUDuration(seconds(*c.CPUDuration)))
+	}
+	return opts, nil
+}
+
+// telemetry returns the settings of c, as reported to instrumentation telemetry.
+func (c *remoteConfig) telemetry() []telemetry.Configuration {
+	var configs []telemetry.Configuration
+	add := func(name string, value interface{}) {
+		configs = append(configs, telemetry.Configuration{Name: name, Value: value, Origin: "remote_config"})
+	}
+	if c.Enabled != nil {
+		add("profiling_enabled", *c.Enabled)
+	}
+	if c.ProfileTypes != nil {
+		add("profile_types", strings.Join(c.ProfileTypes, ","))
+	}
+	if c.MutexFraction != nil {
+		add("mutex_profile_fraction", *c.MutexFraction)
+	}
+	if c.BlockRate != nil {
+		add("block_profile_rate", *c.BlockRate)
+	}
+	if c.Period != nil {
+		add("profile_period", seconds(*c.Period).String())
+	}
+	if c.CPUDuration != nil {
+		add("cpu_duration", seconds(*c.CPUDuration).String())
+	}
+	return configs
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// profileTypeByName returns the profile type with the given name.
+func profileTypeByName(name string) (ProfileType, bool) {
+	for t, pt := range profileTypes {
+		if pt.Name == name && t != executionTrace {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// remoteState is the remote configuration state of the profiler started by
+// Start. It is guarded by mu.
+type remoteState struct {
+	opts     []Option  // opts are the options given to Start
+	disabled *profiler // disabled is the last profiler, if profiling was disabled
+}
+
+// rcState is the state of the remote configuration subscription, if any.
+var rcState *remoteState
+
+// startRemoteConfig subscribes the profiler started with opts, and configured
+// by cfg, to the remote configuration client of the tracer. Must be called
+// with mu locked.
+func startRemoteConfig(cfg *config, opts []Option) {
+	if !cfg.remoteConfigEnabled || cfg.agentless {
+		return
+	}
+	s := &remoteState{opts: opts}
+	remoteconfig.Subscribe(rc.ProductAPMTracing, s.onUpdate)
+	rcState = s
+}
+
+// stopRemoteConfig unsubscribes the profiler from remote configuration. It
+// returns the last profiler if profiling was disabled through remote
+// configuration, so that its exporters can be closed. Must be called with mu
+// locked.
+func stopRemoteConfig() (disabled *profiler) {
+	if rcState == nil {
+		return nil
+	}
+	remoteconfig.Unsubscribe(rc.ProductAPMTracing)
+	disabled = rcState.disabled
+	rcState = nil
+	return disabled
+}
+
+// onUpdate is the remote configuration callback of the profiler. It updates,
+// starts or stops the profiler with the received configuration.
+func (s *remoteState) onUpdate(updates map[string]remoteconfig.ProductUpdate) map[string]rc.ApplyStatus {
+	u, ok := updates[rc.ProductAPMTracing]
+	if !ok {
+		return nil
+	}
+	statuses := make(map[string]rc.ApplyStatus, len(u))
+	ack := func(err error) {
+		for path := range u {
+			if err != nil {
+				statuses[path] = rc.ApplyStatus{State: rc.ApplyStateError, Error: err.Error()}
+			} else {
+				statuses[path] = rc.ApplyStatus{State: rc.ApplyStateAcknowledged}
+			}
+		}
+	}
+
+	// A nil config means it was removed: go back to the local configuration.
+	var (
+		remote apmTracingConfig
+		n      int
+	)
+	for path, raw := range u {
+		if raw == nil {
+			continue
+		}
+		if n++; n > 1 {
+			ack(errors.New("more than one APM_TRACING configuration received"))
+			return statuses
+		}
+		if err := json.Unmarshal(raw, &remote); err != nil {
+			log.Error("profiler: Remote config: error while unmarshalling %s: %v. Configuration won't be applied.", path, err)
+			ack(err)
+			return statuses
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if rcState != s {
+		// the profiler was stopped or restarted in the meantime
+		return nil
+	}
+	err := s.apply(remote.LibConfig)
+	if err != nil {
+		log.Error("profiler: Remote config: configuration won't be applied: %v", err)
+	}
+	ack(err)
+	return statuses
+}
+
+// apply applies remote to the active profiler, starts a new profiler if
+// profiling was disabled, or stops the active profiler if remote disables
+// profiling. Must be called with mu locked.
+func (s *remoteState) apply(remote remoteConfig) error {
+	opts, err := remote.options()
+	if err != nil {
+		return err
+	}
+	var p *profiler
+	if remote.Enabled == nil || *remote.Enabled {
+		p, err = newProfiler(append(s.opts[:len(s.opts):len(s.opts)], opts...)...)
+		if err != nil {
+			return err
+		}
+	}
+	log.Debug("profiler: Remote config: applying %+v", remote)
+	switch {
+	case p != nil && activeProfiler != nil:
+		// keep the running profiler, along with its delta profiles and its
+		// profile_seq
+		activeProfiler.reconfigure(p.cfg)
+	case p != nil:
+		activeProfiler = p
+		s.disabled = nil
+		p.run()
+	case activeProfiler != nil:
+		// the exporters are shared with the next profiler
+		activeProfiler.restarting = true
+		activeProfiler.stop()
+		s.disabled = activeProfiler
+		activeProfiler = nil
+	}
+	if configs := remote.telemetry(); len(configs) > 0 && !telemetry.Disabled() {
+		telemetry.GlobalClient.ConfigChange(configs)
+	}
+	return nil
+}
+
+// reconfigure makes the profiler use the profile types, profiling period, CPU
+// duration, mutex profile fraction and block profile rate of cfg from the
+// next profiling period on.
+func (p *profiler) reconfigure(cfg *config) {
+	p.updateMu.Lock()
+	defer p.updateMu.Unlock()
+	p.update = cfg
+}
+
+// applyUpdate applies the configuration given to reconfigure, if any. It is
+// called by collect between two profiling periods, when no profile is being
+// collected.
+func (p *profiler) applyUpdate(ticker *time.Ticker) {
+	p.updateMu.Lock()
+	cfg := p.update
+	p.update = nil
+	p.updateMu.Unlock()
+	if cfg == nil {
+		return
+	}
+	for pt, t := range profileTypes {
+		_, enabled := cfg.types[pt]
+		if _, ok := p.deltas[pt]; !enabled {
+			// drop the baseline, which would be stale if the type is
+			// enabled again
+			delete(p.deltas, pt)
+		} else if !ok && len(t.DeltaValues) > 0 {
+			p.deltas[pt] = newDeltaProfiler(p.cfg, t.DeltaValues...)
+		}
+		if p.leaks == nil || (pt != GoroutineProfile && pt != expGoroutineWaitProfile) {
+			continue
+		}
+		if _, ok := p.leaks[pt]; !enabled {
+			delete(p.leaks, pt)
+		} else if !ok {
+			reason := leakReasonCount
+			if pt == expGoroutineWaitProfile {
+				reason = leakReasonWait
+			}
+			p.leaks[pt] = newLeakDetector(p.cfg.leakSnapshots, reason)
+		}
+	}
+	p.cfg.types = cfg.types
+	p.cfg.mutexFraction = cfg.mutexFraction
+	p.cfg.blockRate = cfg.blockRate
+	p.cfg.cpuDuration = cfg.cpuDuration
+	if _, ok := cfg.types[MutexProfile]; ok {
+		if o := p.overhead; o != nil {
+			o.mu.Lock()
+			o.mutexFraction = cfg.mutexFraction
+			o.configured.mutexFraction = cfg.mutexFraction
+			o.mu.Unlock()
+		}
+		runtime.SetMutexProfileFraction(cfg.mutexFraction)
+	}
+	if _, ok := cfg.types[BlockProfile]; ok {
+		runtime.SetBlockProfileRate(cfg.blockRate)
+	}
+	if cfg.period != p.cfg.period {
+		p.cfg.period = cfg.period
+		atomic.StoreInt64(&p.periodNanos, int64(cfg.period))
+		ticker.Reset(cfg.period)
+	}
+}
+
+// period returns the profiling period. Unlike cfg.period, it may be called
+// from any goroutine.
+func (p *profiler) period() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.periodNanos))
+}
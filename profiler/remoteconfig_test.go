@@ -0,0 +1,169 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry/telemetrytest"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteConfig(t *testing.T) {
+	t.Setenv("DD_PROFILING_REMOTE_CONFIGURATION_ENABLED", "true")
+	telemetryClient := new(telemetrytest.MockClient)
+	defer telemetry.MockGlobalClient(telemetryClient)()
+
+	backend := &mockBackend{t: t, profiles: make(chan profileMeta)}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(-1))
+	require.NoError(t, Start(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithProfileTypes(HeapProfile),
+		WithPeriod(10*time.Millisecond),
+		WithLogStartup(false),
+	))
+	defer Stop()
+
+	const path = "datadog/2/APM_TRACING/config/config"
+	// update sends the given library configuration, or removes the configuration if empty
+	update := func(libConfig string) rc.ApplyStatus {
+		mu.Lock()
+		s := rcState
+		mu.Unlock()
+		require.NotNil(t, s)
+		u := remoteconfig.ProductUpdate{path: []byte(`{"lib_config": ` + libConfig + `}`)}
+		if libConfig == "" {
+			u[path] = nil
+		}
+		return s.onUpdate(map[string]remoteconfig.ProductUpdate{rc.ProductAPMTracing: u})[path]
+	}
+	active := func() *profiler {
+		mu.Lock()
+		defer mu.Unlock()
+		return activeProfiler
+	}
+
+	// next returns the next uploaded profile with the given attachment, and its
+	// profile_seq
+	next := func(attachment string) (profileMeta, int) {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case prof := <-backend.profiles:
+				if _, ok := prof.attachments[attachment]; !ok {
+					continue
+				}
+				for _, tag := range prof.tags {
+					if strings.HasPrefix(tag, "profile_seq:") {
+						seq, err := strconv.Atoi(strings.TrimPrefix(tag, "profile_seq:"))
+						require.NoError(t, err)
+						return prof, seq
+					}
+				}
+				t.Fatalf("no profile_seq tag in %v", prof.tags)
+			case <-timeout:
+				t.Fatalf("no profile with %s uploaded", attachment)
+			}
+		}
+	}
+
+	t.Run("update", func(t *testing.T) {
+		p := active()
+		_, before := next("delta-heap.pprof")
+		status := update(`{"profile_types": ["cpu", "goroutine"], "mutex_profile_fraction": 20, "profile_period_seconds": 0.02}`)
+		assert.Equal(t, rc.ApplyStateAcknowledged, status.State)
+		// the running profiler is updated, rather than replaced
+		assert.Same(t, p, active())
+		prof, seq := next("goroutines.pprof")
+		assert.Greater(t, seq, before)
+		assert.Contains(t, prof.attachments, "cpu.pprof")
+		assert.Contains(t, prof.attachments, "delta-mutex.pprof")
+		assert.NotContains(t, prof.attachments, "delta-heap.pprof")
+		assert.Equal(t, 20, runtime.SetMutexProfileFraction(-1))
+		assert.Equal(t, 20*time.Millisecond, p.period())
+		assert.Contains(t, telemetryClient.Configuration, telemetry.Configuration{
+			Name:   "mutex_profile_fraction",
+			Value:  20,
+			Origin: "remote_config",
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		p := active()
+		status := update(`{"profile_types": ["nope"]}`)
+		assert.Equal(t, rc.ApplyStateError, status.State)
+		assert.Equal(t, `unknown profile type "nope"`, status.Error)
+		status = update(`{"cpu_duration_seconds": "1"}`)
+		assert.Equal(t, rc.ApplyStateError, status.State)
+		assert.Same(t, p, active())
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		status := update(`{"profiling_enabled": false}`)
+		assert.Equal(t, rc.ApplyStateAcknowledged, status.State)
+		assert.Nil(t, active())
+		assert.Equal(t, ErrProfilerNotRunning, Capture(context.Background(), CaptureOptions{}))
+		assert.Contains(t, telemetryClient.Configuration, telemetry.Configuration{
+			Name:   "profiling_enabled",
+			Value:  false,
+			Origin: "remote_config",
+		})
+
+		status = update(`{"profiling_enabled": true}`)
+		assert.Equal(t, rc.ApplyStateAcknowledged, status.State)
+		assert.NotNil(t, active())
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		p := active()
+		require.NotNil(t, p)
+		_, before := next("metrics.json")
+		status := update("")
+		assert.Equal(t, rc.ApplyStateAcknowledged, status.State)
+		prof, seq := next("delta-heap.pprof")
+		assert.Greater(t, seq, before)
+		assert.NotContains(t, prof.attachments, "goroutines.pprof")
+		assert.Equal(t, 10*time.Millisecond, p.period())
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		mu.Lock()
+		s := rcState
+		mu.Unlock()
+		Stop()
+		u := remoteconfig.ProductUpdate{path: []byte(`{"lib_config": {"profiling_enabled": true}}`)}
+		assert.Empty(t, s.onUpdate(map[string]remoteconfig.ProductUpdate{rc.ProductAPMTracing: u}))
+		assert.Nil(t, active())
+	})
+}
+
+func TestRemoteConfigDisabled(t *testing.T) {
+	// Remote configuration is opt-in
+	server := httptest.NewServer(&mockBackend{t: t, profiles: make(chan profileMeta)})
+	defer server.Close()
+	require.NoError(t, Start(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithProfileTypes(),
+		WithLogStartup(false),
+	))
+	defer Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Nil(t, rcState)
+}
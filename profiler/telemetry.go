@@ -45,6 +45,11 @@ func startTelemetry(c *config) {
 			{Name: "execution_trace_enabled", Value: c.traceEnabled},
 			{Name: "execution_trace_period", Value: c.traceConfig.Period.String()},
 			{Name: "execution_trace_size_limit", Value: c.traceConfig.Limit},
+			{Name: "execution_trace_slow_span_threshold", Value: c.traceConfig.SlowSpanThreshold.String()},
 			{Name: "endpoint_count_enabled", Value: c.endpointCountEnabled},
+			{Name: "max_overhead", Value: c.maxOverhead},
+			{Name: "redaction_enabled", Value: c.redaction != nil},
+			{Name: "goroutine_leak_snapshots", Value: c.leakSnapshots},
+			{Name: "cpu_time_per_request_enabled", Value: c.cpuTimePerRequest},
 		}...))
 }
@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	rtmetrics "runtime/metrics"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// defaultTriggerCheckInterval is the default value of CaptureTriggers.CheckInterval.
+	defaultTriggerCheckInterval = 10 * time.Second
+
+	// defaultTriggerCooldown is the default value of CaptureTriggers.Cooldown.
+	defaultTriggerCooldown = 5 * time.Minute
+)
+
+// CaptureTriggers configures the thresholds above which the profiler captures
+// profiles on its own, see WithCaptureTriggers. A zero threshold disables the
+// corresponding trigger.
+type CaptureTriggers struct {
+	// CPUUtilization triggers a capture when the process uses more than this
+	// fraction of the CPU available to it (GOMAXPROCS) between two checks,
+	// e.g. 0.9 for 90%. It requires Go 1.20 or later.
+	CPUUtilization float64
+
+	// HeapGrowth triggers a capture when the heap grows by more than this
+	// fraction since the profiler started or since the last capture triggered
+	// by heap growth, e.g. 0.5 for 50%.
+	HeapGrowth float64
+
+	// Goroutines triggers a capture when the number of goroutines exceeds this
+	// value.
+	Goroutines int
+
+	// CheckInterval is the interval at which the thresholds are checked. It
+	// defaults to 10 seconds.
+	CheckInterval time.Duration
+
+	// Cooldown is the minimum time between two triggered captures. It defaults
+	// to 5 minutes.
+	Cooldown time.Duration
+
+	// Capture configures the triggered captures. Its Reason is replaced by the
+	// name of the trigger: "cpu", "heap" or "goroutines".
+	Capture CaptureOptions
+}
+
+// triggerSample holds the runtime measurements checked against the thresholds
+// of CaptureTriggers.
+type triggerSample struct {
+	cpuTotal   float64 // cpuTotal is the available CPU time, in seconds
+	cpuIdle    float64 // cpuIdle is the unused CPU time, in seconds
+	heap       uint64  // heap is the size of the heap, in bytes
+	goroutines int
+}
+
+// readTriggerSample reads the current triggerSample. The CPU times are left to
+// zero if the Go version doesn't report them.
+func (p *profiler) readTriggerSample() triggerSample {
+	if p.testHooks.readTriggerSample != nil {
+		return p.testHooks.readTriggerSample()
+	}
+	samples := []rtmetrics.Sample{
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+		{Name: "/memory/classes/heap/objects:bytes"},
+	}
+	rtmetrics.Read(samples)
+	s := triggerSample{goroutines: runtime.NumGoroutine()}
+	if samples[0].Value.Kind() == rtmetrics.KindFloat64 && samples[1].Value.Kind() == rtmetrics.KindFloat64 {
+		s.cpuTotal = samples[0].Value.Float64()
+		s.cpuIdle = samples[1].Value.Float64()
+	}
+	if samples[2].Value.Kind() == rtmetrics.KindUint64 {
+		s.heap = samples[2].Value.Uint64()
+	}
+	return s
+}
+
+// check returns the name of the first trigger whose threshold is exceeded by cur,
+// or "" if there is none. prev is the previous sample and heapBase is the heap
+// size the HeapGrowth threshold is relative to.
+func (t *CaptureTriggers) check(prev, cur triggerSample, heapBase uint64) string {
+	if t.CPUUtilization > 0 {
+		if total := cur.cpuTotal - prev.cpuTotal; total > 0 {
+			used := total - (cur.cpuIdle - prev.cpuIdle)
+			if used/total > t.CPUUtilization {
+				return "cpu"
+			}
+		}
+	}
+	if t.HeapGrowth > 0 && heapBase > 0 && float64(cur.heap) > float64(heapBase)*(1+t.HeapGrowth) {
+		return "heap"
+	}
+	if t.Goroutines > 0 && cur.goroutines > t.Goroutines {
+		return "goroutines"
+	}
+	return ""
+}
+
+// watchTriggers checks the thresholds of t every t.CheckInterval and captures
+// profiles when one is exceeded, until the profiler is stopped.
+func (p *profiler) watchTriggers(t CaptureTriggers) {
+	tick := time.NewTicker(t.CheckInterval)
+	defer tick.Stop()
+	prev := p.readTriggerSample()
+	heapBase := prev.heap
+	var last time.Time
+	for {
+		select {
+		case <-p.exit:
+			return
+		case <-tick.C:
+		}
+		cur := p.readTriggerSample()
+		reason := t.check(prev, cur, heapBase)
+		prev = cur
+		if reason == "" || (!last.IsZero() && now().Sub(last) < t.Cooldown) {
+			continue
+		}
+		last = now()
+		opts := t.Capture
+		opts.Reason = reason
+		err := p.capture(context.Background(), opts)
+		if errors.Is(err, ErrCaptureInProgress) {
+			log.Debug("Skipping %s triggered capture: %v", reason, err)
+		} else if err != nil {
+			log.Error("Triggered capture (%s) failed: %v", reason, err)
+		}
+		// Don't let the capture itself count towards the next check, and re-arm
+		// the heap trigger relative to the current heap size.
+		prev = p.readTriggerSample()
+		if reason == "heap" {
+			heapBase = prev.heap
+		}
+	}
+}
@@ -42,7 +42,7 @@ func (p *profiler) upload(bat batch) error {
 		err = p.doRequest(bat)
 		if rerr, ok := err.(*retriableError); ok {
 			statsd.Count("datadog.profiling.go.upload_retry", 1, nil, 1)
-			wait := time.Duration(rand.Int63n(p.cfg.period.Nanoseconds())) * time.Nanosecond
+			wait := time.Duration(rand.Int63n(p.period().Nanoseconds())) * time.Nanosecond
 			log.Error("Uploading profile failed: %v. Trying again in %s...", rerr, wait)
 			p.interruptibleSleep(wait)
 			continue
@@ -68,16 +68,18 @@ type retriableError struct{ err error }
 // Error implements error.
 func (e retriableError) Error() string { return e.err.Error() }
 
-// doRequest makes an HTTP POST request to the Datadog Profiling API with the
-// given profile.
-func (p *profiler) doRequest(bat batch) error {
-	tags := append(p.cfg.tags.Slice(),
-		fmt.Sprintf("service:%s", p.cfg.service),
+// tags returns the tags of the given batch of profiles.
+func (p *profiler) tags(bat batch) []string {
+	tags := append(p.cfg.tags.Slice(), fmt.Sprintf("service:%s", p.cfg.service))
+	if bat.trigger != "" {
+		// On-demand captures are out of the sequence of the regular profiles.
+		tags = append(tags, fmt.Sprintf("trigger:%s", bat.trigger))
+	} else {
 		// The profile_seq tag can be used to identify the first profile
 		// uploaded by a given runtime-id, identify missing profiles, etc.. See
 		// PROF-5612 (internal) for more details.
-		fmt.Sprintf("profile_seq:%d", bat.seq),
-	)
+		tags = append(tags, fmt.Sprintf("profile_seq:%d", bat.seq))
+	}
 	// If the user did not configure an "env" in the client, we should omit
 	// the tag so that the agent has a chance to supply a default tag.
 	// Otherwise, the tag supplied by the client will have priority.
@@ -91,7 +93,17 @@ func (p *profiler) doRequest(bat batch) error {
 			tags = append(tags, "go_execution_traced:yes")
 		}
 	}
-	contentType, body, err := encode(bat, tags)
+	tags = append(tags, bat.tags...)
+	if bat.host != "" {
+		tags = append(tags, fmt.Sprintf("host:%s", bat.host))
+	}
+	return append(tags, "runtime:go")
+}
+
+// doRequest makes an HTTP POST request to the Datadog Profiling API with the
+// given profile.
+func (p *profiler) doRequest(bat batch) error {
+	contentType, body, err := encode(p.exportedBatch(bat))
 	if err != nil {
 		return err
 	}
@@ -148,33 +160,35 @@ type uploadEvent struct {
 	EndpointCounts map[string]uint64 `json:"endpoint_counts,omitempty"`
 }
 
-// encode encodes the profile as a multipart mime request.
-func encode(bat batch, tags []string) (contentType string, body io.Reader, err error) {
-	var buf bytes.Buffer
-
-	mw := multipart.NewWriter(&buf)
-
-	if bat.host != "" {
-		tags = append(tags, fmt.Sprintf("host:%s", bat.host))
-	}
-	tags = append(tags, "runtime:go")
-
+// newUploadEvent returns the event describing the batch b.
+func newUploadEvent(b Batch) *uploadEvent {
 	event := &uploadEvent{
 		Version:        "4",
 		Family:         "go",
-		Start:          bat.start.Format(time.RFC3339Nano),
-		End:            bat.end.Format(time.RFC3339Nano),
-		Tags:           strings.Join(tags, ","),
-		EndpointCounts: bat.endpointCounts,
+		Start:          b.Start.Format(time.RFC3339Nano),
+		End:            b.End.Format(time.RFC3339Nano),
+		Tags:           strings.Join(b.Tags, ","),
+		EndpointCounts: b.EndpointCounts,
 	}
+	for _, p := range b.Profiles {
+		event.Attachments = append(event.Attachments, p.Name)
+	}
+	return event
+}
+
+// encode encodes the profile as a multipart mime request.
+func encode(b Batch) (contentType string, body io.Reader, err error) {
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
 
-	for _, p := range bat.profiles {
-		event.Attachments = append(event.Attachments, p.name)
-		f, err := mw.CreateFormFile(p.name, p.name)
+	event := newUploadEvent(b)
+	for _, p := range b.Profiles {
+		f, err := mw.CreateFormFile(p.Name, p.Name)
 		if err != nil {
 			return "", nil, err
 		}
-		if _, err := f.Write(p.data); err != nil {
+		if _, err := f.Write(p.Data); err != nil {
 			return "", nil, err
 		}
 	}